@@ -0,0 +1,4 @@
+// Package api содержит Protobuf-спецификации API календаря.
+package api
+
+//go:generate protoc --go_out=. --go_opt=module=github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/api --go-grpc_out=. --go-grpc_opt=module=github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/api EventService.proto
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/spf13/cobra"
+)
+
+// serviceSections — разделы конфигурации, которые проверяет `config check` для каждого процесса.
+var serviceSections = map[string][]config.Section{
+	"calendar":  config.CalendarSections,
+	"scheduler": config.SchedulerSections,
+	"sender":    config.SenderSections,
+}
+
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect service configuration",
+	}
+
+	var service string
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Load the config with environment and --set overrides and report all problems",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			sections, ok := serviceSections[service]
+			if !ok {
+				return fmt.Errorf("unknown service %q, use %s", service, strings.Join(serviceNames(), ", "))
+			}
+			cmd.SilenceUsage = true
+			if _, err := config.Load(configPath, overrides, sections...); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Configuration for %s is valid\n", service)
+			return nil
+		},
+	}
+	checkCmd.Flags().StringVar(&service, "service", "calendar",
+		"process the config is for: "+strings.Join(serviceNames(), ", "))
+
+	configCmd.AddCommand(checkCmd)
+	return configCmd
+}
+
+func serviceNames() []string {
+	names := make([]string, 0, len(serviceSections))
+	for name := range serviceSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/app"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/ical"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// newICalCmd — подкоманды `ical import|export` для переноса событий в формате .ics.
+func newICalCmd() *cobra.Command {
+	icalCmd := &cobra.Command{
+		Use:   "ical",
+		Short: "Import and export events in iCalendar (.ics) format",
+	}
+
+	var userID, tz string
+	icalCmd.PersistentFlags().StringVar(&userID, "user", "", "owner of the events (required)")
+	icalCmd.PersistentFlags().StringVar(&tz, "tz", "UTC", "time zone for floating times and dates")
+	_ = icalCmd.MarkPersistentFlagRequired("user")
+
+	var dryRun bool
+	importCmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import events from an .ics file ('-' for stdin)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Аргументы разобраны: дальше ошибки не связаны с использованием команды
+			cmd.SilenceUsage = true
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return fmt.Errorf("unknown time zone %q", tz)
+			}
+			events, err := decodeFile(args[0], loc)
+			if err != nil {
+				return err
+			}
+
+			// Пробный прогон ничего не сохраняет, поэтому допустим и без журнала
+			a, err := newApp(cmd.Context(), !dryRun)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = a.Close() }()
+
+			results, err := a.ImportEvents(cmd.Context(), userID, events, dryRun)
+			printImportResults(os.Stdout, results)
+			if err != nil {
+				return err
+			}
+			problems := 0
+			for _, r := range results {
+				if r.Status == ical.ImportConflict || r.Status == ical.ImportInvalid {
+					problems++
+				}
+			}
+			if problems > 0 {
+				return fmt.Errorf("%d of %d events cannot be imported", problems, len(results))
+			}
+			return nil
+		},
+	}
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only report conflicts, do not save anything")
+
+	var from, to, out string
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export events in [from, to) to an .ics file",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return fmt.Errorf("unknown time zone %q", tz)
+			}
+			start, err := time.ParseInLocation(time.DateOnly, from, loc)
+			if err != nil {
+				return fmt.Errorf("bad --from: %w", err)
+			}
+			end, err := time.ParseInLocation(time.DateOnly, to, loc)
+			if err != nil {
+				return fmt.Errorf("bad --to: %w", err)
+			}
+
+			a, err := newApp(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = a.Close() }()
+
+			events, err := a.ExportEvents(cmd.Context(), userID, start, end)
+			if err != nil {
+				return err
+			}
+			if out == "" || out == "-" {
+				return ical.Encode(os.Stdout, events)
+			}
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			if err := ical.Encode(f, events); err != nil {
+				_ = f.Close()
+				return err
+			}
+			return f.Close()
+		},
+	}
+	exportCmd.Flags().StringVar(&from, "from", "", "first day, YYYY-MM-DD (required)")
+	exportCmd.Flags().StringVar(&to, "to", "", "day after the last one, YYYY-MM-DD (required)")
+	exportCmd.Flags().StringVar(&out, "out", "-", "output file, '-' for stdout")
+	_ = exportCmd.MarkFlagRequired("from")
+	_ = exportCmd.MarkFlagRequired("to")
+
+	icalCmd.AddCommand(importCmd, exportCmd)
+	return icalCmd
+}
+
+// newApp создаёт приложение по конфигурации. Если persistent, хранилище должно
+// сохранять события между запусками: иначе импорт пропал бы при выходе из команды.
+func newApp(ctx context.Context, persistent bool) (*app.App, error) {
+	cfg, err := config.Load(configPath, overrides, config.StorageSection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if persistent && cfg.Storage.Type == config.InMemory && cfg.Storage.InMemory.Dir == "" {
+		return nil, fmt.Errorf("inmemory storage without storage.inmemory.dir does not keep events, " +
+			"set the dir or use sql or sqlite storage")
+	}
+	return app.New(ctx, cfg)
+}
+
+// decodeFile читает события из файла или, если path равен "-", из stdin.
+func decodeFile(path string, loc *time.Location) ([]storage.Event, error) {
+	if path == "-" {
+		return ical.Decode(os.Stdin, loc)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return ical.Decode(f, loc)
+}
+
+func printImportResults(w io.Writer, results []ical.ImportResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tID\tSTART\tTITLE\tERROR")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			r.Status, r.ID, r.DateTime.Format(time.RFC3339), r.Title, r.Error)
+	}
+	_ = tw.Flush()
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/lib/pq"
 	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
@@ -10,19 +13,30 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var configPath string
+var (
+	configPath string
+	overrides  []string
+)
 
 func main() {
 	rootCmd := &cobra.Command{
 		Use:   "calendar",
 		Short: "Calendar service skeleton",
+		// Ошибку печатает main
+		SilenceErrors: true,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			cfg, err := config.LoadConfig(configPath)
+			cfg, err := config.Load(configPath, overrides, config.CalendarSections...)
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
-			app := app.New(cfg)
-			return app.Run()
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			app, err := app.New(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			return app.Run(ctx)
 		},
 	}
 
@@ -35,8 +49,11 @@ func main() {
 		},
 	}
 
-	rootCmd.Flags().StringVar(&configPath, "config", "config.yaml", "path to config file")
-	rootCmd.AddCommand(versionCmd)
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "config.yaml",
+		"path to config file; empty uses defaults and "+config.EnvPrefix+"* environment variables only")
+	rootCmd.PersistentFlags().StringArrayVar(&overrides, "set", nil,
+		"override a config value, e.g. --set server.port=8081 (repeatable)")
+	rootCmd.AddCommand(versionCmd, newMigrateCmd(), newICalCmd(), newConfigCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
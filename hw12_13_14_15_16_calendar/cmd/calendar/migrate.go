@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/app"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/migration"
+	sqlstorage "github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/sql"
+	sqlitestorage "github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/sqlite"
+	"github.com/spf13/cobra"
+)
+
+// newMigrateCmd — подкоманды `migrate up|down|status` для хранилищ sql и sqlite.
+func newMigrateCmd() *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database schema migrations",
+	}
+
+	upCmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		RunE: withMigrator(func(ctx context.Context, m *migration.Migrator) error {
+			applied, err := m.Up(ctx)
+			for _, mig := range applied {
+				fmt.Printf("applied %05d_%s\n", mig.Version, mig.Name)
+			}
+			if err == nil && len(applied) == 0 {
+				fmt.Println("schema is up to date")
+			}
+			return err
+		}),
+	}
+
+	var steps int
+	downCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the last applied migrations",
+		RunE: withMigrator(func(ctx context.Context, m *migration.Migrator) error {
+			if steps < 1 {
+				return fmt.Errorf("steps must be positive, got %d", steps)
+			}
+			rolledBack, err := m.Down(ctx, steps)
+			for _, mig := range rolledBack {
+				fmt.Printf("rolled back %05d_%s\n", mig.Version, mig.Name)
+			}
+			return err
+		}),
+	}
+	downCmd.Flags().IntVar(&steps, "steps", 1, "number of migrations to roll back")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show applied and pending migrations",
+		RunE: withMigrator(func(ctx context.Context, m *migration.Migrator) error {
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+			for _, st := range statuses {
+				state, appliedAt := "pending", "-"
+				if st.Applied {
+					state, appliedAt = "applied", st.AppliedAt.Format("2006-01-02 15:04:05 -0700")
+				}
+				if st.Modified {
+					state += " (modified)"
+				}
+				fmt.Fprintf(w, "%05d\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt)
+			}
+			return w.Flush()
+		}),
+	}
+
+	migrateCmd.AddCommand(upCmd, downCmd, statusCmd)
+	return migrateCmd
+}
+
+// withMigrator подключается к БД из конфигурации и передаёт Migrator в fn.
+func withMigrator(
+	fn func(ctx context.Context, m *migration.Migrator) error,
+) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, _ []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.Load(configPath, overrides, config.StorageSection)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		log, err := app.NewLogger(cfg)
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+		var (
+			db          *sqlx.DB
+			newMigrator func(*sqlx.DB) (*migration.Migrator, error)
+		)
+		switch cfg.Storage.Type {
+		case config.SQL:
+			db, err = sqlstorage.Connect(ctx, cfg.Storage.SQL.DSN, app.SQLOptions(cfg), log)
+			newMigrator = migration.New
+		case config.SQLite:
+			db, err = sqlitestorage.Open(ctx, cfg.Storage.SQLite.Path)
+			newMigrator = migration.NewSQLite
+		default:
+			return fmt.Errorf("migrations require storage type %q or %q, got %q",
+				config.SQL, config.SQLite, cfg.Storage.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer func() { _ = db.Close() }()
+
+		m, err := newMigrator(db)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, m)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/lib/pq"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/app"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/scheduler"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configPath string
+	overrides  []string
+)
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:   "calendar_scheduler",
+		Short: "Calendar notification scheduler",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := config.Load(configPath, overrides, config.SchedulerSections...)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			log, err := app.NewLogger(cfg)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			checks := health.NewRegistry(0)
+			store, err := app.NewStorage(ctx, cfg, log, checks)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = store.Close() }()
+
+			q, err := app.NewQueue(cfg, log)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = q.Close() }()
+			checks.Register("queue", q.Ping)
+			app.ServeMonitoring(ctx, cfg, log, checks)
+
+			s := scheduler.New(log, store, q, scheduler.Config{
+				Interval:        cfg.Scheduler.Interval,
+				Retention:       cfg.Scheduler.Retention,
+				CleanupInterval: cfg.Scheduler.CleanupInterval,
+			})
+			return s.Run(ctx)
+		},
+	}
+
+	rootCmd.Flags().StringVar(&configPath, "config", "scheduler_config.yaml",
+		"path to config file; empty uses defaults and "+config.EnvPrefix+"* environment variables only")
+	rootCmd.Flags().StringArrayVar(&overrides, "set", nil,
+		"override a config value, e.g. --set logger.level=debug (repeatable)")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/app"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/sender"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configPath string
+	overrides  []string
+)
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:   "calendar_sender",
+		Short: "Calendar notification sender",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := config.Load(configPath, overrides, config.SenderSections...)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			log, err := app.NewLogger(cfg)
+			if err != nil {
+				return err
+			}
+
+			sinks, err := newSinks(cfg, log)
+			if err != nil {
+				return err
+			}
+
+			q, err := app.NewQueue(cfg, log)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = q.Close() }()
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			checks := health.NewRegistry(0)
+			checks.Register("queue", q.Ping)
+			app.ServeMonitoring(ctx, cfg, log, checks)
+
+			s := sender.New(log, q, sinks, sender.Backoff{
+				MaxAttempts:     cfg.Sender.Retry.MaxAttempts,
+				Initial:         cfg.Sender.Retry.InitialBackoff,
+				Max:             cfg.Sender.Retry.MaxBackoff,
+				MaxRedeliveries: cfg.Sender.Retry.MaxRedeliveries,
+			})
+			return s.Run(ctx)
+		},
+	}
+
+	rootCmd.Flags().StringVar(&configPath, "config", "sender_config.yaml",
+		"path to config file; empty uses defaults and "+config.EnvPrefix+"* environment variables only")
+	rootCmd.Flags().StringArrayVar(&overrides, "set", nil,
+		"override a config value, e.g. --set logger.level=debug (repeatable)")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newSinks(cfg *config.Config, log *logrus.Logger) (map[string]sender.Sink, error) {
+	sinks := make(map[string]sender.Sink, len(cfg.Sender.Sinks))
+	for _, name := range cfg.Sender.Sinks {
+		switch name {
+		case "log":
+			sinks[name] = sender.NewLogSink(log)
+		case "stdout":
+			sinks[name] = sender.NewWriterSink(os.Stdout)
+		case "webhook":
+			sinks[name] = sender.NewWebhookSink(cfg.Sender.Webhook.URL, cfg.Sender.Webhook.Timeout)
+		default:
+			return nil, fmt.Errorf("unknown sink %q", name)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("no sinks configured")
+	}
+	return sinks, nil
+}
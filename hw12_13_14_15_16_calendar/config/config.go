@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,32 +15,163 @@ type StorageType string
 const (
 	InMemory StorageType = "inmemory"
 	SQL      StorageType = "sql"
+	SQLite   StorageType = "sqlite"
 )
 
 type Config struct {
 	Server struct {
+		Host            string        `yaml:"host"`
+		Port            int           `yaml:"port"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // время на завершение текущих запросов
+	} `yaml:"server"`
+	GRPC struct {
 		Host string `yaml:"host"`
 		Port int    `yaml:"port"`
-	} `yaml:"server"`
+	} `yaml:"grpc"`
+	Metrics struct {
+		Addr string `yaml:"addr"` // адрес /metrics, /healthz и /readyz scheduler и sender; пусто — не отдавать
+	} `yaml:"metrics"`
 	Logger struct {
-		Level string `yaml:"level"` // debug, info, warn, error
+		Level  string `yaml:"level"`  // debug, info, warn, error
+		Format string `yaml:"format"` // text, json
+		Output string `yaml:"output"` // stderr, stdout, file
+		File   struct {
+			Path       string `yaml:"path"`
+			MaxSize    int    `yaml:"max_size"`    // мегабайт до ротации
+			MaxBackups int    `yaml:"max_backups"` // 0 — хранить все старые файлы
+			MaxAge     int    `yaml:"max_age"`     // дней; 0 — бессрочно
+			Compress   bool   `yaml:"compress"`    // сжимать старые файлы gzip
+		} `yaml:"file"`
 	} `yaml:"logger"`
 	Storage struct {
-		Type StorageType `yaml:"type"`
-		SQL  struct {
-			DSN string `yaml:"dsn"`
+		Type     StorageType `yaml:"type"`
+		InMemory struct {
+			Dir              string        `yaml:"dir"`               // журнал и снимки; пусто — без сохранения
+			Fsync            string        `yaml:"fsync"`             // always, interval, never
+			FsyncInterval    time.Duration `yaml:"fsync_interval"`    // для fsync: interval
+			SnapshotInterval time.Duration `yaml:"snapshot_interval"` // 0 — снимок только при запуске и остановке
+		} `yaml:"inmemory"`
+		SQL struct {
+			DSN             string        `yaml:"dsn"`
+			ConnectTimeout  time.Duration `yaml:"connect_timeout"`    // сколько ждать БД при запуске; 0 — одна попытка
+			MaxOpenConns    int           `yaml:"max_open_conns"`     // 0 — без ограничения
+			MaxIdleConns    int           `yaml:"max_idle_conns"`     // 0 — 2; не больше max_open_conns
+			ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`  // 0 — соединения не пересоздаются
+			ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"` // 0 — простаивающие не закрываются
 		} `yaml:"sql"`
+		SQLite struct {
+			Path string `yaml:"path"` // файл БД; создаётся при первом запуске
+		} `yaml:"sqlite"`
 	} `yaml:"storage"`
+	Queue struct {
+		URL             string        `yaml:"url"`
+		Exchange        string        `yaml:"exchange"`
+		Queue           string        `yaml:"queue"`
+		DeadLetterQueue string        `yaml:"dead_letter_queue"` // недоставленные уведомления
+		RoutingKey      string        `yaml:"routing_key"`
+		Prefetch        int           `yaml:"prefetch"`
+		ReconnectDelay  time.Duration `yaml:"reconnect_delay"`
+	} `yaml:"queue"`
+	Scheduler struct {
+		Interval        time.Duration `yaml:"interval"`         // период сканирования событий
+		Retention       time.Duration `yaml:"retention"`        // срок хранения событий, явный 0 — бессрочно
+		CleanupInterval time.Duration `yaml:"cleanup_interval"` // период удаления старых событий
+	} `yaml:"scheduler"`
+	Sender struct {
+		Sinks   []string `yaml:"sinks"` // log, stdout, webhook
+		Webhook struct {
+			URL     string        `yaml:"url"`
+			Timeout time.Duration `yaml:"timeout"`
+		} `yaml:"webhook"`
+		Retry struct {
+			MaxAttempts     int           `yaml:"max_attempts"`
+			InitialBackoff  time.Duration `yaml:"initial_backoff"`
+			MaxBackoff      time.Duration `yaml:"max_backoff"`
+			MaxRedeliveries int           `yaml:"max_redeliveries"`
+		} `yaml:"retry"`
+	} `yaml:"sender"`
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию; файл, переменные окружения
+// и флаги переопределяют их. Значения, у которых нет разумного умолчания (DSN, адрес очереди),
+// остаются пустыми.
+func Default() *Config {
+	var cfg Config
+	cfg.Server.Host = "0.0.0.0"
+	cfg.Server.Port = 8080
+	cfg.Server.ShutdownTimeout = 10 * time.Second
+	cfg.GRPC.Host = "0.0.0.0"
+	cfg.GRPC.Port = 50051
+	cfg.Logger.Level = "info"
+	cfg.Logger.Format = "text"
+	cfg.Logger.Output = "stderr"
+	cfg.Logger.File.Path = "calendar.log"
+	cfg.Logger.File.MaxSize = 100
+	cfg.Logger.File.MaxBackups = 5
+	cfg.Logger.File.MaxAge = 30
+	cfg.Storage.Type = InMemory
+	cfg.Storage.InMemory.Fsync = "interval"
+	cfg.Storage.InMemory.FsyncInterval = time.Second
+	cfg.Storage.InMemory.SnapshotInterval = 5 * time.Minute
+	cfg.Storage.SQL.ConnectTimeout = 30 * time.Second
+	cfg.Storage.SQL.MaxOpenConns = 10
+	cfg.Storage.SQL.MaxIdleConns = 5
+	cfg.Storage.SQL.ConnMaxLifetime = 30 * time.Minute
+	cfg.Storage.SQL.ConnMaxIdleTime = 5 * time.Minute
+	cfg.Storage.SQLite.Path = "calendar.db"
+	cfg.Queue.Exchange = "calendar"
+	cfg.Queue.Queue = "notifications"
+	cfg.Queue.DeadLetterQueue = "notifications.dead"
+	cfg.Queue.RoutingKey = "notification"
+	cfg.Queue.Prefetch = 10
+	cfg.Queue.ReconnectDelay = 5 * time.Second
+	cfg.Scheduler.Interval = time.Minute
+	cfg.Scheduler.Retention = 365 * 24 * time.Hour
+	cfg.Scheduler.CleanupInterval = time.Hour
+	cfg.Sender.Sinks = []string{"log"}
+	cfg.Sender.Webhook.Timeout = 5 * time.Second
+	cfg.Sender.Retry.MaxAttempts = 5
+	cfg.Sender.Retry.InitialBackoff = 200 * time.Millisecond
+	cfg.Sender.Retry.MaxBackoff = 10 * time.Second
+	cfg.Sender.Retry.MaxRedeliveries = 5
+	return &cfg
+}
+
+// Load собирает конфигурацию из слоёв, каждый следующий переопределяет предыдущий:
+// значения по умолчанию, файл path (пустой — без файла), переменные окружения
+// CALENDAR_<КЛЮЧ> (см. EnvName) и переопределения "ключ=значение" из флагов --set.
+// Затем проверяет разделы sections и возвращает *ValidationError со всеми найденными проблемами.
+func Load(path string, overrides []string, sections ...Section) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var problems []string
+	problems = append(problems, cfg.applyEnv(os.LookupEnv)...)
+	problems = append(problems, cfg.applyOverrides(overrides)...)
+	problems = append(problems, cfg.validate(sections)...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
 }
 
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+// readFile накладывает на cfg значения из YAML-файла. Неизвестные ключи — ошибка:
+// опечатка в имени параметра иначе молча оставила бы значение по умолчанию.
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	defer func() { _ = f.Close() }()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
 	}
-	return &cfg, nil
+	return nil
 }
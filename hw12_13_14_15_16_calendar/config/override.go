@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix — префикс переменных окружения, переопределяющих конфигурацию.
+const EnvPrefix = "CALENDAR_"
+
+// EnvName возвращает имя переменной окружения для ключа конфигурации:
+// storage.sql.dsn — CALENDAR_STORAGE_SQL_DSN.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// fieldsOf собирает конечные (не структурные) поля v по их ключам.
+func fieldsOf(v reflect.Value, prefix string) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	t := v.Type()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			for k, f := range fieldsOf(field, key+".") {
+				fields[k] = f
+			}
+			continue
+		}
+		fields[key] = field
+	}
+	return fields
+}
+
+// applyEnv переопределяет параметры, для которых задана переменная окружения,
+// и возвращает описания некорректных значений.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) []string {
+	fields := fieldsOf(reflect.ValueOf(c).Elem(), "")
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	// Порядок важен только для стабильного порядка сообщений об ошибках
+	sort.Strings(keys)
+
+	var problems []string
+	for _, key := range keys {
+		value, ok := lookup(EnvName(key))
+		if !ok {
+			continue
+		}
+		if err := setField(fields[key], value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", EnvName(key), err))
+		}
+	}
+	return problems
+}
+
+// applyOverrides применяет переопределения вида "ключ=значение" и возвращает описания ошибок.
+func (c *Config) applyOverrides(overrides []string) []string {
+	fields := fieldsOf(reflect.ValueOf(c).Elem(), "")
+	var problems []string
+	for _, o := range overrides {
+		key, value, ok := strings.Cut(o, "=")
+		if !ok {
+			problems = append(problems, fmt.Sprintf("--set %s: expected key=value", o))
+			continue
+		}
+		field, known := fields[key]
+		if !known {
+			problems = append(problems, fmt.Sprintf("--set %s: unknown key %q", o, key))
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			problems = append(problems, fmt.Sprintf("--set %s: %v", o, err))
+		}
+	}
+	return problems
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField разбирает value по типу поля: длительности — как в time.ParseDuration,
+// списки — через запятую.
+func setField(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
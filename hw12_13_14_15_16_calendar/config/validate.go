@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Section — раздел конфигурации, который проверяется, только если процесс им пользуется:
+// например, API-сервису не нужна очередь, а рассыльщику — хранилище.
+// Разделы logger и metrics проверяются всегда.
+type Section string
+
+const (
+	ServerSection    Section = "server" // server и grpc
+	StorageSection   Section = "storage"
+	QueueSection     Section = "queue"
+	SchedulerSection Section = "scheduler"
+	SenderSection    Section = "sender"
+)
+
+// Разделы, которые использует каждый из процессов календаря.
+var (
+	CalendarSections  = []Section{ServerSection, StorageSection}
+	SchedulerSections = []Section{StorageSection, QueueSection, SchedulerSection}
+	SenderSections    = []Section{QueueSection, SenderSection}
+)
+
+// ValidationError перечисляет все проблемы конфигурации.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate проверяет разделы sections и возвращает *ValidationError со всеми найденными проблемами.
+func (c *Config) Validate(sections ...Section) error {
+	if problems := c.validate(sections); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// problems накапливает описания проблем вида "ключ: что не так".
+type problems []string
+
+func (p *problems) addf(key, format string, args ...any) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (c *Config) validate(sections []Section) []string {
+	var p problems
+	if _, err := logrus.ParseLevel(c.Logger.Level); err != nil {
+		p.addf("logger.level", "unknown level %q", c.Logger.Level)
+	}
+	c.validateLogger(&p)
+	if c.Metrics.Addr != "" {
+		checkAddr(&p, "metrics.addr", c.Metrics.Addr)
+	}
+	for _, s := range sections {
+		switch s {
+		case ServerSection:
+			c.validateServer(&p)
+		case StorageSection:
+			c.validateStorage(&p)
+		case QueueSection:
+			c.validateQueue(&p)
+		case SchedulerSection:
+			c.validateScheduler(&p)
+		case SenderSection:
+			c.validateSender(&p)
+		}
+	}
+	return p
+}
+
+func (c *Config) validateLogger(p *problems) {
+	l := c.Logger
+	if !slices.Contains([]string{"text", "json"}, l.Format) {
+		p.addf("logger.format", "must be text or json, got %q", l.Format)
+	}
+	if !slices.Contains([]string{"stderr", "stdout", "file"}, l.Output) {
+		p.addf("logger.output", "must be stderr, stdout or file, got %q", l.Output)
+	}
+	if l.Output != "file" {
+		return
+	}
+	if l.File.Path == "" {
+		p.addf("logger.file.path", "is required for output %q", l.Output)
+	}
+	checkNotNegative(p, "logger.file.max_size", int64(l.File.MaxSize))
+	checkNotNegative(p, "logger.file.max_backups", int64(l.File.MaxBackups))
+	checkNotNegative(p, "logger.file.max_age", int64(l.File.MaxAge))
+}
+
+func (c *Config) validateServer(p *problems) {
+	checkPort(p, "server.port", c.Server.Port)
+	checkPort(p, "grpc.port", c.GRPC.Port)
+	if c.Server.Port == c.GRPC.Port && c.Server.Host == c.GRPC.Host {
+		p.addf("grpc.port", "must differ from server.port")
+	}
+	checkNotNegative(p, "server.shutdown_timeout", int64(c.Server.ShutdownTimeout))
+}
+
+func (c *Config) validateStorage(p *problems) {
+	st := c.Storage
+	switch st.Type {
+	case InMemory:
+		if st.InMemory.Dir == "" {
+			break
+		}
+		if !slices.Contains([]string{"always", "interval", "never"}, st.InMemory.Fsync) {
+			p.addf("storage.inmemory.fsync", "must be always, interval or never, got %q", st.InMemory.Fsync)
+		}
+		if st.InMemory.Fsync == "interval" && st.InMemory.FsyncInterval <= 0 {
+			p.addf("storage.inmemory.fsync_interval", "must be positive when fsync is interval")
+		}
+		checkNotNegative(p, "storage.inmemory.snapshot_interval", int64(st.InMemory.SnapshotInterval))
+	case SQL:
+		sql := st.SQL
+		if sql.DSN == "" {
+			p.addf("storage.sql.dsn", "is required for storage type %q", SQL)
+		}
+		checkNotNegative(p, "storage.sql.connect_timeout", int64(sql.ConnectTimeout))
+		checkNotNegative(p, "storage.sql.max_open_conns", int64(sql.MaxOpenConns))
+		checkNotNegative(p, "storage.sql.max_idle_conns", int64(sql.MaxIdleConns))
+		if sql.MaxOpenConns > 0 && sql.MaxIdleConns > sql.MaxOpenConns {
+			p.addf("storage.sql.max_idle_conns", "must not exceed storage.sql.max_open_conns")
+		}
+		checkNotNegative(p, "storage.sql.conn_max_lifetime", int64(sql.ConnMaxLifetime))
+		checkNotNegative(p, "storage.sql.conn_max_idle_time", int64(sql.ConnMaxIdleTime))
+	case SQLite:
+		if st.SQLite.Path == "" {
+			p.addf("storage.sqlite.path", "is required for storage type %q", SQLite)
+		}
+	default:
+		p.addf("storage.type", "must be %s, %s or %s, got %q", InMemory, SQL, SQLite, st.Type)
+	}
+}
+
+func (c *Config) validateQueue(p *problems) {
+	q := c.Queue
+	if q.URL == "" {
+		p.addf("queue.url", "is required")
+	} else {
+		checkURL(p, "queue.url", q.URL, "amqp", "amqps")
+	}
+	if q.Exchange == "" {
+		p.addf("queue.exchange", "is required")
+	}
+	if q.Queue == "" {
+		p.addf("queue.queue", "is required")
+	}
+	if q.DeadLetterQueue == "" {
+		p.addf("queue.dead_letter_queue", "is required")
+	} else if q.DeadLetterQueue == q.Queue {
+		p.addf("queue.dead_letter_queue", "must differ from queue.queue")
+	}
+	checkNotNegative(p, "queue.prefetch", int64(q.Prefetch))
+	checkNotNegative(p, "queue.reconnect_delay", int64(q.ReconnectDelay))
+}
+
+func (c *Config) validateScheduler(p *problems) {
+	s := c.Scheduler
+	if s.Interval <= 0 {
+		p.addf("scheduler.interval", "must be positive")
+	}
+	checkNotNegative(p, "scheduler.retention", int64(s.Retention))
+	checkNotNegative(p, "scheduler.cleanup_interval", int64(s.CleanupInterval))
+}
+
+func (c *Config) validateSender(p *problems) {
+	s := c.Sender
+	if len(s.Sinks) == 0 {
+		p.addf("sender.sinks", "at least one sink is required")
+	}
+	for _, sink := range s.Sinks {
+		if !slices.Contains([]string{"log", "stdout", "webhook"}, sink) {
+			p.addf("sender.sinks", "unknown sink %q, use log, stdout or webhook", sink)
+		}
+	}
+	if slices.Contains(s.Sinks, "webhook") {
+		checkURL(p, "sender.webhook.url", s.Webhook.URL, "http", "https")
+		checkNotNegative(p, "sender.webhook.timeout", int64(s.Webhook.Timeout))
+	}
+	if s.Retry.MaxAttempts < 1 {
+		p.addf("sender.retry.max_attempts", "must be at least 1")
+	}
+	checkNotNegative(p, "sender.retry.initial_backoff", int64(s.Retry.InitialBackoff))
+	if s.Retry.MaxBackoff < s.Retry.InitialBackoff {
+		p.addf("sender.retry.max_backoff", "must not be less than sender.retry.initial_backoff")
+	}
+	checkNotNegative(p, "sender.retry.max_redeliveries", int64(s.Retry.MaxRedeliveries))
+}
+
+func checkPort(p *problems, key string, port int) {
+	if port < 1 || port > 65535 {
+		p.addf(key, "must be between 1 and 65535, got %d", port)
+	}
+}
+
+// checkURL проверяет, что raw — абсолютный URL с одной из схем schemes.
+// Сам URL в сообщение не попадает: в нём может быть пароль.
+func checkURL(p *problems, key, raw string, schemes ...string) {
+	u, err := url.Parse(raw)
+	if err != nil || !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+		p.addf(key, "must be an absolute URL with scheme %s", strings.Join(schemes, " or "))
+	}
+}
+
+func checkAddr(p *problems, key, addr string) {
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		p.addf(key, "must be host:port or :port, got %q", addr)
+	}
+}
+
+func checkNotNegative(p *problems, key string, v int64) {
+	if v < 0 {
+		p.addf(key, "must not be negative")
+	}
+}
@@ -1,14 +1,18 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
-	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/logger"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
 	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/server"
+	grpcserver "github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/server/grpc"
 	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
-	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
-	sqlstorage "github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/sql"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,34 +21,194 @@ type App struct {
 	logger *logrus.Logger
 	store  storage.Storage
 	srv    *server.Server
+	grpc   *grpcserver.Server
 }
 
-func New(cfg *config.Config) *App {
-	log := logger.New(cfg.Logger.Level)
-	var store storage.Storage
-
-	switch cfg.Storage.Type {
-	case config.InMemory:
-		store = inmemory.New()
-	case config.SQL:
-		s, err := sqlstorage.New(cfg.Storage.SQL.DSN, log)
-		if err != nil {
-			panic(fmt.Sprintf("failed to init SQL storage: %v", err))
-		}
-		store = s
-	default:
-		panic("unknown storage type")
+// New подключает хранилище и создаёт серверы; ctx ограничивает ожидание хранилища при запуске.
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	log, err := NewLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	checks := health.NewRegistry(0)
+	store, err := NewStorage(ctx, cfg, log, checks)
+	if err != nil {
+		return nil, err
 	}
 
-	srv := server.New(log, cfg.Server.Host, cfg.Server.Port)
-	return &App{
+	a := &App{
 		cfg:    cfg,
 		logger: log,
 		store:  store,
-		srv:    srv,
 	}
+	a.srv = server.New(log, a, checks, cfg.Server.Host, cfg.Server.Port)
+	a.grpc = grpcserver.New(log, a, cfg.GRPC.Host, cfg.GRPC.Port)
+	return a, nil
+}
+
+const (
+	// DefaultListLimit — размер страницы ListEvents, если limit не задан.
+	DefaultListLimit = 100
+	// DefaultSearchLimit — число результатов SearchEvents, если limit не задан.
+	DefaultSearchLimit = 20
+	// MaxListLimit — наибольший размер страницы ListEvents и SearchEvents.
+	MaxListLimit = 1000
+)
+
+// defaultShutdownTimeout используется, если server.shutdown_timeout не задан.
+const defaultShutdownTimeout = 10 * time.Second
+
+// Run запускает HTTP и GRPC серверы и работает до отмены ctx или падения одного из них.
+// Затем серверы останавливаются с ожиданием текущих запросов, а хранилище закрывается.
+func (a *App) Run(ctx context.Context) error {
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- a.srv.Start()
+	}()
+	go func() {
+		errCh <- a.grpc.Start()
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		a.logger.Info("Shutting down")
+	case err = <-errCh:
+		a.logger.WithError(err).Error("Server failed, shutting down")
+	}
+
+	if stopErr := a.shutdown(); stopErr != nil && err == nil {
+		err = stopErr
+	}
+	return err
+}
+
+// shutdown останавливает серверы в пределах server.shutdown_timeout и закрывает хранилище.
+func (a *App) shutdown() error {
+	timeout := a.cfg.Server.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if errs[0] = a.srv.Stop(ctx); errs[0] != nil {
+			errs[0] = fmt.Errorf("failed to stop HTTP server: %w", errs[0])
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if errs[1] = a.grpc.Stop(ctx); errs[1] != nil {
+			errs[1] = fmt.Errorf("failed to stop GRPC server: %w", errs[1])
+		}
+	}()
+	wg.Wait()
+
+	// Хранилище закрываем последним: им могли пользоваться завершающиеся запросы
+	if errs[2] = a.store.Close(); errs[2] != nil {
+		errs[2] = fmt.Errorf("failed to close storage: %w", errs[2])
+	}
+	return errors.Join(errs...)
+}
+
+// Close закрывает хранилище. Используется, когда App не запускается через Run (например, в CLI).
+func (a *App) Close() error {
+	return a.store.Close()
+}
+
+// CreateEvent сохраняет новое событие пользователя userID. Если ID не задан — генерирует UUID.
+func (a *App) CreateEvent(ctx context.Context, userID string, event storage.Event) (storage.Event, error) {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+	event.UserID = userID
+	if err := event.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	if err := a.store.Add(ctx, event); err != nil {
+		return storage.Event{}, err
+	}
+	return event, nil
+}
+
+// UpdateEvent заменяет событие пользователя userID с указанным ID.
+func (a *App) UpdateEvent(ctx context.Context, userID, id string, event storage.Event) (storage.Event, error) {
+	event.ID = id
+	event.UserID = userID
+	if err := event.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	if err := a.store.Update(ctx, userID, id, event); err != nil {
+		return storage.Event{}, err
+	}
+	return event, nil
+}
+
+func (a *App) DeleteEvent(ctx context.Context, userID, id string) error {
+	return a.store.Delete(ctx, userID, id)
+}
+
+func (a *App) GetEvent(ctx context.Context, userID, id string) (storage.Event, error) {
+	return a.store.Get(ctx, userID, id)
+}
+
+// ListAllEvents возвращает все события пользователя без разворачивания повторений.
+func (a *App) ListAllEvents(ctx context.Context, userID string) ([]storage.Event, error) {
+	return a.store.ListAll(ctx, userID)
+}
+
+// ListEvents возвращает страницу экземпляров событий пользователя, начинающихся в [from, to).
+// limit 0 означает DefaultListLimit.
+func (a *App) ListEvents(
+	ctx context.Context, userID string, from, to time.Time, cursor string, limit int,
+) ([]storage.Event, string, error) {
+	limit, err := pageLimit(limit, DefaultListLimit)
+	if err != nil {
+		return nil, "", err
+	}
+	return a.store.List(ctx, userID, from, to, cursor, limit)
+}
+
+// SearchEvents ищет события пользователя по словам в названии и описании.
+// limit 0 означает DefaultSearchLimit.
+func (a *App) SearchEvents(ctx context.Context, userID, query string, limit int) ([]storage.Event, error) {
+	limit, err := pageLimit(limit, DefaultSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+	return a.store.Search(ctx, userID, query, limit)
+}
+
+// pageLimit подставляет значение по умолчанию и проверяет верхнюю границу.
+func pageLimit(limit, def int) (int, error) {
+	if limit == 0 {
+		return def, nil
+	}
+	if limit < 0 || limit > MaxListLimit {
+		return 0, fmt.Errorf("%w: limit must be between 1 and %d", storage.ErrInvalidQuery, MaxListLimit)
+	}
+	return limit, nil
+}
+
+func (a *App) ListDay(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListDay(ctx, userID, date, mode)
+}
+
+func (a *App) ListWeek(
+	ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListWeek(ctx, userID, startDate, mode)
 }
 
-func (a *App) Run() error {
-	return a.srv.Start()
+func (a *App) ListMonth(
+	ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListMonth(ctx, userID, startDate, mode)
 }
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_StorageUnavailable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := lis.Addr().(*net.TCPAddr).Port
+	require.NoError(t, lis.Close())
+
+	cfg := config.Default()
+	cfg.Logger.Level = "error"
+	cfg.Storage.Type = config.SQL
+	cfg.Storage.SQL.DSN = fmt.Sprintf("host=127.0.0.1 port=%d user=calendar dbname=calendar sslmode=disable", port)
+	cfg.Storage.SQL.ConnectTimeout = 0
+
+	// Недоступная БД — ошибка, а не паника
+	a, err := New(context.Background(), cfg)
+	assert.Nil(t, a)
+	assert.ErrorContains(t, err, "failed to connect to database")
+}
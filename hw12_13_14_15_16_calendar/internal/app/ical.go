@@ -0,0 +1,191 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/ical"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+)
+
+// ImportEvents сохраняет события пользователя userID. Событие с уже существующим
+// ID (UID из .ics) обновляется, остальные создаются. Обновления выполняются первыми,
+// чтобы новые события не конфликтовали со старым временем переносимых.
+// Ошибки отдельных событий попадают в результат и не прерывают импорт.
+//
+// При dryRun ничего не сохраняется: события проверяются на корректность
+// и на пересечения с существующими событиями и друг с другом.
+func (a *App) ImportEvents(
+	ctx context.Context, userID string, events []storage.Event, dryRun bool,
+) ([]ical.ImportResult, error) {
+	prepared := make([]storage.Event, len(events))
+	results := make([]ical.ImportResult, len(events))
+	valid := make([]storage.Event, 0, len(events))
+	for i, e := range events {
+		if e.ID == "" {
+			e.ID = uuid.NewString()
+		}
+		e.UserID = userID
+		prepared[i] = e
+		results[i] = ical.ImportResult{ID: e.ID, Title: e.Title, DateTime: e.DateTime}
+		if err := e.Validate(); err != nil {
+			results[i].Status, results[i].Error = ical.ImportInvalid, err.Error()
+			continue
+		}
+		valid = append(valid, e)
+	}
+
+	target := importTarget{update: a.store.Update, add: a.store.Add}
+	if dryRun {
+		var err error
+		if target, err = a.dryRunTarget(ctx, userID, valid); err != nil {
+			return nil, err
+		}
+	}
+
+	// Первый проход — обновления, второй — новые события
+	var toAdd []int
+	for i := range results {
+		if results[i].Status == ical.ImportInvalid {
+			continue
+		}
+		err := target.update(ctx, userID, prepared[i].ID, prepared[i])
+		if errors.Is(err, storage.ErrEventNotFound) {
+			toAdd = append(toAdd, i)
+			continue
+		}
+		if err := target.result(&results[i], ical.ImportUpdated, err); err != nil {
+			return results, err
+		}
+	}
+	for _, i := range toAdd {
+		if err := target.result(&results[i], ical.ImportCreated, target.add(ctx, prepared[i])); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// importTarget — куда сохраняются импортируемые события: в хранилище
+// или, при пробном прогоне, во временную копию.
+type importTarget struct {
+	update func(ctx context.Context, userID, id string, e storage.Event) error
+	add    func(ctx context.Context, e storage.Event) error
+	dryRun bool
+}
+
+// result заполняет итог по ошибке сохранения. Ошибки, не связанные с самим
+// событием, возвращаются и прерывают импорт.
+func (t importTarget) result(r *ical.ImportResult, status ical.ImportStatus, err error) error {
+	switch {
+	case err == nil:
+		r.Status = status
+		if t.dryRun {
+			r.Status = ical.ImportOK
+		}
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrEventExists):
+		r.Status, r.Error = ical.ImportConflict, err.Error()
+	default:
+		return fmt.Errorf("failed to import event %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// dryRunTarget готовит временное хранилище с экземплярами существующих событий
+// пользователя вокруг импортируемых. События с теми же ID туда не копируются:
+// при импорте они будут заменены.
+func (a *App) dryRunTarget(ctx context.Context, userID string, events []storage.Event) (importTarget, error) {
+	scratch := inmemory.New()
+	target := importTarget{
+		add:    scratch.Add,
+		dryRun: true,
+	}
+	existing := make(map[string]bool)
+	target.update = func(ctx context.Context, _, id string, e storage.Event) error {
+		if !existing[id] {
+			return storage.ErrEventNotFound
+		}
+		return scratch.Add(ctx, e)
+	}
+	if len(events) == 0 {
+		return target, nil
+	}
+
+	replaced := make(map[string]bool, len(events))
+	from, to := events[0].DateTime, events[0].DateTime
+	for _, e := range events {
+		replaced[e.ID] = true
+		if e.DateTime.Before(from) {
+			from = e.DateTime
+		}
+		if e.DateTime.After(to) {
+			to = e.DateTime
+		}
+	}
+	// Экземпляры, начавшиеся накануне, ещё могут идти; повторяющиеся события
+	// проверяются на пересечения в пределах storage.ConflictHorizon.
+	occurrences, err := a.occurrences(ctx, userID, from.AddDate(0, 0, -1), to.Add(storage.ConflictHorizon))
+	if err != nil {
+		return target, err
+	}
+	for i, e := range occurrences {
+		if replaced[e.ID] {
+			existing[e.ID] = true
+			continue
+		}
+		e.ID = fmt.Sprintf("existing-%d", i)
+		e.RRule, e.ExDates = "", nil
+		if err := scratch.Add(ctx, e); err != nil {
+			return target, err
+		}
+	}
+	return target, nil
+}
+
+// ExportEvents возвращает экземпляры событий пользователя, начинающиеся в [from, to).
+// Повторяющиеся события разворачиваются в отдельные разовые события
+// с ID вида <id>-<начало экземпляра в UTC>. Период не длиннее ical.MaxExportSpan.
+func (a *App) ExportEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	events, err := a.occurrences(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range events {
+		if e.IsRecurring() {
+			events[i].ID = e.ID + "-" + e.DateTime.UTC().Format("20060102T150405Z")
+			events[i].RRule, events[i].ExDates = "", nil
+		}
+	}
+	return events, nil
+}
+
+// occurrences собирает экземпляры событий в [from, to) помесячными запросами к хранилищу.
+// Период длиннее ical.MaxExportSpan отклоняется, чтобы число запросов было ограничено.
+func (a *App) occurrences(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	if to.Sub(from) > ical.MaxExportSpan {
+		return nil, fmt.Errorf("%w: range must not exceed %d days",
+			storage.ErrInvalidQuery, ical.MaxExportSpan/(24*time.Hour))
+	}
+	var result []storage.Event
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+	for ; month.Before(to); month = month.AddDate(0, 1, 0) {
+		events, err := a.store.ListMonth(ctx, userID, month, storage.StartsInRange)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			if !e.DateTime.Before(from) && e.DateTime.Before(to) {
+				result = append(result, e)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DateTime.Before(result[j].DateTime)
+	})
+	return result, nil
+}
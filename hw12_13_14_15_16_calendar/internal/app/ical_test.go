@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/ical"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+
+func statuses(results []ical.ImportResult) []ical.ImportStatus {
+	s := make([]ical.ImportStatus, 0, len(results))
+	for _, r := range results {
+		s = append(s, r.Status)
+	}
+	return s
+}
+
+func TestApp_ImportEvents(t *testing.T) {
+	ctx := context.Background()
+	a := &App{store: inmemory.New()}
+	_, err := a.CreateEvent(ctx, "user1", storage.Event{ID: "existing", Title: "Busy", DateTime: base, Duration: 3600})
+	require.NoError(t, err)
+	_, err = a.CreateEvent(ctx, "user1", storage.Event{ID: "keep", Title: "Keep", DateTime: base.Add(4 * time.Hour)})
+	require.NoError(t, err)
+
+	events := []storage.Event{
+		{ID: "a", Title: "Clashes with keep", DateTime: base.Add(4 * time.Hour), Duration: 600},
+		{ID: "b", Title: "Free slot", DateTime: base.Add(2 * time.Hour), Duration: 600},
+		{ID: "c", Title: "Clashes with b", DateTime: base.Add(2*time.Hour + 5*time.Minute), Duration: 600},
+		{ID: "d", DateTime: base},
+		{ID: "existing", Title: "Moved", DateTime: base.Add(-2 * time.Hour), Duration: 600},
+	}
+
+	results, err := a.ImportEvents(ctx, "user1", events, true)
+	require.NoError(t, err)
+	assert.Equal(t, []ical.ImportStatus{
+		ical.ImportConflict, ical.ImportOK, ical.ImportConflict, ical.ImportInvalid, ical.ImportOK,
+	}, statuses(results))
+	assert.NotEmpty(t, results[3].Error)
+
+	// Пробный прогон ничего не меняет
+	list, err := a.ListDay(ctx, "user1", base, storage.StartsInRange)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	// Старое время "existing" не мешает: событие переносится раньше, чем создаются новые
+	events[0].DateTime = base.Add(30 * time.Minute)
+	expected := []ical.ImportStatus{
+		ical.ImportOK, ical.ImportOK, ical.ImportConflict, ical.ImportInvalid, ical.ImportOK,
+	}
+	results, err = a.ImportEvents(ctx, "user1", events, true)
+	require.NoError(t, err)
+	assert.Equal(t, expected, statuses(results))
+
+	results, err = a.ImportEvents(ctx, "user1", events, false)
+	require.NoError(t, err)
+	assert.Equal(t, []ical.ImportStatus{
+		ical.ImportCreated, ical.ImportCreated, ical.ImportConflict, ical.ImportInvalid, ical.ImportUpdated,
+	}, statuses(results))
+
+	// Тот же UID у другого пользователя — конфликт, а не перезапись
+	results, err = a.ImportEvents(ctx, "user2", events[1:2], false)
+	require.NoError(t, err)
+	assert.Equal(t, []ical.ImportStatus{ical.ImportConflict}, statuses(results))
+}
+
+func TestApp_ExportEvents(t *testing.T) {
+	ctx := context.Background()
+	a := &App{store: inmemory.New()}
+	_, err := a.CreateEvent(ctx, "user1", storage.Event{
+		ID: "daily", Title: "Daily", DateTime: base, Duration: 600, RRule: "FREQ=DAILY;COUNT=40",
+	})
+	require.NoError(t, err)
+	_, err = a.CreateEvent(ctx, "user1", storage.Event{ID: "once", Title: "Once", DateTime: base.Add(time.Hour)})
+	require.NoError(t, err)
+
+	// Период захватывает конец мая и начало июня
+	events, err := a.ExportEvents(ctx, "user1", base.AddDate(0, 0, 20), base.AddDate(0, 0, 25))
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	assert.Equal(t, "daily-20240530T100000Z", events[0].ID)
+	assert.Equal(t, "daily-20240603T100000Z", events[4].ID)
+	for _, e := range events {
+		assert.False(t, e.IsRecurring())
+	}
+
+	events, err = a.ExportEvents(ctx, "user1", base, base.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "once", events[1].ID)
+
+	// Слишком длинный период отклоняется до запросов к хранилищу
+	_, err = a.ExportEvents(ctx, "user1", time.Time{}, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+}
@@ -0,0 +1,23 @@
+package app
+
+import (
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// NewLogger создаёт логгер согласно конфигурации.
+func NewLogger(cfg *config.Config) (*logrus.Logger, error) {
+	return logger.New(logger.Options{
+		Level:  cfg.Logger.Level,
+		Format: cfg.Logger.Format,
+		Output: cfg.Logger.Output,
+		File: logger.FileOptions{
+			Path:       cfg.Logger.File.Path,
+			MaxSize:    cfg.Logger.File.MaxSize,
+			MaxBackups: cfg.Logger.File.MaxBackups,
+			MaxAge:     cfg.Logger.File.MaxAge,
+			Compress:   cfg.Logger.File.Compress,
+		},
+	})
+}
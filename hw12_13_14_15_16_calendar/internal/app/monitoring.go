@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// ServeMonitoring отдаёт метрики и пробы фонового процесса на metrics.addr в фоне, пока не отменён ctx.
+// У API-сервиса их отдаёт основной HTTP-сервер.
+func ServeMonitoring(ctx context.Context, cfg *config.Config, log *logrus.Logger, checks *health.Registry) {
+	addr := cfg.Metrics.Addr
+	if addr == "" {
+		return
+	}
+	go func() {
+		log.Infof("Serving metrics and health checks on %s", addr)
+		if err := listenAndServe(ctx, addr, monitoringHandler(checks)); err != nil {
+			log.WithError(err).Error("Monitoring server failed")
+		}
+	}()
+}
+
+func monitoringHandler(checks *health.Registry) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+metrics.Path, metrics.Handler())
+	mux.Handle("GET "+health.LivePath, health.LiveHandler())
+	mux.Handle("GET "+health.ReadyPath, checks.ReadyHandler())
+	return mux
+}
+
+// listenAndServe обслуживает handler на addr, пока не отменён ctx.
+func listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package app
+
+import (
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/queue"
+	"github.com/sirupsen/logrus"
+)
+
+// NewQueue подключается к очереди уведомлений согласно конфигурации.
+func NewQueue(cfg *config.Config, log *logrus.Logger) (*queue.AMQP, error) {
+	return queue.NewAMQP(queue.AMQPConfig{
+		URL:             cfg.Queue.URL,
+		Exchange:        cfg.Queue.Exchange,
+		Queue:           cfg.Queue.Queue,
+		DeadLetterQueue: cfg.Queue.DeadLetterQueue,
+		RoutingKey:      cfg.Queue.RoutingKey,
+		Prefetch:        cfg.Queue.Prefetch,
+		ReconnectDelay:  cfg.Queue.ReconnectDelay,
+	}, log)
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/config"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+	sqlstorage "github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/sql"
+	sqlitestorage "github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/sqlite"
+	"github.com/sirupsen/logrus"
+)
+
+// NewStorage создаёт хранилище согласно конфигурации, подключает к нему метрики
+// и регистрирует в checks проверки готовности, если хранилищу есть что проверять.
+// Используется API-сервисом и фоновыми процессами.
+func NewStorage(
+	ctx context.Context, cfg *config.Config, log *logrus.Logger, checks *health.Registry,
+) (storage.Storage, error) {
+	s, err := openStorage(ctx, cfg, log, checks)
+	if err != nil {
+		return nil, err
+	}
+	return metrics.InstrumentStorage(s, string(cfg.Storage.Type)), nil
+}
+
+func openStorage(
+	ctx context.Context, cfg *config.Config, log *logrus.Logger, checks *health.Registry,
+) (storage.Storage, error) {
+	switch cfg.Storage.Type {
+	case config.InMemory:
+		mem := cfg.Storage.InMemory
+		if mem.Dir == "" {
+			return inmemory.New(), nil
+		}
+		s, err := inmemory.Open(mem.Dir, inmemory.Options{
+			Sync:             inmemory.SyncPolicy(mem.Fsync),
+			SyncInterval:     mem.FsyncInterval,
+			SnapshotInterval: mem.SnapshotInterval,
+		}, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open in-memory storage: %w", err)
+		}
+		return s, nil
+	case config.SQL:
+		s, err := sqlstorage.New(ctx, cfg.Storage.SQL.DSN, SQLOptions(cfg), log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init SQL storage: %w", err)
+		}
+		checks.Register("storage", s.Ping)
+		checks.Register("migrations", s.CheckSchema)
+		return s, nil
+	case config.SQLite:
+		s, err := sqlitestorage.New(ctx, cfg.Storage.SQLite.Path, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init SQLite storage: %w", err)
+		}
+		checks.Register("storage", s.Ping)
+		checks.Register("migrations", s.CheckSchema)
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Storage.Type)
+	}
+}
+
+// SQLOptions возвращает параметры подключения к Postgres из конфигурации.
+func SQLOptions(cfg *config.Config) sqlstorage.Options {
+	sql := cfg.Storage.SQL
+	return sqlstorage.Options{
+		ConnectTimeout:  sql.ConnectTimeout,
+		MaxOpenConns:    sql.MaxOpenConns,
+		MaxIdleConns:    sql.MaxIdleConns,
+		ConnMaxLifetime: sql.ConnMaxLifetime,
+		ConnMaxIdleTime: sql.ConnMaxIdleTime,
+	}
+}
@@ -0,0 +1,151 @@
+// Package health — проверки живости и готовности сервисов календаря.
+// Процесс регистрирует в Registry проверки своих зависимостей (хранилище, очередь),
+// а HTTP-сервер отдаёт их результат на ReadyPath для проб Kubernetes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// LivePath — проба живости: процесс запущен и обслуживает HTTP.
+	LivePath = "/healthz"
+	// ReadyPath — проба готовности: все зарегистрированные проверки прошли.
+	ReadyPath = "/readyz"
+)
+
+// DefaultTimeout — время на одну проверку, если Registry создан без таймаута.
+const DefaultTimeout = 2 * time.Second
+
+// Значения Report.Status и Result.Status.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check проверяет одну зависимость; nil — зависимость доступна.
+type Check func(ctx context.Context) error
+
+// Result — результат одной проверки.
+type Result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report — результат всех проверок; Status — StatusOK, только если прошли все.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Registry — набор именованных проверок готовности. Безопасен для конкурентного использования.
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// NewRegistry создаёт пустой набор проверок; timeout ограничивает каждую проверку
+// (0 — DefaultTimeout).
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Registry{timeout: timeout, checks: make(map[string]Check)}
+}
+
+// Register добавляет проверку name; проверка с тем же именем заменяется.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// Run выполняет все проверки параллельно, каждую не дольше таймаута Registry.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]Result, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := r.run(ctx, check)
+			mu.Lock()
+			results[name] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// run выполняет проверку с таймаутом. Проверка, не уважающая ctx, считается
+// проваленной по истечении таймаута; её горутина завершится сама.
+func (r *Registry) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		return Result{Status: StatusFail, Error: err.Error()}
+	}
+	return Result{Status: StatusOK}
+}
+
+// LiveHandler отвечает 200, пока процесс способен обслуживать запросы.
+// Зависимости не проверяет: их недоступность не повод перезапускать процесс.
+func LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+// ReadyHandler отвечает 200, если прошли все проверки, иначе 503; в теле — результат каждой проверки.
+func (r *Registry) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeReport(w, code, report)
+	})
+}
+
+func writeReport(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
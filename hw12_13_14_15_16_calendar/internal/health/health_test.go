@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Run(t *testing.T) {
+	r := NewRegistry(50 * time.Millisecond)
+	report := r.Run(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Empty(t, report.Checks)
+
+	r.Register("storage", func(context.Context) error { return nil })
+	report = r.Run(context.Background())
+	assert.Equal(t, Report{Status: StatusOK, Checks: map[string]Result{"storage": {Status: StatusOK}}}, report)
+
+	r.Register("queue", func(context.Context) error { return errors.New("connection is closed") })
+	// Проверка, которая не уважает ctx, прерывается по таймауту
+	r.Register("stuck", func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	start := time.Now()
+	report = r.Run(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, Result{Status: StatusOK}, report.Checks["storage"])
+	assert.Equal(t, Result{Status: StatusFail, Error: "connection is closed"}, report.Checks["queue"])
+	assert.Equal(t, Result{Status: StatusFail, Error: context.DeadlineExceeded.Error()}, report.Checks["stuck"])
+}
+
+func TestHandlers(t *testing.T) {
+	r := NewRegistry(0)
+	healthy := true
+	r.Register("storage", func(context.Context) error {
+		if healthy {
+			return nil
+		}
+		return errors.New("connection refused")
+	})
+
+	get := func(h http.Handler) (int, Report) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		var report Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec.Code, report
+	}
+
+	code, report := get(r.ReadyHandler())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Checks["storage"].Status)
+
+	healthy = false
+	code, report = get(r.ReadyHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "connection refused", report.Checks["storage"].Error)
+
+	// Живость не зависит от зависимостей
+	code, report = get(LiveHandler())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+}
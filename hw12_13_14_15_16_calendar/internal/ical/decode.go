@@ -0,0 +1,408 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+)
+
+var (
+	ErrMalformed       = errors.New("malformed iCalendar data")
+	ErrUnknownTimezone = errors.New("unknown timezone")
+)
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+	utcLayout      = "20060102T150405Z"
+)
+
+// property — строка содержимого NAME;PARAM=VALUE:value.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// component — блок BEGIN:NAME ... END:NAME.
+type component struct {
+	name       string
+	props      []property
+	components []*component
+}
+
+func (c *component) prop(name string) (property, bool) {
+	for _, p := range c.props {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return property{}, false
+}
+
+// Decode читает VCALENDAR и возвращает события из всех VEVENT.
+// Время без часового пояса (floating) и даты без времени трактуются в loc (nil — UTC).
+// TZID берётся из базы IANA, а если такого пояса в ней нет — из VTIMEZONE файла.
+// Отменённые события (STATUS:CANCELLED) пропускаются.
+func Decode(r io.Reader, loc *time.Location) ([]storage.Event, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	calendars, err := parse(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []storage.Event
+	for _, cal := range calendars {
+		d := decoder{loc: loc, zones: timezones(cal)}
+		n := 0
+		for _, c := range cal.components {
+			if c.name != "VEVENT" {
+				continue
+			}
+			n++
+			if p, ok := c.prop("STATUS"); ok && strings.EqualFold(p.value, "CANCELLED") {
+				continue
+			}
+			e, err := d.event(c)
+			if err != nil {
+				uid, _ := c.prop("UID")
+				return nil, fmt.Errorf("VEVENT #%d (UID %q): %w", n, uid.value, err)
+			}
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// unfold склеивает перенесённые строки (RFC 5545, 3.1).
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, sc.Err()
+}
+
+// parse строит дерево компонентов; возвращает компоненты верхнего уровня (VCALENDAR).
+func parse(lines []string) ([]*component, error) {
+	var roots []*component
+	var stack []*component
+	for n, line := range lines {
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrMalformed, n+1, err)
+		}
+		switch p.name {
+		case "BEGIN":
+			c := &component{name: strings.ToUpper(p.value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.components = append(parent.components, c)
+			} else {
+				roots = append(roots, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrMalformed, n+1, p.value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: property outside of component", ErrMalformed, n+1)
+			}
+			c := stack[len(stack)-1]
+			c.props = append(c.props, p)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing END:%s", ErrMalformed, stack[len(stack)-1].name)
+	}
+	if len(roots) == 0 || roots[0].name != "VCALENDAR" {
+		return nil, fmt.Errorf("%w: VCALENDAR not found", ErrMalformed)
+	}
+	return roots, nil
+}
+
+// parseLine разбирает строку NAME *(;PARAM=VALUE) : value. Значения параметров
+// могут быть в кавычках и тогда содержать ':' и ';'.
+func parseLine(line string) (property, error) {
+	p := property{params: make(map[string]string)}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, fmt.Errorf("no property name in %q", line)
+	}
+	p.name = strings.ToUpper(line[:i])
+
+	rest := line[i:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return p, fmt.Errorf("bad parameter in property %s", p.name)
+		}
+		key := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("unterminated quote in property %s", p.name)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			j := strings.IndexAny(rest, ";:")
+			if j < 0 {
+				return p, fmt.Errorf("no value in property %s", p.name)
+			}
+			value, rest = rest[:j], rest[j:]
+		}
+		p.params[key] = value
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return p, fmt.Errorf("no value in property %s", p.name)
+	}
+	p.value = rest[1:]
+	return p, nil
+}
+
+// timezones собирает VTIMEZONE файла: TZID → смещение из STANDARD (или DAYLIGHT).
+// Правила перехода на летнее время не поддерживаются — это запасной вариант
+// для поясов, которых нет в базе IANA (например, из Outlook).
+func timezones(cal *component) map[string]*time.Location {
+	zones := make(map[string]*time.Location)
+	for _, c := range cal.components {
+		if c.name != "VTIMEZONE" {
+			continue
+		}
+		tzid, ok := c.prop("TZID")
+		if !ok {
+			continue
+		}
+		var offset string
+		for _, sub := range c.components {
+			if p, ok := sub.prop("TZOFFSETTO"); ok && (offset == "" || sub.name == "STANDARD") {
+				offset = p.value
+			}
+		}
+		if seconds, err := parseOffset(offset); err == nil {
+			zones[tzid.value] = time.FixedZone(tzid.value, seconds)
+		}
+	}
+	return zones
+}
+
+// parseOffset разбирает смещение вида +0300 или -053000.
+func parseOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("bad UTC offset %q", s)
+	}
+	h, err1 := strconv.Atoi(s[1:3])
+	m, err2 := strconv.Atoi(s[3:5])
+	sec := 0
+	var err3 error
+	if len(s) == 7 {
+		sec, err3 = strconv.Atoi(s[5:7])
+	}
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return 0, fmt.Errorf("bad UTC offset %q", s)
+	}
+	total := h*3600 + m*60 + sec
+	if s[0] == '-' {
+		total = -total
+	}
+	return total, nil
+}
+
+type decoder struct {
+	loc   *time.Location
+	zones map[string]*time.Location
+}
+
+func (d decoder) event(c *component) (storage.Event, error) {
+	var e storage.Event
+	if p, ok := c.prop("UID"); ok {
+		e.ID = p.value
+	}
+	if p, ok := c.prop("SUMMARY"); ok {
+		e.Title = unescape(p.value)
+	}
+	if p, ok := c.prop("DESCRIPTION"); ok {
+		e.Description = unescape(p.value)
+	}
+
+	start, ok := c.prop("DTSTART")
+	if !ok {
+		return e, errors.New("DTSTART is required")
+	}
+	var allDay bool
+	var err error
+	e.DateTime, allDay, err = d.time(start.value, start.params)
+	if err != nil {
+		return e, fmt.Errorf("DTSTART: %w", err)
+	}
+
+	switch {
+	case hasProp(c, "DURATION"):
+		p, _ := c.prop("DURATION")
+		dur, err := parseDuration(p.value)
+		if err != nil {
+			return e, fmt.Errorf("DURATION: %w", err)
+		}
+		e.Duration = int64(dur / time.Second)
+	case hasProp(c, "DTEND"):
+		p, _ := c.prop("DTEND")
+		end, _, err := d.time(p.value, p.params)
+		if err != nil {
+			return e, fmt.Errorf("DTEND: %w", err)
+		}
+		e.Duration = int64(end.Sub(e.DateTime) / time.Second)
+	case allDay:
+		e.Duration = 24 * 60 * 60
+	}
+
+	if p, ok := c.prop("RRULE"); ok {
+		e.RRule = p.value
+	}
+	for _, p := range c.props {
+		if p.name != "EXDATE" {
+			continue
+		}
+		for _, v := range strings.Split(p.value, ",") {
+			t, _, err := d.time(v, p.params)
+			if err != nil {
+				return e, fmt.Errorf("EXDATE: %w", err)
+			}
+			e.ExDates = append(e.ExDates, t)
+		}
+	}
+
+	for _, alarm := range c.components {
+		if alarm.name != "VALARM" {
+			continue
+		}
+		before, ok, err := d.alarm(alarm, e.DateTime)
+		if err != nil {
+			return e, fmt.Errorf("VALARM: %w", err)
+		}
+		if ok {
+			e.NotifyBefore = before
+			break
+		}
+	}
+	return e, nil
+}
+
+// alarm возвращает, за сколько секунд до начала события срабатывает напоминание.
+// Напоминания после начала и относительно окончания события не поддерживаются.
+func (d decoder) alarm(c *component, start time.Time) (int64, bool, error) {
+	p, ok := c.prop("TRIGGER")
+	if !ok || strings.EqualFold(p.params["RELATED"], "END") {
+		return 0, false, nil
+	}
+	var before time.Duration
+	if strings.EqualFold(p.params["VALUE"], "DATE-TIME") {
+		t, _, err := d.time(p.value, p.params)
+		if err != nil {
+			return 0, false, err
+		}
+		before = start.Sub(t)
+	} else {
+		dur, err := parseDuration(p.value)
+		if err != nil {
+			return 0, false, err
+		}
+		before = -dur
+	}
+	if before < 0 {
+		return 0, false, nil
+	}
+	return int64(before / time.Second), true, nil
+}
+
+// time разбирает DATE или DATE-TIME с учётом параметров TZID и VALUE.
+func (d decoder) time(value string, params map[string]string) (time.Time, bool, error) {
+	loc := d.loc
+	if tzid, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		} else if l, ok := d.zones[tzid]; ok {
+			loc = l
+		} else {
+			return time.Time{}, false, fmt.Errorf("%w %q", ErrUnknownTimezone, tzid)
+		}
+	}
+
+	switch {
+	case strings.EqualFold(params["VALUE"], "DATE") || len(value) == len(dateLayout):
+		t, err := time.ParseInLocation(dateLayout, value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse(utcLayout, value)
+		return t, false, err
+	default:
+		t, err := time.ParseInLocation(dateTimeLayout, value, loc)
+		return t, false, err
+	}
+}
+
+var durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration разбирает длительность RFC 5545, например -PT15M или P1DT2H.
+func parseDuration(s string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("bad duration %q", s)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var total time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		total += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		total = -total
+	}
+	return total, nil
+}
+
+func hasProp(c *component, name string) bool {
+	_, ok := c.prop(name)
+	return ok
+}
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, `;`, `\,`, `,`, `\n`, "\n", `\N`, "\n")
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,237 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+)
+
+// ProdID — идентификатор продукта в экспортируемых календарях.
+const ProdID = "-//OtusGolangHW//Calendar//RU"
+
+// MaxExportSpan — наибольшая длина периода экспорта: экземпляры собираются
+// помесячными запросами к хранилищу, их число должно быть ограничено.
+const MaxExportSpan = 5 * 366 * 24 * time.Hour
+
+// maxLineOctets — максимальная длина строки до переноса (RFC 5545, 3.1).
+const maxLineOctets = 75
+
+// zoneYears — на сколько лет от первого DTSTART VTIMEZONE описывает переходы пояса.
+// Дальше клиенты берут правила по имени пояса из базы IANA.
+const zoneYears = 10
+
+// Encode пишет события в формате VCALENDAR. Время записывается в UTC, кроме повторяющихся
+// событий не в UTC: BYDAY и BYMONTHDAY разворачиваются в поясе DTSTART, поэтому их DTSTART
+// и EXDATE пишутся с TZID, а пояс описывается в VTIMEZONE.
+// Напоминание NotifyBefore — VALARM с относительным TRIGGER.
+func Encode(w io.Writer, events []storage.Event) error {
+	bw := bufio.NewWriter(w)
+	enc := encoder{w: bw}
+	stamp := time.Now().UTC().Format(utcLayout)
+
+	enc.line("BEGIN", "VCALENDAR")
+	enc.line("VERSION", "2.0")
+	enc.line("PRODID", ProdID)
+	enc.line("CALSCALE", "GREGORIAN")
+
+	// Пояс описывается с самого раннего начала событий в нём
+	var zoneIDs []string
+	zoneStarts := make(map[string]time.Time)
+	for _, e := range events {
+		if !localTime(e) {
+			continue
+		}
+		id := tzid(e.DateTime)
+		if from, ok := zoneStarts[id]; !ok {
+			zoneIDs = append(zoneIDs, id)
+			zoneStarts[id] = e.DateTime
+		} else if e.DateTime.Before(from) {
+			zoneStarts[id] = e.DateTime
+		}
+	}
+	for _, id := range zoneIDs {
+		enc.timezone(id, zoneStarts[id])
+	}
+
+	for _, e := range events {
+		enc.line("BEGIN", "VEVENT")
+		enc.line("UID", e.ID)
+		enc.line("DTSTAMP", stamp)
+		local := localTime(e)
+		if local {
+			enc.line("DTSTART;TZID="+paramValue(tzid(e.DateTime)), e.DateTime.Format(dateTimeLayout))
+		} else {
+			enc.line("DTSTART", e.DateTime.UTC().Format(utcLayout))
+		}
+		enc.line("DURATION", formatDuration(time.Duration(e.Duration)*time.Second))
+		enc.line("SUMMARY", escape(e.Title))
+		if e.Description != "" {
+			enc.line("DESCRIPTION", escape(e.Description))
+		}
+		if e.RRule != "" {
+			enc.line("RRULE", e.RRule)
+		}
+		if len(e.ExDates) > 0 {
+			dates := make([]string, 0, len(e.ExDates))
+			for _, t := range e.ExDates {
+				if local {
+					dates = append(dates, t.In(e.DateTime.Location()).Format(dateTimeLayout))
+				} else {
+					dates = append(dates, t.UTC().Format(utcLayout))
+				}
+			}
+			if local {
+				enc.line("EXDATE;TZID="+paramValue(tzid(e.DateTime)), strings.Join(dates, ","))
+			} else {
+				enc.line("EXDATE", strings.Join(dates, ","))
+			}
+		}
+		if e.NotifyBefore > 0 {
+			enc.line("BEGIN", "VALARM")
+			enc.line("ACTION", "DISPLAY")
+			enc.line("DESCRIPTION", escape(e.Title))
+			enc.line("TRIGGER", formatDuration(-time.Duration(e.NotifyBefore)*time.Second))
+			enc.line("END", "VALARM")
+		}
+		enc.line("END", "VEVENT")
+	}
+	enc.line("END", "VCALENDAR")
+
+	if enc.err != nil {
+		return enc.err
+	}
+	return bw.Flush()
+}
+
+// localTime сообщает, нужно ли писать время события в его поясе, а не в UTC.
+func localTime(e storage.Event) bool {
+	name, offset := storage.ZoneOf(e.DateTime)
+	return e.IsRecurring() && name != "UTC" && (name != "" || offset != 0)
+}
+
+// tzid возвращает TZID пояса t: имя пояса, а для смещения без имени — вида UTC+03:00.
+func tzid(t time.Time) string {
+	if name, _ := storage.ZoneOf(t); name != "" {
+		return name
+	}
+	return "UTC" + t.Format("-07:00")
+}
+
+// paramValue заключает значение параметра в кавычки, если в нём есть разделители.
+func paramValue(s string) string {
+	if strings.ContainsAny(s, ":;,") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line пишет строку NAME:value, перенося её по 75 октетов без разрыва символов UTF-8.
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	s := name + ":" + value
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, e.err = e.w.WriteString(s[:cut] + "\r\n "); e.err != nil {
+			return
+		}
+		// Продолжение начинается с пробела, который тоже занимает октет
+		s, limit = s[cut:], maxLineOctets-1
+	}
+	_, e.err = e.w.WriteString(s + "\r\n")
+}
+
+// timezone пишет VTIMEZONE пояса from: переходы с периода, в который попадает from,
+// на zoneYears вперёд. DTSTART перехода — местное время до него (RFC 5545, 3.6.5).
+func (e *encoder) timezone(id string, from time.Time) {
+	e.line("BEGIN", "VTIMEZONE")
+	e.line("TZID", id)
+	limit := from.AddDate(zoneYears, 0, 0)
+	t := from
+	for {
+		start, end := t.ZoneBounds()
+		_, offsetTo := t.Zone()
+		offsetFrom, begin := offsetTo, "19700101T000000"
+		if !start.IsZero() {
+			_, offsetFrom = start.Add(-time.Second).Zone()
+			begin = start.UTC().Add(time.Duration(offsetFrom) * time.Second).Format(dateTimeLayout)
+		}
+		kind := "STANDARD"
+		if t.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		e.line("BEGIN", kind)
+		e.line("DTSTART", begin)
+		e.line("TZOFFSETFROM", formatOffset(offsetFrom))
+		e.line("TZOFFSETTO", formatOffset(offsetTo))
+		e.line("END", kind)
+		if end.IsZero() || end.After(limit) {
+			break
+		}
+		t = end
+	}
+	e.line("END", "VTIMEZONE")
+}
+
+// formatOffset записывает смещение от UTC в виде +hhmm или +hhmmss.
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	s := fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
+
+// formatDuration записывает длительность в виде [-]PnDTnHnMnS.
+func formatDuration(d time.Duration) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteByte('P')
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+	if d > 0 || days == 0 {
+		b.WriteByte('T')
+		h, m, s := d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second
+		if h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+		if s > 0 || (h == 0 && m == 0) {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,213 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(s, "\n"), "\n", "\r\n")
+}
+
+func TestRoundTrip(t *testing.T) {
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	events := []storage.Event{
+		{
+			ID: "1", Title: "Standup; daily, short", DateTime: start, Duration: 900,
+			Description:  "line one\nline two \\ " + strings.Repeat("длинное описание ", 10),
+			NotifyBefore: 600, RRule: "FREQ=DAILY;COUNT=5", ExDates: []time.Time{start.AddDate(0, 0, 2)},
+		},
+		{ID: "2", Title: "Offsite", DateTime: start.AddDate(0, 1, 0), Duration: 2*86400 + 3661},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, events))
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), maxLineOctets, "line must be folded: %q", line)
+	}
+	assert.Contains(t, buf.String(), "TRIGGER:-PT10M\r\n")
+	assert.Contains(t, buf.String(), "DURATION:P2DT1H1M1S\r\n")
+
+	decoded, err := Decode(&buf, nil)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	for i := range events {
+		assert.Equal(t, events[i].ID, decoded[i].ID)
+		assert.Equal(t, events[i].Title, decoded[i].Title)
+		assert.Equal(t, events[i].Description, decoded[i].Description)
+		assert.True(t, events[i].DateTime.Equal(decoded[i].DateTime))
+		assert.Equal(t, events[i].Duration, decoded[i].Duration)
+		assert.Equal(t, events[i].NotifyBefore, decoded[i].NotifyBefore)
+		assert.Equal(t, events[i].RRule, decoded[i].RRule)
+		assert.Len(t, decoded[i].ExDates, len(events[i].ExDates))
+	}
+}
+
+func TestRoundTrip_LocalRecurrence(t *testing.T) {
+	// Понедельник 01:00 по Москве — воскресенье по UTC; время из JSON API без имени пояса
+	monday, err := time.Parse(time.RFC3339, "2024-01-01T01:00:00+03:00")
+	require.NoError(t, err)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	events := []storage.Event{
+		{ID: "fixed", Title: "Planning", DateTime: monday, Duration: 600, RRule: "FREQ=WEEKLY;BYDAY=MO;COUNT=4",
+			ExDates: []time.Time{monday.AddDate(0, 0, 7)}},
+		{ID: "dst", Title: "Sync", DateTime: time.Date(2024, 3, 25, 10, 0, 0, 0, berlin), Duration: 600,
+			RRule: "FREQ=WEEKLY;COUNT=3"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, events))
+	out := buf.String()
+	assert.Contains(t, out, "DTSTART;TZID=\"UTC+03:00\":20240101T010000\r\n")
+	assert.Contains(t, out, "EXDATE;TZID=\"UTC+03:00\":20240108T010000\r\n")
+	assert.Contains(t, out, "DTSTART;TZID=Europe/Berlin:20240325T100000\r\n")
+	assert.Contains(t, out, "TZID:UTC+03:00\r\n")
+	// Переход на летнее время 31 марта 2024 в 02:00 по Берлину
+	assert.Contains(t, out, "BEGIN:DAYLIGHT\r\nDTSTART:20240331T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\n")
+
+	decoded, err := Decode(&buf, nil)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+
+	occurrences := decoded[0].Occurrences(monday.AddDate(0, 0, -7), monday.AddDate(0, 1, 0))
+	require.Len(t, occurrences, 3)
+	for _, occ := range occurrences {
+		assert.Equal(t, "01:00+03:00 Monday", occ.DateTime.Format("15:04Z07:00 Monday"))
+	}
+	assert.False(t, occurrences[1].DateTime.Equal(monday.AddDate(0, 0, 7)), "exdate must be excluded")
+
+	occurrences = decoded[1].Occurrences(events[1].DateTime, events[1].DateTime.AddDate(0, 1, 0))
+	require.Len(t, occurrences, 3)
+	for _, occ := range occurrences {
+		assert.Equal(t, 10, occ.DateTime.In(berlin).Hour())
+	}
+}
+
+func TestDecode_Timezones(t *testing.T) {
+	data := crlf(`
+BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VTIMEZONE
+TZID:Russian Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T000000
+TZOFFSETFROM:+0300
+TZOFFSETTO:+0300
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:iana
+DTSTART;TZID=Europe/Berlin:20240706T100000
+DTEND;TZID=Europe/Berlin:20240706T113000
+SUMMARY:IANA zone
+END:VEVENT
+BEGIN:VEVENT
+UID:outlook
+DTSTART;TZID="Russian Standard Time":20240706T100000
+DURATION:PT1H
+SUMMARY:VTIMEZONE zone
+END:VEVENT
+BEGIN:VEVENT
+UID:floating
+DTSTART:20240706T100000
+SUMMARY:Floating
+END:VEVENT
+BEGIN:VEVENT
+UID:allday
+DTSTART;VALUE=DATE:20240706
+SUMMARY:All day
+END:VEVENT
+END:VCALENDAR
+`)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	events, err := Decode(strings.NewReader(data), tokyo)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	assert.True(t, time.Date(2024, 7, 6, 8, 0, 0, 0, time.UTC).Equal(events[0].DateTime), "CEST is UTC+2")
+	assert.Equal(t, int64(5400), events[0].Duration)
+	assert.True(t, time.Date(2024, 7, 6, 7, 0, 0, 0, time.UTC).Equal(events[1].DateTime))
+	assert.True(t, time.Date(2024, 7, 6, 1, 0, 0, 0, time.UTC).Equal(events[2].DateTime), "floating uses loc")
+	assert.True(t, time.Date(2024, 7, 6, 0, 0, 0, 0, tokyo).Equal(events[3].DateTime))
+	assert.Equal(t, int64(86400), events[3].Duration)
+}
+
+func TestDecode_Alarms(t *testing.T) {
+	data := crlf(`
+BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:1
+DTSTART:20240706T100000Z
+SUMMARY:Folded
+  title
+STATUS:CONFIRMED
+BEGIN:VALARM
+TRIGGER;RELATED=END:-PT5M
+END:VALARM
+BEGIN:VALARM
+TRIGGER;VALUE=DATE-TIME:20240705T100000Z
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:2
+DTSTART:20240706T100000Z
+STATUS:CANCELLED
+END:VEVENT
+END:VCALENDAR
+`)
+	events, err := Decode(strings.NewReader(data), nil)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "cancelled events are skipped")
+	assert.Equal(t, "Folded title", events[0].Title)
+	assert.Equal(t, int64(86400), events[0].NotifyBefore)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{"not a calendar", "hello", ErrMalformed},
+		{"unbalanced", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n", ErrMalformed},
+		{"unknown tz", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=Mars/Olympus:20240101T000000\n" +
+			"END:VEVENT\nEND:VCALENDAR\n", ErrUnknownTimezone},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tc.data), nil)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	_, err := Decode(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\nEND:VEVENT\nEND:VCALENDAR\n"), nil)
+	assert.ErrorContains(t, err, "DTSTART is required")
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT15M":    15 * time.Minute,
+		"-PT10M":   -10 * time.Minute,
+		"P1W":      7 * 24 * time.Hour,
+		"P1DT2H3S": 26*time.Hour + 3*time.Second,
+		"+PT0S":    0,
+	}
+	for in, want := range tests {
+		got, err := parseDuration(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, bad := range []string{"", "P", "PT", "15M", "P1H"} {
+		_, err := parseDuration(bad)
+		assert.Error(t, err, bad)
+	}
+}
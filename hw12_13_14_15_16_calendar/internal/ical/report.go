@@ -0,0 +1,23 @@
+package ical
+
+import "time"
+
+// ImportStatus — итог импорта одного события.
+type ImportStatus string
+
+const (
+	ImportCreated  ImportStatus = "created"
+	ImportUpdated  ImportStatus = "updated"
+	ImportOK       ImportStatus = "ok" // пробный прогон: событие будет импортировано
+	ImportConflict ImportStatus = "conflict"
+	ImportInvalid  ImportStatus = "invalid"
+)
+
+// ImportResult описывает, что произошло (или произойдёт при пробном прогоне) с событием.
+type ImportResult struct {
+	ID       string
+	Title    string
+	DateTime time.Time
+	Status   ImportStatus
+	Error    string
+}
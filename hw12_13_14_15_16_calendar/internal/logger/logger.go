@@ -1,15 +1,77 @@
 package logger
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func New(level string) *logrus.Logger {
+// Options — настройки логгера.
+type Options struct {
+	Level  string // debug, info, warn, error; неизвестный уровень — info
+	Format string // text или json; пусто — text
+	Output string // stderr, stdout или file; пусто — stderr
+	File   FileOptions
+}
+
+// FileOptions — настройки файла и его ротации для Output: file.
+type FileOptions struct {
+	Path       string
+	MaxSize    int // мегабайт до ротации; 0 — 100
+	MaxBackups int // сколько старых файлов хранить; 0 — все
+	MaxAge     int // сколько дней хранить старые файлы; 0 — бессрочно
+	Compress   bool
+}
+
+// New создаёт логгер. В записи, сделанные через WithContext, добавляется request_id из контекста.
+func New(opts Options) (*logrus.Logger, error) {
 	log := logrus.New()
-	lvl, err := logrus.ParseLevel(level)
+	lvl, err := logrus.ParseLevel(opts.Level)
 	if err != nil {
 		lvl = logrus.InfoLevel
 	}
 	log.SetLevel(lvl)
-	return log
+
+	switch opts.Format {
+	case "", "text":
+	case "json":
+		log.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	out, err := output(opts)
+	if err != nil {
+		return nil, err
+	}
+	log.SetOutput(out)
+	log.AddHook(requestIDHook{})
+	return log, nil
+}
+
+// output по умолчанию — stderr: stdout занимают команды вроде `ical export`.
+func output(opts Options) (io.Writer, error) {
+	switch opts.Output {
+	case "", "stderr":
+		return os.Stderr, nil
+	case "stdout":
+		return os.Stdout, nil
+	case "file":
+		if opts.File.Path == "" {
+			return nil, fmt.Errorf("log file path is required")
+		}
+		return &lumberjack.Logger{
+			Filename:   opts.File.Path,
+			MaxSize:    opts.File.MaxSize,
+			MaxBackups: opts.File.MaxBackups,
+			MaxAge:     opts.File.MaxAge,
+			Compress:   opts.File.Compress,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown log output %q", opts.Output)
+	}
 }
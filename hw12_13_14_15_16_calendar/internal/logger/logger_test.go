@@ -2,32 +2,53 @@ package logger
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew_ValidLevel(t *testing.T) {
-	log := New("debug")
+	log, err := New(Options{Level: "debug"})
+	require.NoError(t, err)
 	assert.Equal(t, logrus.DebugLevel, log.GetLevel())
 }
 
 func TestNew_InvalidLevel(t *testing.T) {
 	t.Parallel()
-	log := New("invalid-level")
+	log, err := New(Options{Level: "invalid-level"})
+	require.NoError(t, err)
 	assert.Equal(t, logrus.InfoLevel, log.GetLevel()) // fallback to info
 }
 
 func TestNew_EmptyLevel(t *testing.T) {
 	t.Parallel()
-	log := New("")
+	log, err := New(Options{})
+	require.NoError(t, err)
 	assert.Equal(t, logrus.InfoLevel, log.GetLevel())
+	assert.Equal(t, os.Stderr, log.Out)
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	t.Parallel()
+	_, err := New(Options{Format: "xml"})
+	assert.ErrorContains(t, err, `unknown log format "xml"`)
+	_, err = New(Options{Output: "syslog"})
+	assert.ErrorContains(t, err, `unknown log output "syslog"`)
+	_, err = New(Options{Output: "file"})
+	assert.ErrorContains(t, err, "log file path is required")
 }
 
 func TestLogger_LogMethods(t *testing.T) {
 	t.Parallel()
-	log := New("debug")
+	log, err := New(Options{Level: "debug"})
+	require.NoError(t, err)
 
 	// Просто убедимся, что не падает
 	log.Debug("debug message")
@@ -52,3 +73,51 @@ func TestLogger_Output(t *testing.T) {
 	assert.Contains(t, output, "level=info")
 	assert.Contains(t, output, "msg=\"test message\"")
 }
+
+func TestLogger_JSONWithRequestID(t *testing.T) {
+	log, err := New(Options{Format: "json"})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	log.WithContext(ctx).WithField("event_id", "42").Error("Request failed")
+	// Без контекста ID не добавляется, а записи с контекстом не влияют друг на друга
+	log.Info("Shutting down")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "req-1", entry[RequestIDField])
+	assert.Equal(t, "42", entry["event_id"])
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "Request failed", entry["msg"])
+
+	entry = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.NotContains(t, entry, RequestIDField)
+}
+
+func TestLogger_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.log")
+	log, err := New(Options{Output: "file", File: FileOptions{Path: path, MaxSize: 1}})
+	require.NoError(t, err)
+
+	log.Info("to file")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `msg="to file"`)
+}
+
+func TestEnsureRequestID(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "abc-123", EnsureRequestID("abc-123"))
+
+	for _, id := range []string{"", "has space", "line\nbreak", "кириллица", strings.Repeat("x", 129)} {
+		got := EnsureRequestID(id)
+		assert.NotEqual(t, id, got)
+		assert.Len(t, got, 36, "uuid expected instead of %q", id)
+	}
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDField — поле записи с ID запроса.
+const RequestIDField = "request_id"
+
+// maxRequestIDLen ограничивает длину ID, полученного от клиента.
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// EnsureRequestID возвращает полученный от клиента ID или, если его нет или он некорректен, новый.
+// Принимаются только печатные ASCII-символы без пробелов, чтобы чужой ID не ломал строки лога.
+func EnsureRequestID(id string) string {
+	if id == "" || len(id) > maxRequestIDLen {
+		return uuid.NewString()
+	}
+	for i := range len(id) {
+		if id[i] <= ' ' || id[i] > '~' {
+			return uuid.NewString()
+		}
+	}
+	return id
+}
+
+// WithRequestID возвращает контекст с ID запроса.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает ID запроса из контекста или пустую строку.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDHook добавляет ID запроса в записи, у которых есть контекст,
+// поэтому обработчикам достаточно писать log.WithContext(ctx).
+type requestIDHook struct{}
+
+func (requestIDHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (requestIDHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if id := RequestID(entry.Context); id != "" {
+		entry.Data[RequestIDField] = id
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Package metrics — метрики Prometheus сервисов календаря.
+// Метрики регистрируются в реестре по умолчанию вместе с метриками Go-рантайма и процесса.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "calendar"
+
+// Path — путь, по которому отдаются метрики.
+const Path = "/metrics"
+
+var (
+	// HTTPRequests — число HTTP-запросов по методу, шаблону маршрута и коду ответа.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	// HTTPDuration — время обработки HTTP-запросов.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// GRPCRequests — число GRPC-вызовов по методу и коду статуса.
+	GRPCRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "GRPC calls by method and status code.",
+	}, []string{"method", "code"})
+	// GRPCDuration — время обработки GRPC-вызовов.
+	GRPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "GRPC call latency by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// StorageDuration — время операций хранилища.
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Storage operation latency by backend and operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"backend", "operation"})
+	// StorageErrors — число отказов хранилища. Ошибки из-за данных запроса
+	// (событие не найдено, время занято и т.п.) сюда не входят.
+	StorageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "errors_total",
+		Help:      "Storage failures by backend and operation, excluding client errors.",
+	}, []string{"backend", "operation"})
+
+	// SchedulerLag — насколько начало ещё не просканированного окна уведомлений
+	// отстаёт от текущего времени. Растёт, если сканирование завершается ошибками.
+	SchedulerLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "lag_seconds",
+		Help:      "Age of the oldest notification time not yet scanned by the scheduler.",
+	})
+	// SchedulerPublished — число отправленных в очередь уведомлений.
+	SchedulerPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "notifications_published_total",
+		Help:      "Notifications published to the queue.",
+	})
+	// SchedulerErrors — число неудачных сканирований и очисток.
+	SchedulerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "errors_total",
+		Help:      "Failed scheduler runs by task (scan, cleanup).",
+	}, []string{"task"})
+
+	// SenderQueueLag — время от публикации уведомления до его получения рассыльщиком.
+	SenderQueueLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sender",
+		Name:      "queue_lag_seconds",
+		Help:      "Time between publishing a notification and receiving it from the queue.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	})
+	// SenderNotifications — число обработанных уведомлений по результату (delivered, retried, dead_lettered).
+	SenderNotifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sender",
+		Name:      "notifications_total",
+		Help:      "Processed notifications by result (delivered, retried, dead_lettered).",
+	}, []string{"result"})
+)
+
+// Handler отдаёт метрики в формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+)
+
+// Storage измеряет время и ошибки операций хранилища.
+type Storage struct {
+	storage.Storage
+	backend string
+}
+
+// InstrumentStorage оборачивает хранилище; backend — значение метки backend (inmemory, sql, sqlite).
+func InstrumentStorage(s storage.Storage, backend string) *Storage {
+	return &Storage{Storage: s, backend: backend}
+}
+
+// observe записывает время операции, начатой в start, и её ошибку.
+func (s *Storage) observe(op string, start time.Time, err error) {
+	StorageDuration.WithLabelValues(s.backend, op).Observe(time.Since(start).Seconds())
+	if isFailure(err) {
+		StorageErrors.WithLabelValues(s.backend, op).Inc()
+	}
+}
+
+// isFailure отличает отказ хранилища от ошибок, вызванных данными запроса или отменой запроса клиентом.
+func isFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, storage.ErrEventNotFound),
+		errors.Is(err, storage.ErrEventExists),
+		errors.Is(err, storage.ErrDateBusy),
+		errors.Is(err, storage.ErrInvalidEvent),
+		errors.Is(err, storage.ErrInvalidQuery),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+func (s *Storage) Add(ctx context.Context, event storage.Event) error {
+	start := time.Now()
+	err := s.Storage.Add(ctx, event)
+	s.observe("add", start, err)
+	return err
+}
+
+func (s *Storage) Update(ctx context.Context, userID, id string, event storage.Event) error {
+	start := time.Now()
+	err := s.Storage.Update(ctx, userID, id, event)
+	s.observe("update", start, err)
+	return err
+}
+
+func (s *Storage) Delete(ctx context.Context, userID, id string) error {
+	start := time.Now()
+	err := s.Storage.Delete(ctx, userID, id)
+	s.observe("delete", start, err)
+	return err
+}
+
+func (s *Storage) Get(ctx context.Context, userID, id string) (storage.Event, error) {
+	start := time.Now()
+	event, err := s.Storage.Get(ctx, userID, id)
+	s.observe("get", start, err)
+	return event, err
+}
+
+func (s *Storage) ListAll(ctx context.Context, userID string) ([]storage.Event, error) {
+	start := time.Now()
+	events, err := s.Storage.ListAll(ctx, userID)
+	s.observe("list_all", start, err)
+	return events, err
+}
+
+func (s *Storage) ListDay(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start := time.Now()
+	events, err := s.Storage.ListDay(ctx, userID, date, mode)
+	s.observe("list_day", start, err)
+	return events, err
+}
+
+func (s *Storage) ListWeek(
+	ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start := time.Now()
+	events, err := s.Storage.ListWeek(ctx, userID, startDate, mode)
+	s.observe("list_week", start, err)
+	return events, err
+}
+
+func (s *Storage) ListMonth(
+	ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start := time.Now()
+	events, err := s.Storage.ListMonth(ctx, userID, startDate, mode)
+	s.observe("list_month", start, err)
+	return events, err
+}
+
+func (s *Storage) List(
+	ctx context.Context, userID string, from, to time.Time, cursor string, limit int,
+) ([]storage.Event, string, error) {
+	start := time.Now()
+	events, next, err := s.Storage.List(ctx, userID, from, to, cursor, limit)
+	s.observe("list", start, err)
+	return events, next, err
+}
+
+func (s *Storage) Search(ctx context.Context, userID, query string, limit int) ([]storage.Event, error) {
+	start := time.Now()
+	events, err := s.Storage.Search(ctx, userID, query, limit)
+	s.observe("search", start, err)
+	return events, err
+}
+
+func (s *Storage) ListToNotify(ctx context.Context, from, to time.Time) ([]storage.Event, error) {
+	start := time.Now()
+	events, err := s.Storage.ListToNotify(ctx, from, to)
+	s.observe("list_to_notify", start, err)
+	return events, err
+}
+
+func (s *Storage) DeleteBefore(ctx context.Context, t time.Time) (int, error) {
+	start := time.Now()
+	n, err := s.Storage.DeleteBefore(ctx, t)
+	s.observe("delete_before", start, err)
+	return n, err
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenStorage отказывает на любой запрос.
+type brokenStorage struct {
+	storage.Storage
+}
+
+func (brokenStorage) Get(context.Context, string, string) (storage.Event, error) {
+	return storage.Event{}, errors.New("connection refused")
+}
+
+// sampleCount возвращает число измерений операции op.
+func sampleCount(t *testing.T, backend, op string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, StorageDuration.WithLabelValues(backend, op).(prometheus.Histogram).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	s := InstrumentStorage(inmemory.New(), "test")
+	e := storage.Event{ID: "1", Title: "e", DateTime: time.Now(), Duration: 60, UserID: "user1"}
+
+	require.NoError(t, s.Add(ctx, e))
+	assert.ErrorIs(t, s.Add(ctx, e), storage.ErrEventExists)
+	_, err := s.Get(ctx, "user1", "missing")
+	assert.ErrorIs(t, err, storage.ErrEventNotFound)
+
+	assert.Equal(t, uint64(2), sampleCount(t, "test", "add"))
+	// Ошибки из-за данных запроса не считаются отказами
+	assert.Zero(t, testutil.ToFloat64(StorageErrors.WithLabelValues("test", "add")))
+	assert.Zero(t, testutil.ToFloat64(StorageErrors.WithLabelValues("test", "get")))
+
+	broken := InstrumentStorage(brokenStorage{}, "broken")
+	_, err = broken.Get(ctx, "user1", "1")
+	assert.Error(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(StorageErrors.WithLabelValues("broken", "get")))
+}
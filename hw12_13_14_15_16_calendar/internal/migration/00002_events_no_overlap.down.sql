@@ -0,0 +1,2 @@
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_no_overlap;
+ALTER TABLE events DROP COLUMN IF EXISTS ends_at;
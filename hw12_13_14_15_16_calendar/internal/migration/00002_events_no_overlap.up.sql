@@ -0,0 +1,49 @@
+-- Запрет пересекающихся по времени событий одного пользователя.
+-- Выражение datetime + interval не IMMUTABLE, поэтому окончание события
+-- хранится в отдельной колонке и вычисляется приложением.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS ends_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE events
+SET ends_at = datetime + GREATEST(duration, 1) * INTERVAL '1 second'
+WHERE ends_at IS NULL;
+
+ALTER TABLE events ALTER COLUMN ends_at SET NOT NULL;
+
+-- До этой миграции Add не проверял пересечения, поэтому в таблице уже могут быть
+-- пересекающиеся события. Они не удаляются автоматически: миграция завершается ошибкой
+-- со списком пар ID, которые нужно исправить вручную и перезапустить сервис.
+DO $$
+DECLARE
+    total BIGINT;
+    sample TEXT;
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'events_no_overlap' AND conrelid = 'events'::regclass
+    ) THEN
+        RETURN;
+    END IF;
+
+    CREATE TEMP TABLE overlapping_events ON COMMIT DROP AS
+    SELECT a.user_id, a.id AS first_id, b.id AS second_id
+    FROM events a
+    JOIN events b ON b.user_id = a.user_id AND b.id > a.id
+        AND tstzrange(b.datetime, b.ends_at) && tstzrange(a.datetime, a.ends_at);
+
+    SELECT count(*) INTO total FROM overlapping_events;
+    IF total > 0 THEN
+        SELECT string_agg(format('%s: %s & %s', user_id, first_id, second_id), ', ')
+        INTO sample
+        FROM (
+            SELECT * FROM overlapping_events ORDER BY user_id, first_id, second_id LIMIT 20
+        ) AS first_pairs;
+        RAISE EXCEPTION 'cannot add events_no_overlap: % pair(s) of overlapping events (user: id & id, first 20): %; move or delete one event of each pair and restart',
+            total, sample;
+    END IF;
+
+    ALTER TABLE events
+        ADD CONSTRAINT events_no_overlap
+        EXCLUDE USING gist (user_id WITH =, tstzrange(datetime, ends_at) WITH &&);
+END
+$$;
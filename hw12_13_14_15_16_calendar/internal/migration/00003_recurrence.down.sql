@@ -0,0 +1,10 @@
+DELETE FROM events WHERE rrule <> '';
+
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events
+    ADD CONSTRAINT events_no_overlap
+    EXCLUDE USING gist (user_id WITH =, tstzrange(datetime, ends_at) WITH &&);
+
+ALTER TABLE events DROP COLUMN last_occurrence;
+ALTER TABLE events DROP COLUMN exdates;
+ALTER TABLE events DROP COLUMN rrule;
@@ -0,0 +1,14 @@
+-- Повторяющиеся события: правило RFC 5545, отменённые экземпляры
+-- и начало последнего экземпляра (NULL — правило бесконечное).
+ALTER TABLE events ADD COLUMN rrule TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN exdates TIMESTAMP WITH TIME ZONE[] NOT NULL DEFAULT '{}';
+ALTER TABLE events ADD COLUMN last_occurrence TIMESTAMP WITH TIME ZONE;
+
+UPDATE events SET last_occurrence = datetime;
+
+-- Пересечения повторяющихся событий проверяет приложение, ограничение
+-- остаётся только для разовых.
+ALTER TABLE events DROP CONSTRAINT events_no_overlap;
+ALTER TABLE events
+    ADD CONSTRAINT events_no_overlap
+    EXCLUDE USING gist (user_id WITH =, tstzrange(datetime, ends_at) WITH &&) WHERE (rrule = '');
@@ -0,0 +1,2 @@
+-- Постраничная выборка событий пользователя по времени начала.
+CREATE INDEX IF NOT EXISTS events_user_datetime_idx ON events (user_id, datetime);
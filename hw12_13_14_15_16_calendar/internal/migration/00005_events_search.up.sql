@@ -0,0 +1,8 @@
+-- Полнотекстовый поиск по названию (вес A) и описанию (вес B).
+-- Конфигурация russian стеммит и русские, и латинские (английские) слова.
+ALTER TABLE events ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS events_search_idx ON events USING gin (search);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS events_search_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS search;
+DROP FUNCTION IF EXISTS events_search_text(TEXT);
+
+ALTER TABLE events ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS events_search_idx ON events USING gin (search);
@@ -0,0 +1,24 @@
+-- Поиск без стемминга Postgres: слова нормализуются так же, как в storage.Tokenize
+-- (латиница, кириллица и цифры, нижний регистр, ё → е, первые 5 букв), поэтому все
+-- хранилища находят одни и те же события. Функция не зависит от локали базы.
+DROP INDEX IF EXISTS events_search_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS search;
+
+CREATE OR REPLACE FUNCTION events_search_text(s TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$
+    SELECT coalesce(string_agg(left(w, 5), ' ' ORDER BY n), '')
+    FROM regexp_split_to_table(
+        translate(coalesce(s, ''),
+            'ABCDEFGHIJKLMNOPQRSTUVWXYZАБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯё',
+            'abcdefghijklmnopqrstuvwxyzабвгдеежзийклмнопрстуфхцчшщъыьэюяе'),
+        '[^0-9a-zа-я]+') WITH ORDINALITY AS t(w, n)
+    WHERE w <> ''
+$$;
+
+ALTER TABLE events ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', events_search_text(title)), 'A') ||
+    setweight(to_tsvector('simple', events_search_text(description)), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS events_search_idx ON events USING gin (search);
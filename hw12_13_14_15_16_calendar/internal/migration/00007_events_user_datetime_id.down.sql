@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS events_user_datetime_idx ON events (user_id, datetime);
+DROP INDEX IF EXISTS events_user_datetime_id_idx;
@@ -0,0 +1,5 @@
+-- Постраничная выборка с продолжением после курсора (datetime, id): сравнение id
+-- в collation "C" позволяет искать позицию курсора по индексу, а не перебирать
+-- все события пользователя с начала периода. Заменяет индекс из 00004.
+CREATE INDEX IF NOT EXISTS events_user_datetime_id_idx ON events (user_id, datetime, id COLLATE "C");
+DROP INDEX IF EXISTS events_user_datetime_idx;
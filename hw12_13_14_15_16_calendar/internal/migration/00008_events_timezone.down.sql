@@ -0,0 +1,2 @@
+ALTER TABLE events DROP COLUMN IF EXISTS tz_offset;
+ALTER TABLE events DROP COLUMN IF EXISTS tz_name;
@@ -0,0 +1,5 @@
+-- Часовой пояс события: timestamptz хранит только момент, а повторения разворачиваются
+-- в поясе начала события. Имя — из базы IANA (пусто для фиксированного смещения),
+-- смещение в секундах восстанавливает пояс без имени. Старые события остаются в UTC.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS tz_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS tz_offset INTEGER NOT NULL DEFAULT 0;
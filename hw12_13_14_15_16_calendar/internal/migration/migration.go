@@ -0,0 +1,147 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// embedded — миграции Postgres.
+//
+//go:embed *.sql
+var embedded embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// sqliteMigrations — миграции SQLite: схема та же, но без расширений Postgres.
+var sqliteMigrations, _ = fs.Sub(sqliteFiles, "sqlite")
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+)
+
+// fileRe — имя файла миграции: <версия>_<название>.<up|down>.sql.
+var fileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration — одна версия схемы БД.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 текста Up
+}
+
+// Load читает миграции из fsys и возвращает их по возрастанию версии.
+// У каждой версии должен быть файл .up.sql; .down.sql необязателен.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, name := range names {
+		m := fileRe.FindStringSubmatch(path.Base(name))
+		if m == nil {
+			return nil, fmt.Errorf("%w: unexpected file name %s", ErrInvalidMigration, name)
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: bad version in %s", ErrInvalidMigration, name)
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("%w: version %d has different names %q and %q",
+				ErrInvalidMigration, version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has no up file", ErrInvalidMigration, mig.Version)
+		}
+		sum := sha256.Sum256([]byte(mig.Up))
+		mig.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// applied — запись о применённой миграции в таблице версий.
+type applied struct {
+	Version  int64  `db:"version"`
+	Checksum string `db:"checksum"`
+}
+
+// pending возвращает ещё не применённые миграции. Если текст уже применённой
+// миграции изменился, возвращается ErrChecksumMismatch. Версии из БД, которых
+// нет среди файлов (например, после отката бинарника), пропускаются.
+func pending(migrations []Migration, done []applied) ([]Migration, error) {
+	checksums := make(map[int64]string, len(done))
+	for _, a := range done {
+		checksums[a.Version] = a.Checksum
+	}
+
+	var result []Migration
+	for _, m := range migrations {
+		sum, ok := checksums[m.Version]
+		if !ok {
+			result = append(result, m)
+			continue
+		}
+		if sum != m.Checksum {
+			return nil, fmt.Errorf("%w: version %d (%s)", ErrChecksumMismatch, m.Version, m.Name)
+		}
+	}
+	return result, nil
+}
+
+// rollback возвращает steps последних применённых миграций в порядке отката.
+func rollback(migrations []Migration, done []applied, steps int) ([]Migration, error) {
+	known := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	var result []Migration
+	for i := len(done) - 1; i >= 0 && len(result) < steps; i-- {
+		m, ok := known[done[i].Version]
+		if !ok {
+			return nil, fmt.Errorf("%w: applied version %d is unknown to this binary",
+				ErrInvalidMigration, done[i].Version)
+		}
+		if m.Down == "" {
+			return nil, fmt.Errorf("%w: version %d has no down file", ErrInvalidMigration, m.Version)
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
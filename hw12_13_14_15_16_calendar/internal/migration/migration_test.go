@@ -0,0 +1,106 @@
+package migration
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"00002_second.up.sql":   {Data: []byte("CREATE INDEX b;")},
+		"00002_second.down.sql": {Data: []byte("DROP INDEX b;")},
+		"00001_first.up.sql":    {Data: []byte("CREATE TABLE a;")},
+	}
+
+	migrations, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Empty(t, migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Equal(t, "DROP INDEX b;", migrations[1].Down)
+	assert.Len(t, migrations[1].Checksum, 64)
+	assert.NotEqual(t, migrations[0].Checksum, migrations[1].Checksum)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"bad name", fstest.MapFS{"init.sql": {}}},
+		{"zero version", fstest.MapFS{"00000_init.up.sql": {Data: []byte("x")}}},
+		{"down without up", fstest.MapFS{"00001_init.down.sql": {Data: []byte("x")}}},
+		{"name mismatch", fstest.MapFS{
+			"00001_init.up.sql":    {Data: []byte("x")},
+			"00001_other.down.sql": {Data: []byte("x")},
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.fsys)
+			assert.ErrorIs(t, err, ErrInvalidMigration)
+		})
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	for name, fsys := range map[string]fs.FS{"postgres": embedded, "sqlite": sqliteMigrations} {
+		t.Run(name, func(t *testing.T) {
+			migrations, err := Load(fsys)
+			require.NoError(t, err)
+			require.NotEmpty(t, migrations)
+			for i, m := range migrations {
+				assert.Equal(t, int64(i+1), m.Version, "versions must be sequential")
+				assert.NotEmpty(t, m.Down, "migration %d must have a down file", m.Version)
+			}
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Checksum: "a"},
+		{Version: 2, Checksum: "b"},
+		{Version: 3, Checksum: "c"},
+	}
+
+	todo, err := pending(migrations, []applied{{Version: 1, Checksum: "a"}})
+	require.NoError(t, err)
+	require.Len(t, todo, 2)
+	assert.Equal(t, int64(2), todo[0].Version)
+
+	// Версия из БД, неизвестная бинарнику, не мешает применению
+	todo, err = pending(migrations, []applied{{1, "a"}, {2, "b"}, {3, "c"}, {4, "d"}})
+	require.NoError(t, err)
+	assert.Empty(t, todo)
+
+	_, err = pending(migrations, []applied{{Version: 1, Checksum: "changed"}})
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestRollback(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Down: "d1"},
+		{Version: 2, Down: "d2"},
+		{Version: 3},
+	}
+
+	todo, err := rollback(migrations, []applied{{Version: 1}, {Version: 2}}, 5)
+	require.NoError(t, err)
+	require.Len(t, todo, 2)
+	assert.Equal(t, int64(2), todo[0].Version)
+	assert.Equal(t, int64(1), todo[1].Version)
+
+	_, err = rollback(migrations, []applied{{Version: 1}, {Version: 2}, {Version: 3}}, 1)
+	assert.ErrorIs(t, err, ErrInvalidMigration, "no down file")
+
+	_, err = rollback(migrations, []applied{{Version: 4}}, 1)
+	assert.ErrorIs(t, err, ErrInvalidMigration, "unknown version")
+}
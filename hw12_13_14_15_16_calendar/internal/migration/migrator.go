@@ -0,0 +1,266 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lockID — ключ advisory lock, под которым выполняются миграции,
+// чтобы несколько экземпляров сервиса не применяли их одновременно.
+const lockID int64 = 0x63616c656e646172 // "calendar"
+
+// dialect — то, чем СУБД различаются для Migrator: свои миграции,
+// таблица версий и блокировка на время применения.
+type dialect struct {
+	migrations    fs.FS
+	versionsTable string
+	// lock и unlock — запросы сессионной блокировки; пустые, если СУБД
+	// сама сериализует пишущие транзакции (SQLite)
+	lock, unlock string
+}
+
+var postgres = dialect{
+	migrations: embedded,
+	versionsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			checksum   TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+		)`,
+	lock:   `SELECT pg_advisory_lock($1)`,
+	unlock: `SELECT pg_advisory_unlock($1)`,
+}
+
+var sqlite = dialect{
+	migrations: sqliteMigrations,
+	versionsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			checksum   TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+}
+
+// Status — состояние одной миграции.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // файл изменился после применения
+}
+
+// Migrator применяет и откатывает миграции, учитывая версии в таблице schema_migrations.
+type Migrator struct {
+	db         *sqlx.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+// New создаёт Migrator для Postgres со встроенными в бинарник миграциями.
+func New(db *sqlx.DB) (*Migrator, error) {
+	return newMigrator(db, postgres)
+}
+
+// NewSQLite создаёт Migrator для SQLite со встроенными миграциями из каталога sqlite.
+func NewSQLite(db *sqlx.DB) (*Migrator, error) {
+	return newMigrator(db, sqlite)
+}
+
+func newMigrator(db *sqlx.DB, d dialect) (*Migrator, error) {
+	migrations, err := Load(d.migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+// Latest возвращает версию последней известной миграции.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up применяет все неприменённые миграции и возвращает их.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var result []Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn, done []applied) error {
+		todo, err := pending(m.migrations, done)
+		if err != nil {
+			return err
+		}
+		for _, mig := range todo {
+			var skipped bool
+			err := inTx(ctx, conn, func(tx *sqlx.Tx) error {
+				// Без блокировки миграцию мог применить другой процесс, пока мы читали версии
+				err := tx.GetContext(ctx, &skipped,
+					`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, mig.Version)
+				if err != nil || skipped {
+					return err
+				}
+				if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err = tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+					mig.Version, mig.Name, mig.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d (%s): %w", mig.Version, mig.Name, err)
+			}
+			if !skipped {
+				result = append(result, mig)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Down откатывает steps последних применённых миграций и возвращает их.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var result []Migration
+	err := m.withLock(ctx, func(conn *sqlx.Conn, done []applied) error {
+		todo, err := rollback(m.migrations, done, steps)
+		if err != nil {
+			return err
+		}
+		for _, mig := range todo {
+			err := inTx(ctx, conn, func(tx *sqlx.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to roll back migration %d (%s): %w", mig.Version, mig.Name, err)
+			}
+			result = append(result, mig)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Status возвращает состояние всех известных миграций и тех, что есть только в БД.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if _, err := m.db.ExecContext(ctx, m.dialect.versionsTable); err != nil {
+		return nil, fmt.Errorf("failed to create versions table: %w", err)
+	}
+	var rows []struct {
+		applied
+		Name      string    `db:"name"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	err := m.db.SelectContext(ctx, &rows,
+		`SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	result := make([]Status, 0, len(m.migrations))
+	known := make(map[int64]int, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = len(result)
+		result = append(result, Status{Version: mig.Version, Name: mig.Name})
+	}
+	for _, row := range rows {
+		i, ok := known[row.Version]
+		if !ok {
+			result = append(result, Status{Version: row.Version, Name: row.Name})
+			i = len(result) - 1
+		} else {
+			result[i].Modified = row.Checksum != m.migrations[i].Checksum
+		}
+		result[i].Applied = true
+		result[i].AppliedAt = row.AppliedAt
+	}
+	return result, nil
+}
+
+// ErrVersionMismatch — в БД применены не все миграции, известные бинарнику.
+var ErrVersionMismatch = errors.New("schema version mismatch")
+
+// Check проверяет, что в БД применены все известные миграции и их тексты не менялись.
+// Более новые версии не считаются ошибкой: во время выкладки БД уже обновил новый бинарник,
+// а старый продолжает работать, пока его не заменят.
+func (m *Migrator) Check(ctx context.Context) error {
+	var done []applied
+	err := m.db.SelectContext(ctx, &done, `SELECT version, checksum FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	todo, err := pending(m.migrations, done)
+	if err != nil {
+		return err
+	}
+	if len(todo) > 0 {
+		return fmt.Errorf("%w: %d pending migration(s), first is %d (%s)",
+			ErrVersionMismatch, len(todo), todo[0].Version, todo[0].Name)
+	}
+	return nil
+}
+
+// Version возвращает максимальную применённую версию (0 — миграций не было).
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var version sql.NullInt64
+	err := m.db.GetContext(ctx, &version, `SELECT max(version) FROM schema_migrations`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version.Int64, nil
+}
+
+// withLock выполняет fn на отдельном соединении под блокировкой миграций.
+// Блокировка привязана к сессии, поэтому все запросы идут через conn.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn, done []applied) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if m.dialect.lock != "" {
+		if _, err := conn.ExecContext(ctx, m.dialect.lock, lockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			// Снимаем блокировку даже если ctx уже отменён
+			_, _ = conn.ExecContext(context.Background(), m.dialect.unlock, lockID)
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, m.dialect.versionsTable); err != nil {
+		return fmt.Errorf("failed to create versions table: %w", err)
+	}
+	var done []applied
+	err = conn.SelectContext(ctx, &done, `SELECT version, checksum FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	return fn(conn, done)
+}
+
+func inTx(ctx context.Context, conn *sqlx.Conn, fn func(tx *sqlx.Tx) error) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,18 @@
+-- Время хранится в наносекундах Unix (UTC): SQLite сравнивает его как числа.
+-- Пересечения событий проверяет приложение в транзакции BEGIN IMMEDIATE.
+CREATE TABLE IF NOT EXISTS events (
+    id              TEXT PRIMARY KEY,
+    title           TEXT    NOT NULL,
+    datetime        INTEGER NOT NULL,
+    ends_at         INTEGER NOT NULL,
+    duration        INTEGER NOT NULL,
+    description     TEXT    NOT NULL DEFAULT '',
+    user_id         TEXT    NOT NULL,
+    notify_before   INTEGER NOT NULL DEFAULT 0,
+    rrule           TEXT    NOT NULL DEFAULT '',
+    exdates         TEXT    NOT NULL DEFAULT '[]', -- JSON-массив наносекунд
+    last_occurrence INTEGER                        -- NULL — правило бесконечное
+);
+
+CREATE INDEX IF NOT EXISTS events_user_datetime_idx ON events (user_id, datetime);
+CREATE INDEX IF NOT EXISTS events_last_occurrence_idx ON events (last_occurrence);
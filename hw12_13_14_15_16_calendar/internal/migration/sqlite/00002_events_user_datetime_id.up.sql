@@ -0,0 +1,3 @@
+-- Постраничная выборка ищет позицию курсора (datetime, id) по индексу. Заменяет events_user_datetime_idx.
+CREATE INDEX IF NOT EXISTS events_user_datetime_id_idx ON events (user_id, datetime, id);
+DROP INDEX IF EXISTS events_user_datetime_idx;
@@ -0,0 +1,2 @@
+ALTER TABLE events DROP COLUMN tz_offset;
+ALTER TABLE events DROP COLUMN tz_name;
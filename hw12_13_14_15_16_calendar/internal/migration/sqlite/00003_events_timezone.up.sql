@@ -0,0 +1,4 @@
+-- Часовой пояс события, в котором разворачиваются повторения: имя из базы IANA
+-- (пусто для фиксированного смещения) и смещение в секундах. Старые события остаются в UTC.
+ALTER TABLE events ADD COLUMN tz_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN tz_offset INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,27 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+)
+
+// Notification — уведомление о событии. В БД не хранится,
+// передаётся от планировщика рассыльщику через очередь.
+type Notification struct {
+	EventID     string    `json:"event_id"`
+	Title       string    `json:"title"`
+	DateTime    time.Time `json:"datetime"`
+	UserID      string    `json:"user_id"`
+	PublishedAt time.Time `json:"published_at"` // время отправки в очередь; старые планировщики не заполняют
+}
+
+// FromEvent строит уведомление по событию.
+func FromEvent(e storage.Event) Notification {
+	return Notification{
+		EventID:  e.ID,
+		Title:    e.Title,
+		DateTime: e.DateTime,
+		UserID:   e.UserID,
+	}
+}
@@ -0,0 +1,334 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrClosed = errors.New("queue is closed")
+
+type AMQPConfig struct {
+	URL      string
+	Exchange string
+	Queue    string
+	// DeadLetterQueue принимает сообщения, отклонённые через Nack(false).
+	DeadLetterQueue string
+	RoutingKey      string
+	Prefetch        int
+	ReconnectDelay  time.Duration
+}
+
+// AMQP — очередь поверх RabbitMQ. При старте объявляет durable exchange,
+// очередь и её dead-letter очередь, при обрыве соединения переподключается в фоне.
+// Публикация ждёт подтверждения брокера (publisher confirms).
+type AMQP struct {
+	cfg    AMQPConfig
+	logger *logrus.Logger
+
+	mu      sync.RWMutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func NewAMQP(cfg AMQPConfig, logger *logrus.Logger) (*AMQP, error) {
+	if cfg.ReconnectDelay <= 0 {
+		cfg.ReconnectDelay = 5 * time.Second
+	}
+	q := &AMQP{
+		cfg:    cfg,
+		logger: logger,
+		closed: make(chan struct{}),
+	}
+
+	connClosed, chClosed, err := q.connect()
+	if err != nil {
+		return nil, err
+	}
+	go q.reconnectLoop(connClosed, chClosed)
+	return q, nil
+}
+
+// connect устанавливает соединение и объявляет топологию. Возвращает каналы
+// уведомлений о закрытии соединения и канала AMQP.
+func (q *AMQP) connect() (connClosed, chClosed chan *amqp.Error, err error) {
+	conn, err := amqp.Dial(q.cfg.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to AMQP: %w", err)
+	}
+
+	q.mu.Lock()
+	q.conn = conn
+	q.mu.Unlock()
+
+	if chClosed, err = q.openChannel(conn); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	return conn.NotifyClose(make(chan *amqp.Error, 1)), chClosed, nil
+}
+
+// openChannel открывает канал, объявляет на нём топологию и делает его текущим.
+func (q *AMQP) openChannel(conn *amqp.Connection) (chan *amqp.Error, error) {
+	ch, err := q.declare(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	q.channel = ch
+	q.mu.Unlock()
+
+	return ch.NotifyClose(make(chan *amqp.Error, 1)), nil
+}
+
+func (q *AMQP) declare(conn *amqp.Connection) (*amqp.Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	if err := ch.ExchangeDeclare(q.cfg.Exchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare exchange %q: %w", q.cfg.Exchange, err)
+	}
+	// Отклонённые сообщения попадают в DeadLetterQueue через exchange по умолчанию
+	if _, err := ch.QueueDeclare(q.cfg.DeadLetterQueue, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare queue %q: %w", q.cfg.DeadLetterQueue, err)
+	}
+	args := amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": q.cfg.DeadLetterQueue,
+	}
+	if _, err := ch.QueueDeclare(q.cfg.Queue, true, false, false, false, args); err != nil {
+		return nil, fmt.Errorf("failed to declare queue %q: %w", q.cfg.Queue, err)
+	}
+	if err := ch.QueueBind(q.cfg.Queue, q.cfg.RoutingKey, q.cfg.Exchange, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to bind queue %q: %w", q.cfg.Queue, err)
+	}
+	if err := ch.Qos(q.cfg.Prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return ch, nil
+}
+
+// reconnectLoop восстанавливает канал, если брокер закрыл только его
+// (например, после ошибки на канале), и соединение — если оборвалось оно.
+func (q *AMQP) reconnectLoop(connClosed, chClosed chan *amqp.Error) {
+	for {
+		select {
+		case <-q.closed:
+			return
+		case amqpErr := <-connClosed:
+			if q.isClosed() {
+				// Соединение закрыто через Close
+				return
+			}
+			q.logger.WithField("reason", amqpErr).Warn("AMQP connection lost, reconnecting")
+		case amqpErr := <-chClosed:
+			if q.isClosed() {
+				return
+			}
+			q.logger.WithField("reason", amqpErr).Warn("AMQP channel closed, reopening")
+			var err error
+			if chClosed, err = q.reopenChannel(); err == nil {
+				q.logger.Info("AMQP channel restored")
+				continue
+			}
+			q.logger.WithError(err).Warn("Failed to reopen AMQP channel, reconnecting")
+		}
+
+		// Если не удалось открыть канал, соединение ещё живо: закрываем его перед переподключением
+		q.mu.RLock()
+		_ = q.conn.Close()
+		q.mu.RUnlock()
+
+		for {
+			select {
+			case <-q.closed:
+				return
+			case <-time.After(q.cfg.ReconnectDelay):
+			}
+
+			var err error
+			if connClosed, chClosed, err = q.connect(); err != nil {
+				q.logger.WithError(err).Warn("AMQP reconnect failed")
+				continue
+			}
+			q.logger.Info("AMQP connection restored")
+			break
+		}
+	}
+}
+
+// reopenChannel открывает новый канал на текущем соединении.
+func (q *AMQP) reopenChannel() (chan *amqp.Error, error) {
+	q.mu.RLock()
+	conn := q.conn
+	q.mu.RUnlock()
+	if conn.IsClosed() {
+		return nil, errors.New("AMQP connection is closed")
+	}
+	return q.openChannel(conn)
+}
+
+func (q *AMQP) isClosed() bool {
+	select {
+	case <-q.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Ping возвращает ошибку, если соединения с брокером сейчас нет (например, идёт переподключение).
+func (q *AMQP) Ping(_ context.Context) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.conn == nil || q.conn.IsClosed() {
+		return errors.New("AMQP connection is closed")
+	}
+	return nil
+}
+
+func (q *AMQP) currentChannel() *amqp.Channel {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.channel
+}
+
+// Publish отправляет уведомление как persistent-сообщение и ждёт подтверждения брокера.
+func (q *AMQP) Publish(ctx context.Context, n notification.Notification) error {
+	if q.isClosed() {
+		return ErrClosed
+	}
+
+	body, err := Encode(n)
+	if err != nil {
+		return err
+	}
+	return q.publish(ctx, body)
+}
+
+// publish возвращает nil, только когда брокер подтвердил приём сообщения.
+// При закрытии канала до подтверждения возвращается ошибка.
+func (q *AMQP) publish(ctx context.Context, body []byte) error {
+	msg := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Type:         notificationType,
+		Body:         body,
+	}
+	confirm, err := q.currentChannel().PublishWithDeferredConfirmWithContext(
+		ctx, q.cfg.Exchange, q.cfg.RoutingKey, false, false, msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish notification: %w", err)
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for publish confirmation: %w", err)
+	}
+	if !acked {
+		return errors.New("broker did not confirm notification")
+	}
+	return nil
+}
+
+// Consume читает сообщения с ручным подтверждением. После переподключения
+// подписка восстанавливается автоматически.
+func (q *AMQP) Consume(ctx context.Context) (<-chan Delivery, error) {
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for {
+			msgs, err := q.currentChannel().ConsumeWithContext(ctx, q.cfg.Queue, "", false, false, false, false, nil)
+			if err != nil {
+				q.logger.WithError(err).Warn("Failed to start consuming, retrying")
+			} else {
+				q.forward(ctx, msgs, out)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.closed:
+				return
+			case <-time.After(q.cfg.ReconnectDelay):
+			}
+		}
+	}()
+	return out, nil
+}
+
+// forward передаёт сообщения потребителю, пока открыт канал msgs.
+func (q *AMQP) forward(ctx context.Context, msgs <-chan amqp.Delivery, out chan<- Delivery) {
+	for m := range msgs {
+		n, p, err := decode(m.Body)
+		if err != nil {
+			q.logger.WithError(err).Error("Rejecting malformed message")
+			_ = m.Nack(false, false)
+			continue
+		}
+		select {
+		case out <- amqpDelivery{q: q, m: m, n: n, p: p}:
+		case <-ctx.Done():
+			_ = m.Nack(false, true)
+			return
+		}
+	}
+}
+
+func (q *AMQP) Close() error {
+	var err error
+	q.closeOnce.Do(func() {
+		close(q.closed)
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.channel != nil {
+			_ = q.channel.Close()
+		}
+		if q.conn != nil {
+			err = q.conn.Close()
+		}
+	})
+	return err
+}
+
+type amqpDelivery struct {
+	q *AMQP
+	m amqp.Delivery
+	n notification.Notification
+	p Progress
+}
+
+func (d amqpDelivery) Notification() notification.Notification { return d.n }
+
+func (d amqpDelivery) Progress() Progress { return d.p }
+
+func (d amqpDelivery) Ack() error { return d.m.Ack(false) }
+
+func (d amqpDelivery) Nack(requeue bool) error { return d.m.Nack(false, requeue) }
+
+// Retry публикует копию через тот же exchange; исходное сообщение подтверждается,
+// только если брокер подтвердил приём копии.
+func (d amqpDelivery) Retry(ctx context.Context, p Progress) error {
+	body, err := encode(d.n, p)
+	if err != nil {
+		return err
+	}
+	if err := d.q.publish(ctx, body); err != nil {
+		return err
+	}
+	return d.Ack()
+}
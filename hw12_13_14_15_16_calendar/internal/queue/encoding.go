@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+)
+
+// Version — текущая версия формата сообщений. Увеличивается при
+// несовместимых изменениях, чтобы планировщик и рассыльщик можно было
+// обновлять независимо.
+const Version = 1
+
+const notificationType = "notification"
+
+var ErrUnsupportedMessage = errors.New("unsupported message")
+
+// envelope — конверт сообщения в очереди.
+// Состояние доставки хранится в конверте, а не в уведомлении: уведомление целиком
+// уходит получателям (например, телом вебхука). Старые рассыльщики поле progress игнорируют.
+type envelope struct {
+	Version  int             `json:"version"`
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+	Progress *Progress       `json:"progress,omitempty"`
+}
+
+// Encode сериализует уведомление в версионированный JSON.
+func Encode(n notification.Notification) ([]byte, error) {
+	return encode(n, Progress{})
+}
+
+func encode(n notification.Notification, p Progress) ([]byte, error) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode notification: %w", err)
+	}
+	env := envelope{Version: Version, Type: notificationType, Payload: payload}
+	if p.Redeliveries > 0 || len(p.Delivered) > 0 || len(p.Rejected) > 0 {
+		env.Progress = &p
+	}
+	return json.Marshal(env)
+}
+
+// Decode разбирает сообщение, сериализованное Encode.
+func Decode(data []byte) (notification.Notification, error) {
+	n, _, err := decode(data)
+	return n, err
+}
+
+func decode(data []byte) (notification.Notification, Progress, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return notification.Notification{}, Progress{}, fmt.Errorf("failed to decode message: %w", err)
+	}
+	if env.Type != notificationType || env.Version < 1 || env.Version > Version {
+		return notification.Notification{}, Progress{}, fmt.Errorf("%w: type %q, version %d",
+			ErrUnsupportedMessage, env.Type, env.Version)
+	}
+
+	var n notification.Notification
+	if err := json.Unmarshal(env.Payload, &n); err != nil {
+		return notification.Notification{}, Progress{}, fmt.Errorf("failed to decode notification: %w", err)
+	}
+	var p Progress
+	if env.Progress != nil {
+		p = *env.Progress
+	}
+	return n, p, nil
+}
@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+)
+
+// Memory — очередь в памяти процесса с той же семантикой, что и AMQP:
+// сообщения сериализуются, выдаются не более prefetch неподтверждённых,
+// Nack(true) возвращает сообщение в начало очереди, Retry — в конец. Используется в тестах.
+type Memory struct {
+	mu       sync.Mutex
+	messages [][]byte
+	unacked  int
+	prefetch int
+	wake     chan struct{}
+}
+
+// NewMemory создаёт очередь; prefetch <= 0 — без ограничения.
+func NewMemory(prefetch int) *Memory {
+	return &Memory{
+		prefetch: prefetch,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (q *Memory) Publish(ctx context.Context, n notification.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	body, err := Encode(n)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.messages = append(q.messages, body)
+	q.mu.Unlock()
+	q.signal()
+	return nil
+}
+
+// Len возвращает число сообщений, ожидающих выдачи.
+func (q *Memory) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// Unacked возвращает число выданных, но не подтверждённых сообщений.
+func (q *Memory) Unacked() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.unacked
+}
+
+func (q *Memory) Consume(ctx context.Context) (<-chan Delivery, error) {
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for {
+			body, ok := q.next()
+			if !ok {
+				select {
+				case <-q.wake:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			n, p, err := decode(body)
+			if err != nil {
+				// Нераспознанное сообщение отбрасываем, как AMQP-потребитель
+				q.settle(nil)
+				continue
+			}
+			select {
+			case out <- &memoryDelivery{q: q, body: body, n: n, p: p}:
+			case <-ctx.Done():
+				q.settle(body)
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// next забирает первое сообщение, если не превышен prefetch.
+func (q *Memory) next() ([]byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.messages) == 0 || (q.prefetch > 0 && q.unacked >= q.prefetch) {
+		return nil, false
+	}
+	body := q.messages[0]
+	q.messages = q.messages[1:]
+	q.unacked++
+	return body, true
+}
+
+// settle завершает обработку сообщения; непустой requeue возвращается в начало очереди.
+func (q *Memory) settle(requeue []byte) {
+	q.mu.Lock()
+	q.unacked--
+	if requeue != nil {
+		q.messages = append([][]byte{requeue}, q.messages...)
+	}
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *Memory) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+type memoryDelivery struct {
+	q    *Memory
+	body []byte
+	n    notification.Notification
+	p    Progress
+	once sync.Once
+}
+
+func (d *memoryDelivery) Notification() notification.Notification { return d.n }
+
+func (d *memoryDelivery) Progress() Progress { return d.p }
+
+func (d *memoryDelivery) Ack() error {
+	d.once.Do(func() { d.q.settle(nil) })
+	return nil
+}
+
+func (d *memoryDelivery) Nack(requeue bool) error {
+	d.once.Do(func() {
+		if requeue {
+			d.q.settle(d.body)
+			return
+		}
+		d.q.settle(nil)
+	})
+	return nil
+}
+
+func (d *memoryDelivery) Retry(ctx context.Context, p Progress) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	body, err := encode(d.n, p)
+	if err != nil {
+		return err
+	}
+	d.once.Do(func() {
+		d.q.mu.Lock()
+		d.q.messages = append(d.q.messages, body)
+		d.q.mu.Unlock()
+		d.q.settle(nil)
+	})
+	return nil
+}
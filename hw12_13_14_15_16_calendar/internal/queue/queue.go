@@ -0,0 +1,47 @@
+// Package queue — очередь уведомлений между планировщиком и рассыльщиком.
+// Процессы работают с интерфейсами пакета и не зависят от клиента RabbitMQ.
+package queue
+
+import (
+	"context"
+	"slices"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+)
+
+// Publisher — отправляет уведомления в очередь.
+type Publisher interface {
+	Publish(ctx context.Context, n notification.Notification) error
+}
+
+// Progress — состояние доставки уведомления, которое сохраняется при повторной публикации:
+// повторная доставка не отправляет уведомление получателям, которые его уже обработали.
+type Progress struct {
+	Delivered    []string `json:"delivered,omitempty"`    // получатели, которым уведомление доставлено
+	Rejected     []string `json:"rejected,omitempty"`     // получатели, окончательно отказавшиеся его принять
+	Redeliveries int      `json:"redeliveries,omitempty"` // сколько раз уведомление публиковалось повторно
+}
+
+// Done сообщает, обработан ли уже получатель sink.
+func (p Progress) Done(sink string) bool {
+	return slices.Contains(p.Delivered, sink) || slices.Contains(p.Rejected, sink)
+}
+
+// Delivery — уведомление, полученное из очереди. Должно быть подтверждено
+// (Ack) после успешной обработки, возвращено (Nack) при ошибке или
+// опубликовано заново (Retry).
+type Delivery interface {
+	Notification() notification.Notification
+	// Progress возвращает состояние доставки, сохранённое при повторной публикации.
+	Progress() Progress
+	Ack() error
+	// Nack(false) отклоняет сообщение: AMQP перекладывает его в dead-letter очередь.
+	Nack(requeue bool) error
+	// Retry публикует уведомление в конец очереди с состоянием p и подтверждает исходное сообщение.
+	Retry(ctx context.Context, p Progress) error
+}
+
+// Consumer — читает уведомления из очереди. Канал закрывается при отмене ctx.
+type Consumer interface {
+	Consume(ctx context.Context) (<-chan Delivery, error)
+}
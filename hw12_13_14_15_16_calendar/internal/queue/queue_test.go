@@ -0,0 +1,169 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoding(t *testing.T) {
+	n := notification.Notification{
+		EventID:     "1",
+		Title:       "Standup",
+		DateTime:    time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC),
+		UserID:      "user1",
+		PublishedAt: time.Date(2024, 5, 10, 11, 50, 0, 0, time.UTC),
+	}
+
+	data, err := Encode(n)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":1,"type":"notification","payload":{
+		"event_id":"1","title":"Standup","datetime":"2024-05-10T12:00:00Z","user_id":"user1",
+		"published_at":"2024-05-10T11:50:00Z"}}`, string(data))
+
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, n, decoded)
+
+	// Сообщения планировщиков, не заполняющих published_at, по-прежнему читаются
+	decoded, err = Decode([]byte(`{"version":1,"type":"notification","payload":{"event_id":"1"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "1", decoded.EventID)
+	assert.True(t, decoded.PublishedAt.IsZero())
+}
+
+func TestEncoding_Progress(t *testing.T) {
+	n := notification.Notification{EventID: "1", Title: "Standup"}
+	p := Progress{Delivered: []string{"log"}, Rejected: []string{"webhook"}, Redeliveries: 2}
+
+	data, err := encode(n, p)
+	require.NoError(t, err)
+	// Состояние доставки не попадает в уведомление, которое уходит получателям
+	assert.JSONEq(t, `{"version":1,"type":"notification","payload":{
+		"event_id":"1","title":"Standup","datetime":"0001-01-01T00:00:00Z","user_id":"",
+		"published_at":"0001-01-01T00:00:00Z"},
+		"progress":{"delivered":["log"],"rejected":["webhook"],"redeliveries":2}}`, string(data))
+
+	decoded, progress, err := decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, n, decoded)
+	assert.Equal(t, p, progress)
+	assert.True(t, progress.Done("log"))
+	assert.True(t, progress.Done("webhook"))
+	assert.False(t, progress.Done("stdout"))
+}
+
+func TestDecode_Unsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"future version", `{"version":2,"type":"notification","payload":{}}`},
+		{"no version", `{"type":"notification","payload":{}}`},
+		{"unknown type", `{"version":1,"type":"event","payload":{}}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Decode([]byte(tc.data))
+			assert.ErrorIs(t, err, ErrUnsupportedMessage)
+		})
+	}
+
+	_, err := Decode([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func receive(t *testing.T, ch <-chan Delivery) Delivery {
+	t.Helper()
+	select {
+	case d := <-ch:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no delivery")
+		return nil
+	}
+}
+
+func TestMemory_AckAndRequeue(t *testing.T) {
+	q := NewMemory(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, q.Publish(ctx, notification.Notification{EventID: "1"}))
+	require.NoError(t, q.Publish(ctx, notification.Notification{EventID: "2"}))
+
+	deliveries, err := q.Consume(ctx)
+	require.NoError(t, err)
+
+	d := receive(t, deliveries)
+	assert.Equal(t, "1", d.Notification().EventID)
+	require.NoError(t, d.Nack(true))
+
+	// Возвращённое сообщение приходит повторно и первым
+	d = receive(t, deliveries)
+	assert.Equal(t, "1", d.Notification().EventID)
+	require.NoError(t, d.Ack())
+
+	d = receive(t, deliveries)
+	assert.Equal(t, "2", d.Notification().EventID)
+	require.NoError(t, d.Nack(false))
+
+	assert.Eventually(t, func() bool { return q.Len() == 0 && q.Unacked() == 0 },
+		time.Second, 10*time.Millisecond)
+}
+
+func TestMemory_Retry(t *testing.T) {
+	q := NewMemory(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, q.Publish(ctx, notification.Notification{EventID: "1"}))
+	require.NoError(t, q.Publish(ctx, notification.Notification{EventID: "2"}))
+
+	deliveries, err := q.Consume(ctx)
+	require.NoError(t, err)
+
+	d := receive(t, deliveries)
+	assert.Equal(t, Progress{}, d.Progress())
+	require.NoError(t, d.Retry(ctx, Progress{Delivered: []string{"log"}, Redeliveries: 1}))
+
+	// Опубликованная заново копия приходит последней и с сохранённым состоянием
+	d = receive(t, deliveries)
+	assert.Equal(t, "2", d.Notification().EventID)
+	require.NoError(t, d.Ack())
+	d = receive(t, deliveries)
+	assert.Equal(t, "1", d.Notification().EventID)
+	assert.Equal(t, Progress{Delivered: []string{"log"}, Redeliveries: 1}, d.Progress())
+	require.NoError(t, d.Ack())
+
+	assert.Eventually(t, func() bool { return q.Len() == 0 && q.Unacked() == 0 },
+		time.Second, 10*time.Millisecond)
+}
+
+func TestMemory_Prefetch(t *testing.T) {
+	q := NewMemory(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, id := range []string{"1", "2"} {
+		require.NoError(t, q.Publish(ctx, notification.Notification{EventID: id}))
+	}
+
+	deliveries, err := q.Consume(ctx)
+	require.NoError(t, err)
+
+	first := receive(t, deliveries)
+	select {
+	case <-deliveries:
+		t.Fatal("second message delivered before ack")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, first.Ack())
+	second := receive(t, deliveries)
+	assert.Equal(t, "2", second.Notification().EventID)
+}
@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/queue"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// Storage — часть хранилища, нужная планировщику.
+type Storage interface {
+	ListToNotify(ctx context.Context, from, to time.Time) ([]storage.Event, error)
+	DeleteBefore(ctx context.Context, t time.Time) (int, error)
+}
+
+type Config struct {
+	Interval        time.Duration // период сканирования событий для уведомлений
+	Retention       time.Duration // сколько хранить прошедшие события; 0 — не удалять
+	CleanupInterval time.Duration // период очистки старых событий
+}
+
+// Scheduler периодически выбирает события, для которых наступило время
+// уведомления, отправляет уведомления в очередь и удаляет старые события.
+type Scheduler struct {
+	logger *logrus.Logger
+	store  Storage
+	pub    queue.Publisher
+	cfg    Config
+	now    func() time.Time
+}
+
+func New(logger *logrus.Logger, store Storage, pub queue.Publisher, cfg Config) *Scheduler {
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = cfg.Interval
+	}
+	return &Scheduler{
+		logger: logger,
+		store:  store,
+		pub:    pub,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Run сканирует хранилище каждые Interval, пока не отменён ctx.
+// Первый проход захватывает один интервал до запуска, чтобы не терять
+// уведомления при перезапуске планировщика.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	var cleanup <-chan time.Time
+	if s.cfg.Retention > 0 {
+		cleanupTicker := time.NewTicker(s.cfg.CleanupInterval)
+		defer cleanupTicker.Stop()
+		cleanup = cleanupTicker.C
+		s.cleanup(ctx)
+	}
+
+	s.logger.Infof("Scheduler started, scan interval %s", s.cfg.Interval)
+	from := s.now().Add(-s.cfg.Interval)
+	from = s.scan(ctx, from)
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Scheduler stopped")
+			return nil
+		case <-cleanup:
+			s.cleanup(ctx)
+		case <-ticker.C:
+			from = s.scan(ctx, from)
+		}
+	}
+}
+
+// scan публикует уведомления с from до текущего момента и возвращает
+// начало следующего окна. При ошибке окно не сдвигается.
+func (s *Scheduler) scan(ctx context.Context, from time.Time) time.Time {
+	to := s.now()
+	if err := s.Scan(ctx, from, to); err != nil {
+		s.logger.WithError(err).Error("Scan failed")
+		metrics.SchedulerErrors.WithLabelValues("scan").Inc()
+		metrics.SchedulerLag.Set(to.Sub(from).Seconds())
+		return from
+	}
+	metrics.SchedulerLag.Set(0)
+	return to
+}
+
+func (s *Scheduler) cleanup(ctx context.Context) {
+	if _, err := s.Cleanup(ctx); err != nil {
+		s.logger.WithError(err).Error("Cleanup failed")
+		metrics.SchedulerErrors.WithLabelValues("cleanup").Inc()
+	}
+}
+
+// Cleanup удаляет события, начавшиеся раньше, чем Retention назад.
+func (s *Scheduler) Cleanup(ctx context.Context) (int, error) {
+	before := s.now().Add(-s.cfg.Retention)
+	n, err := s.store.DeleteBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old events: %w", err)
+	}
+	s.logger.Infof("Purged %d events older than %s", n, before.Format(time.RFC3339))
+	return n, nil
+}
+
+// Scan публикует уведомления для событий, время уведомления которых в [from, to).
+func (s *Scheduler) Scan(ctx context.Context, from, to time.Time) error {
+	events, err := s.store.ListToNotify(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to list events to notify: %w", err)
+	}
+	for _, e := range events {
+		n := notification.FromEvent(e)
+		n.PublishedAt = s.now()
+		if err := s.pub.Publish(ctx, n); err != nil {
+			return fmt.Errorf("failed to publish notification for event %s: %w", e.ID, err)
+		}
+		metrics.SchedulerPublished.Inc()
+	}
+	if len(events) > 0 {
+		s.logger.Infof("Published %d notifications", len(events))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/queue"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// receive забирает из очереди следующее уведомление.
+func receive(t *testing.T, deliveries <-chan queue.Delivery) notification.Notification {
+	t.Helper()
+	select {
+	case d := <-deliveries:
+		require.NoError(t, d.Ack())
+		return d.Notification()
+	case <-time.After(time.Second):
+		t.Fatal("notification was not published")
+		return notification.Notification{}
+	}
+}
+
+func TestScheduler_Scan(t *testing.T) {
+	ctx := context.Background()
+	store := inmemory.New()
+	base := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Add(ctx, storage.Event{
+		ID: "1", Title: "Standup", UserID: "user1", DateTime: base, NotifyBefore: 600,
+	}))
+	require.NoError(t, store.Add(ctx, storage.Event{
+		ID: "2", Title: "Later", UserID: "user1", DateTime: base.Add(time.Hour), NotifyBefore: 600,
+	}))
+
+	q := queue.NewMemory(0)
+	s := New(newTestLogger(), store, q, Config{Interval: time.Minute})
+	now := base.Add(-5 * time.Minute)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Scan(ctx, base.Add(-15*time.Minute), base.Add(-5*time.Minute)))
+	require.Equal(t, 1, q.Len())
+
+	deliveries, err := q.Consume(ctx)
+	require.NoError(t, err)
+	n := receive(t, deliveries)
+	assert.Equal(t, "1", n.EventID)
+	assert.Equal(t, "Standup", n.Title)
+	assert.Equal(t, "user1", n.UserID)
+	assert.True(t, base.Equal(n.DateTime))
+	assert.True(t, now.Equal(n.PublishedAt))
+}
+
+func TestScheduler_ScanRecurring(t *testing.T) {
+	ctx := context.Background()
+	store := inmemory.New()
+	base := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Add(ctx, storage.Event{
+		ID: "1", Title: "Daily", UserID: "user1", DateTime: base, NotifyBefore: 600, RRule: "FREQ=DAILY",
+	}))
+
+	q := queue.NewMemory(0)
+	s := New(newTestLogger(), store, q, Config{Interval: time.Minute})
+
+	// За три дня — по уведомлению на каждый экземпляр
+	require.NoError(t, s.Scan(ctx, base.Add(-time.Hour), base.AddDate(0, 0, 2)))
+	require.Equal(t, 3, q.Len())
+
+	deliveries, err := q.Consume(ctx)
+	require.NoError(t, err)
+	seen := make(map[time.Time]bool)
+	for range 3 {
+		n := receive(t, deliveries)
+		assert.Equal(t, "1", n.EventID)
+		seen[n.DateTime.UTC()] = true
+	}
+	assert.Len(t, seen, 3)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := inmemory.New()
+	now := time.Now()
+	require.NoError(t, store.Add(ctx, storage.Event{
+		ID: "1", Title: "Soon", UserID: "user1", DateTime: now.Add(time.Second + 50*time.Millisecond), NotifyBefore: 1,
+	}))
+
+	q := queue.NewMemory(0)
+	s := New(newTestLogger(), store, q, Config{Interval: 20 * time.Millisecond})
+
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	deliveries, err := q.Consume(ctx)
+	require.NoError(t, err)
+	n := receive(t, deliveries)
+	assert.Equal(t, "1", n.EventID)
+
+	// Дождёмся ещё нескольких проходов: повторно уведомление не публикуется
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	assert.Zero(t, q.Len(), "notification must be published once")
+}
+
+func TestScheduler_Cleanup(t *testing.T) {
+	ctx := context.Background()
+	store := inmemory.New()
+	now := time.Now()
+	require.NoError(t, store.Add(ctx, storage.Event{ID: "old", UserID: "user1", DateTime: now.AddDate(-2, 0, 0)}))
+	require.NoError(t, store.Add(ctx, storage.Event{ID: "new", UserID: "user1", DateTime: now.AddDate(0, 0, -1)}))
+
+	s := New(newTestLogger(), store, queue.NewMemory(0), Config{
+		Interval:  time.Minute,
+		Retention: 365 * 24 * time.Hour,
+	})
+
+	deleted, err := s.Cleanup(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.ErrorIs(t, store.Delete(ctx, "user1", "old"), storage.ErrEventNotFound)
+}
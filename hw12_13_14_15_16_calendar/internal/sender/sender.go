@@ -0,0 +1,173 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/queue"
+	"github.com/sirupsen/logrus"
+)
+
+// Backoff — параметры повторных попыток доставки.
+type Backoff struct {
+	MaxAttempts int // попыток отправки в один sink за одну доставку
+	Initial     time.Duration
+	Max         time.Duration
+	// MaxRedeliveries — сколько раз уведомление публикуется в очередь заново, если какой-то sink
+	// недоступен; после этого оно отбрасывается (Nack(false)).
+	MaxRedeliveries int
+}
+
+// ErrPermanent — ошибка доставки, которую бессмысленно повторять (например, ответ вебхука 4xx).
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// retryPublishTimeout ограничивает повторную публикацию, которая выполняется и при остановке.
+const retryPublishTimeout = 5 * time.Second
+
+// delay возвращает паузу перед попыткой attempt (начиная с 1): Initial * 2^(attempt-1), но не больше Max.
+func (b Backoff) delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	return d
+}
+
+// Sender читает уведомления из очереди и доставляет их через все sinks.
+// Каждый sink получает уведомление один раз: если часть sinks недоступна, уведомление
+// публикуется заново с отметкой, кому оно уже доставлено (см. queue.Progress).
+type Sender struct {
+	logger   *logrus.Logger
+	consumer queue.Consumer
+	sinks    []namedSink
+	backoff  Backoff
+}
+
+type namedSink struct {
+	name string
+	Sink
+}
+
+// New создаёт рассыльщика; ключи sinks — имена из конфигурации, по ним отмечается доставка.
+func New(logger *logrus.Logger, consumer queue.Consumer, sinks map[string]Sink, backoff Backoff) *Sender {
+	if backoff.MaxAttempts < 1 {
+		backoff.MaxAttempts = 1
+	}
+	backoff.MaxRedeliveries = max(backoff.MaxRedeliveries, 0)
+	named := make([]namedSink, 0, len(sinks))
+	for name, sink := range sinks {
+		named = append(named, namedSink{name: name, Sink: sink})
+	}
+	sort.Slice(named, func(i, j int) bool { return named[i].name < named[j].name })
+	return &Sender{
+		logger:   logger,
+		consumer: consumer,
+		sinks:    named,
+		backoff:  backoff,
+	}
+}
+
+// Run обрабатывает уведомления, пока не отменён ctx или не закрыта очередь.
+func (s *Sender) Run(ctx context.Context) error {
+	deliveries, err := s.consumer.Consume(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming: %w", err)
+	}
+
+	s.logger.Infof("Sender started with %d sinks", len(s.sinks))
+	for d := range deliveries {
+		s.handle(ctx, d)
+	}
+	s.logger.Info("Sender stopped")
+	return nil
+}
+
+func (s *Sender) handle(ctx context.Context, d queue.Delivery) {
+	n := d.Notification()
+	log := s.logger.WithField("event_id", n.EventID)
+	if !n.PublishedAt.IsZero() {
+		metrics.SenderQueueLag.Observe(time.Since(n.PublishedAt).Seconds())
+	}
+
+	progress := d.Progress()
+	failed := 0
+	for _, sink := range s.sinks {
+		if progress.Done(sink.name) {
+			continue
+		}
+		err := s.sendWithRetry(ctx, sink, n)
+		switch {
+		case err == nil:
+			progress.Delivered = append(progress.Delivered, sink.name)
+		case errors.Is(err, ErrPermanent):
+			log.WithError(err).WithField("sink", sink.name).Error("Sink rejected notification")
+			progress.Rejected = append(progress.Rejected, sink.name)
+		default:
+			log.WithError(err).WithField("sink", sink.name).Warn("Failed to deliver notification")
+			failed++
+		}
+	}
+
+	switch {
+	case failed > 0 && progress.Redeliveries < s.backoff.MaxRedeliveries:
+		progress.Redeliveries++
+		// Копию нужно опубликовать и при остановке, иначе доставленное повторится
+		pubCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), retryPublishTimeout)
+		defer cancel()
+		if err := d.Retry(pubCtx, progress); err != nil {
+			log.WithError(err).Error("Failed to republish notification, returning it to queue")
+			if err := d.Nack(true); err != nil {
+				log.WithError(err).Error("Failed to nack notification")
+			}
+		}
+		metrics.SenderNotifications.WithLabelValues("retried").Inc()
+	case failed > 0 || len(progress.Rejected) > 0:
+		log.WithFields(logrus.Fields{
+			"delivered":    progress.Delivered,
+			"rejected":     progress.Rejected,
+			"redeliveries": progress.Redeliveries,
+		}).Error("Giving up on notification, dead-lettering it")
+		metrics.SenderNotifications.WithLabelValues("dead_lettered").Inc()
+		if err := d.Nack(false); err != nil {
+			log.WithError(err).Error("Failed to nack notification")
+		}
+	default:
+		metrics.SenderNotifications.WithLabelValues("delivered").Inc()
+		if err := d.Ack(); err != nil {
+			log.WithError(err).Error("Failed to ack notification")
+		}
+	}
+}
+
+// sendWithRetry отправляет уведомление в sink, повторяя попытки после временных ошибок.
+func (s *Sender) sendWithRetry(ctx context.Context, sink Sink, n notification.Notification) error {
+	var err error
+	for attempt := 1; attempt <= s.backoff.MaxAttempts; attempt++ {
+		if err = sink.Send(ctx, n); err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrPermanent) {
+			return err
+		}
+		if attempt == s.backoff.MaxAttempts {
+			break
+		}
+
+		delay := s.backoff.delay(attempt)
+		s.logger.WithError(err).Warnf("Delivery attempt %d failed, retrying in %s", attempt, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("%d attempts failed: %w", s.backoff.MaxAttempts, err)
+}
@@ -0,0 +1,197 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/queue"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// flakySink падает первые failures вызовов (или все, если failures < 0) с ошибкой err.
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	calls    int
+	sent     []notification.Notification
+}
+
+func (s *flakySink) Send(_ context.Context, n notification.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.failures < 0 || s.calls <= s.failures {
+		if s.err != nil {
+			return s.err
+		}
+		return errors.New("sink is down")
+	}
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+var testBackoff = Backoff{MaxAttempts: 3, Initial: time.Millisecond, Max: 5 * time.Millisecond, MaxRedeliveries: 2}
+
+func (s *flakySink) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// runSender запускает рассыльщик и дожидается, пока очередь опустеет.
+func runSender(t *testing.T, q *queue.Memory, sinks map[string]Sink) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- New(newTestLogger(), q, sinks, testBackoff).Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool { return q.Len() == 0 && q.Unacked() == 0 },
+		time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestSender_RetriesAndAcks(t *testing.T) {
+	q := queue.NewMemory(1)
+	require.NoError(t, q.Publish(context.Background(),
+		notification.Notification{EventID: "1", Title: "Standup", UserID: "user1"}))
+
+	sink := &flakySink{failures: 2}
+	delivered := testutil.ToFloat64(metrics.SenderNotifications.WithLabelValues("delivered"))
+	runSender(t, q, map[string]Sink{"flaky": sink})
+
+	assert.Equal(t, 3, sink.Calls())
+	assert.Equal(t, delivered+1, testutil.ToFloat64(metrics.SenderNotifications.WithLabelValues("delivered")))
+	require.Len(t, sink.sent, 1)
+	assert.Equal(t, "1", sink.sent[0].EventID)
+}
+
+func TestSender_RedeliversAfterMaxAttempts(t *testing.T) {
+	q := queue.NewMemory(1)
+	require.NoError(t, q.Publish(context.Background(), notification.Notification{EventID: "1"}))
+
+	// Первая доставка исчерпает 3 попытки, повторная (после Retry) пройдёт
+	sink := &flakySink{failures: 3}
+	retried := testutil.ToFloat64(metrics.SenderNotifications.WithLabelValues("retried"))
+	runSender(t, q, map[string]Sink{"flaky": sink})
+
+	assert.Equal(t, 4, sink.Calls())
+	require.Len(t, sink.sent, 1)
+	assert.Equal(t, retried+1, testutil.ToFloat64(metrics.SenderNotifications.WithLabelValues("retried")))
+}
+
+func TestSender_DoesNotResendToDeliveredSinks(t *testing.T) {
+	q := queue.NewMemory(1)
+	require.NoError(t, q.Publish(context.Background(), notification.Notification{EventID: "1"}))
+
+	ok := &flakySink{}
+	flaky := &flakySink{failures: 3}
+	runSender(t, q, map[string]Sink{"ok": ok, "flaky": flaky})
+
+	// Повторная доставка идёт только в sink, который не получил уведомление
+	assert.Equal(t, 1, ok.Calls())
+	assert.Len(t, ok.sent, 1)
+	assert.Equal(t, 4, flaky.Calls())
+	assert.Len(t, flaky.sent, 1)
+}
+
+func TestSender_DeadLettersPermanentFailures(t *testing.T) {
+	q := queue.NewMemory(1)
+	require.NoError(t, q.Publish(context.Background(), notification.Notification{EventID: "1"}))
+
+	ok := &flakySink{}
+	rejecting := &flakySink{failures: -1, err: fmt.Errorf("%w: bad request", ErrPermanent)}
+	deadLettered := testutil.ToFloat64(metrics.SenderNotifications.WithLabelValues("dead_lettered"))
+	runSender(t, q, map[string]Sink{"ok": ok, "rejecting": rejecting})
+
+	// Постоянная ошибка не повторяется ни внутри доставки, ни через очередь
+	assert.Equal(t, 1, rejecting.Calls())
+	assert.Equal(t, 1, ok.Calls())
+	assert.Equal(t, deadLettered+1, testutil.ToFloat64(metrics.SenderNotifications.WithLabelValues("dead_lettered")))
+}
+
+func TestSender_DeadLettersAfterMaxRedeliveries(t *testing.T) {
+	q := queue.NewMemory(1)
+	require.NoError(t, q.Publish(context.Background(), notification.Notification{EventID: "1"}))
+
+	down := &flakySink{failures: -1}
+	deadLettered := testutil.ToFloat64(metrics.SenderNotifications.WithLabelValues("dead_lettered"))
+	runSender(t, q, map[string]Sink{"down": down})
+
+	// Первая доставка и MaxRedeliveries повторных, по MaxAttempts попыток в каждой
+	assert.Equal(t, (1+testBackoff.MaxRedeliveries)*testBackoff.MaxAttempts, down.Calls())
+	assert.Equal(t, deadLettered+1, testutil.ToFloat64(metrics.SenderNotifications.WithLabelValues("dead_lettered")))
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	assert.Equal(t, 100*time.Millisecond, b.delay(1))
+	assert.Equal(t, 200*time.Millisecond, b.delay(2))
+	assert.Equal(t, 800*time.Millisecond, b.delay(4))
+	assert.Equal(t, time.Second, b.delay(10))
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got notification.Notification
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch got.EventID {
+		case "unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "invalid":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	}))
+	defer ts.Close()
+
+	sink := NewWebhookSink(ts.URL, time.Second)
+	require.NoError(t, sink.Send(context.Background(), notification.Notification{EventID: "1", Title: "Standup"}))
+	assert.Equal(t, "Standup", got.Title)
+
+	// 5xx, 408 и 429 — временные ошибки, остальные 4xx — постоянные
+	for _, id := range []string{"unavailable", "throttled"} {
+		err := sink.Send(context.Background(), notification.Notification{EventID: id})
+		require.Error(t, err, id)
+		assert.NotErrorIs(t, err, ErrPermanent, id)
+	}
+	err := sink.Send(context.Background(), notification.Notification{EventID: "invalid"})
+	assert.ErrorIs(t, err, ErrPermanent)
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	date := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, sink.Send(context.Background(),
+		notification.Notification{EventID: "1", Title: "Standup", UserID: "user1", DateTime: date}))
+	assert.Equal(t, "[user1] 1: \"Standup\" at 2024-05-10T12:00:00Z\n", buf.String())
+}
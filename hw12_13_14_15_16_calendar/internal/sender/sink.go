@@ -0,0 +1,101 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/notification"
+	"github.com/sirupsen/logrus"
+)
+
+// Sink — способ доставки уведомления пользователю.
+type Sink interface {
+	Send(ctx context.Context, n notification.Notification) error
+}
+
+// LogSink — пишет уведомления в лог.
+type LogSink struct {
+	logger *logrus.Logger
+}
+
+func NewLogSink(logger *logrus.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Send(_ context.Context, n notification.Notification) error {
+	s.logger.WithFields(logrus.Fields{
+		"event_id": n.EventID,
+		"user_id":  n.UserID,
+		"datetime": n.DateTime,
+	}).Infof("Notification: %s", n.Title)
+	return nil
+}
+
+// WriterSink — выводит уведомления в поток (например, STDOUT) построчно.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Send(_ context.Context, n notification.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "[%s] %s: %q at %s\n",
+		n.UserID, n.EventID, n.Title, n.DateTime.Format(time.RFC3339))
+	return err
+}
+
+// WebhookSink — отправляет уведомление POST-запросом с JSON-телом.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *WebhookSink) Send(ctx context.Context, n notification.Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("%w: failed to encode notification: %w", ErrPermanent, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: failed to build webhook request: %w", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		// Запрос отклонён получателем: повтор даст тот же ответ
+		return fmt.Errorf("%w: webhook responded with status %d", ErrPermanent, resp.StatusCode)
+	default:
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,494 @@
+// Package caldav реализует CalDAV-сервер (RFC 4791) поверх событий календаря,
+// чтобы на календарь можно было подписаться из стандартных клиентов.
+//
+// У каждого пользователя один календарь:
+//
+//	{prefix}/                                  — корень, указывает на принципала
+//	{prefix}/principals/{user}/                — принципал пользователя
+//	{prefix}/calendars/{user}/                 — домашняя коллекция календарей
+//	{prefix}/calendars/{user}/default/         — календарь
+//	{prefix}/calendars/{user}/default/{id}.ics — событие, имя ресурса совпадает с UID
+//
+// Отдельные изменённые экземпляры повторяющихся событий (RECURRENCE-ID) не поддерживаются.
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/ical"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// CalendarName — имя единственного календаря пользователя.
+const CalendarName = "default"
+
+const (
+	// maxBodySize ограничивает размер тела PUT, PROPFIND и REPORT.
+	maxBodySize = 1 << 20
+
+	allowedMethods = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+	calendarType   = "text/calendar; charset=utf-8"
+)
+
+var errBadRequest = errors.New("bad request")
+
+// Backend — операции над событиями, которые нужны CalDAV-серверу.
+type Backend interface {
+	GetEvent(ctx context.Context, userID, id string) (storage.Event, error)
+	ListAllEvents(ctx context.Context, userID string) ([]storage.Event, error)
+	CreateEvent(ctx context.Context, userID string, event storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, userID, id string, event storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, userID, id string) error
+}
+
+// Handler обслуживает CalDAV-запросы под префиксом prefix.
+type Handler struct {
+	logger  *logrus.Logger
+	backend Backend
+	prefix  string
+	user    func(*http.Request) string
+}
+
+// New создаёт обработчик. user возвращает ID пользователя запроса или пустую строку,
+// если пользователь не указан — тогда клиенту отвечают 401 с запросом Basic-авторизации.
+func New(logger *logrus.Logger, backend Backend, prefix string, user func(*http.Request) string) *Handler {
+	return &Handler{
+		logger:  logger,
+		backend: backend,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		user:    user,
+	}
+}
+
+type kind int
+
+const (
+	kindRoot kind = iota
+	kindPrincipal
+	kindHome
+	kindCalendar
+	kindObject
+)
+
+// target — ресурс, на который указывает путь запроса.
+type target struct {
+	kind kind
+	user string
+	id   string
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow", allowedMethods)
+		return
+	}
+
+	userID := h.user(r)
+	if userID == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="calendar"`)
+		http.Error(w, "user is required", http.StatusUnauthorized)
+		return
+	}
+	t, ok := h.parsePath(r.URL.EscapedPath())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if t.user != "" && t.user != userID {
+		http.Error(w, "access to another user's calendar is forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case "PROPFIND":
+		h.handlePropfind(w, r, userID, t)
+	case "REPORT":
+		h.handleReport(w, r, userID, t)
+	case http.MethodGet, http.MethodHead:
+		h.handleGet(w, r, userID, t)
+	case http.MethodPut:
+		h.handlePut(w, r, userID, t)
+	case http.MethodDelete:
+		h.handleDelete(w, r, userID, t)
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// parsePath разбирает путь (в экранированном виде, чтобы ID мог содержать «/»).
+func (h *Handler) parsePath(escaped string) (target, bool) {
+	rest, ok := strings.CutPrefix(escaped, h.prefix)
+	if !ok || (rest != "" && rest[0] != '/') {
+		return target{}, false
+	}
+	rest = strings.Trim(rest, "/")
+	if rest == "" {
+		return target{kind: kindRoot}, true
+	}
+
+	segments := strings.Split(rest, "/")
+	for i, s := range segments {
+		var err error
+		if segments[i], err = url.PathUnescape(s); err != nil || segments[i] == "" {
+			return target{}, false
+		}
+	}
+	switch {
+	case len(segments) == 2 && segments[0] == "principals":
+		return target{kind: kindPrincipal, user: segments[1]}, true
+	case len(segments) == 2 && segments[0] == "calendars":
+		return target{kind: kindHome, user: segments[1]}, true
+	case len(segments) == 3 && segments[0] == "calendars" && segments[2] == CalendarName:
+		return target{kind: kindCalendar, user: segments[1]}, true
+	case len(segments) == 4 && segments[0] == "calendars" && segments[2] == CalendarName:
+		id, ok := strings.CutSuffix(segments[3], ".ics")
+		if !ok || id == "" {
+			return target{}, false
+		}
+		return target{kind: kindObject, user: segments[1], id: id}, true
+	}
+	return target{}, false
+}
+
+// href строит путь ресурса; у коллекций он заканчивается «/».
+func (h *Handler) href(t target) string {
+	switch t.kind {
+	case kindPrincipal:
+		return h.prefix + "/principals/" + url.PathEscape(t.user) + "/"
+	case kindHome:
+		return h.prefix + "/calendars/" + url.PathEscape(t.user) + "/"
+	case kindCalendar:
+		return h.prefix + "/calendars/" + url.PathEscape(t.user) + "/" + CalendarName + "/"
+	case kindObject:
+		return h.prefix + "/calendars/" + url.PathEscape(t.user) + "/" + CalendarName + "/" +
+			url.PathEscape(t.id) + ".ics"
+	default:
+		return h.prefix + "/"
+	}
+}
+
+func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request, userID string, t target) {
+	req, err := decodePropfind(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	// Depth: infinity не поддерживается и обрабатывается как 1
+	children := r.Header.Get("Depth") != "0"
+
+	resources, err := h.resources(r.Context(), userID, t, req, children)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	ms := multistatus{Responses: make([]response, 0, len(resources))}
+	for _, res := range resources {
+		ms.Responses = append(ms.Responses, res.response(req))
+	}
+	h.writeMultistatus(w, r, ms)
+}
+
+// resources возвращает ресурс t и, если children, его непосредственных потомков.
+func (h *Handler) resources(
+	ctx context.Context, userID string, t target, req propRequest, children bool,
+) ([]resource, error) {
+	switch t.kind {
+	case kindPrincipal:
+		return []resource{h.principal(userID)}, nil
+	case kindHome:
+		result := []resource{h.home(userID)}
+		if children {
+			events, err := h.backend.ListAllEvents(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, h.calendar(userID, events))
+		}
+		return result, nil
+	case kindCalendar:
+		events, err := h.backend.ListAllEvents(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		result := []resource{h.calendar(userID, events)}
+		if children {
+			objects, err := h.objects(userID, events, req)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, objects...)
+		}
+		return result, nil
+	case kindObject:
+		e, err := h.backend.GetEvent(ctx, userID, t.id)
+		if err != nil {
+			return nil, err
+		}
+		return h.objects(userID, []storage.Event{e}, req)
+	default:
+		return []resource{h.root(userID)}, nil
+	}
+}
+
+func (h *Handler) objects(userID string, events []storage.Event, req propRequest) ([]resource, error) {
+	withData := req.wants(calendarDataName)
+	result := make([]resource, 0, len(events))
+	for _, e := range events {
+		res, err := h.object(userID, e, withData)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, res)
+	}
+	return result, nil
+}
+
+func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request, userID string, t target) {
+	if t.kind != kindCalendar {
+		http.Error(w, "reports are supported on the calendar collection only", http.StatusForbidden)
+		return
+	}
+	var req report
+	if err := xml.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		h.writeError(w, r, fmt.Errorf("%w: invalid XML: %w", errBadRequest, err))
+		return
+	}
+
+	var ms multistatus
+	var err error
+	switch req.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		ms, err = h.calendarQuery(r.Context(), userID, req)
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		ms, err = h.calendarMultiget(r.Context(), userID, req)
+	default:
+		http.Error(w, "unsupported report "+req.XMLName.Local, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.writeMultistatus(w, r, ms)
+}
+
+func (h *Handler) calendarQuery(ctx context.Context, userID string, req report) (multistatus, error) {
+	if err := req.Filter.Comp.parse(); err != nil {
+		return multistatus{}, err
+	}
+	events, err := h.backend.ListAllEvents(ctx, userID)
+	if err != nil {
+		return multistatus{}, err
+	}
+	var ms multistatus
+	for _, e := range events {
+		if !req.Filter.Comp.match(e) {
+			continue
+		}
+		res, err := h.object(userID, e, req.Prop.wants(calendarDataName))
+		if err != nil {
+			return multistatus{}, err
+		}
+		ms.Responses = append(ms.Responses, res.response(req.Prop))
+	}
+	return ms, nil
+}
+
+func (h *Handler) calendarMultiget(ctx context.Context, userID string, req report) (multistatus, error) {
+	var ms multistatus
+	for _, href := range req.Hrefs {
+		u, err := url.Parse(strings.TrimSpace(href))
+		if err != nil {
+			return multistatus{}, fmt.Errorf("%w: invalid href %q", errBadRequest, href)
+		}
+		t, ok := h.parsePath(u.EscapedPath())
+		if !ok || t.kind != kindObject || t.user != userID {
+			ms.Responses = append(ms.Responses, statusResponse(href, http.StatusNotFound))
+			continue
+		}
+		e, err := h.backend.GetEvent(ctx, userID, t.id)
+		if errors.Is(err, storage.ErrEventNotFound) {
+			ms.Responses = append(ms.Responses, statusResponse(href, http.StatusNotFound))
+			continue
+		}
+		if err != nil {
+			return multistatus{}, err
+		}
+		res, err := h.object(userID, e, req.Prop.wants(calendarDataName))
+		if err != nil {
+			return multistatus{}, err
+		}
+		ms.Responses = append(ms.Responses, res.response(req.Prop))
+	}
+	return ms, nil
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request, userID string, t target) {
+	if t.kind != kindObject {
+		http.Error(w, "use PROPFIND to list collections", http.StatusMethodNotAllowed)
+		return
+	}
+	e, err := h.backend.GetEvent(r.Context(), userID, t.id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, []storage.Event{e}); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", calendarType)
+	w.Header().Set("ETag", etag(e))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Warn("Failed to write CalDAV response")
+	}
+}
+
+// handlePut создаёт или заменяет событие. UID события должен совпадать с именем ресурса.
+// Поддерживаются условия If-Match и If-None-Match: *.
+func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, userID string, t target) {
+	if t.kind != kindObject {
+		http.Error(w, "only calendar object resources can be written", http.StatusMethodNotAllowed)
+		return
+	}
+	event, err := decodeObject(http.MaxBytesReader(w, r.Body, maxBodySize), t.id)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	current, err := h.backend.GetEvent(r.Context(), userID, t.id)
+	exists := err == nil
+	if err != nil && !errors.Is(err, storage.ErrEventNotFound) {
+		h.writeError(w, r, err)
+		return
+	}
+	if !preconditions(r, current, exists) {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+
+	status := http.StatusNoContent
+	if exists {
+		event, err = h.backend.UpdateEvent(r.Context(), userID, t.id, event)
+	} else {
+		status = http.StatusCreated
+		event, err = h.backend.CreateEvent(r.Context(), userID, event)
+	}
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(event))
+	w.WriteHeader(status)
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, userID string, t target) {
+	if t.kind != kindObject {
+		http.Error(w, "collections cannot be deleted", http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("If-Match") != "" {
+		current, err := h.backend.GetEvent(r.Context(), userID, t.id)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		if !preconditions(r, current, true) {
+			http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+			return
+		}
+	}
+	if err := h.backend.DeleteEvent(r.Context(), userID, t.id); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeObject читает ресурс календаря с одним событием, UID которого равен id.
+func decodeObject(r io.Reader, id string) (storage.Event, error) {
+	events, err := ical.Decode(r, nil)
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("%w: %w", errBadRequest, err)
+	}
+	if len(events) == 0 {
+		return storage.Event{}, fmt.Errorf("%w: resource must contain a VEVENT", errBadRequest)
+	}
+	// Остальные VEVENT с тем же UID — изменённые экземпляры, они не поддерживаются
+	for _, e := range events {
+		if e.ID != id {
+			return storage.Event{}, fmt.Errorf("%w: UID %q does not match resource name %q", errBadRequest, e.ID, id)
+		}
+	}
+	return events[0], nil
+}
+
+// preconditions проверяет заголовки If-Match и If-None-Match.
+func preconditions(r *http.Request, current storage.Event, exists bool) bool {
+	if match := r.Header.Get("If-Match"); match != "" {
+		if !exists || (match != "*" && !containsETag(match, etag(current))) {
+			return false
+		}
+	}
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" && exists {
+		if noneMatch == "*" || containsETag(noneMatch, etag(current)) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsETag(header, tag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		if strings.TrimSpace(v) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) writeMultistatus(w http.ResponseWriter, r *http.Request, ms multistatus) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, err := io.WriteString(w, xml.Header)
+	if err == nil {
+		err = xml.NewEncoder(w).Encode(ms)
+	}
+	if err != nil {
+		h.logger.WithContext(r.Context()).WithError(err).Warn("Failed to write CalDAV response")
+	}
+}
+
+// writeError переводит ошибку бизнес-логики в HTTP-статус.
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytes):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errBadRequest), errors.Is(err, storage.ErrInvalidEvent):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, storage.ErrEventNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrEventExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		h.logger.WithContext(r.Context()).WithError(err).Error("CalDAV request failed")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
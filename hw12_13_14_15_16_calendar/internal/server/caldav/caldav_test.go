@@ -0,0 +1,294 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackend — Backend поверх inmemory-хранилища.
+type testBackend struct {
+	store *inmemory.Storage
+}
+
+func (b testBackend) GetEvent(ctx context.Context, userID, id string) (storage.Event, error) {
+	return b.store.Get(ctx, userID, id)
+}
+
+func (b testBackend) ListAllEvents(ctx context.Context, userID string) ([]storage.Event, error) {
+	return b.store.ListAll(ctx, userID)
+}
+
+func (b testBackend) CreateEvent(ctx context.Context, userID string, e storage.Event) (storage.Event, error) {
+	e.UserID = userID
+	if err := e.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	return e, b.store.Add(ctx, e)
+}
+
+func (b testBackend) UpdateEvent(ctx context.Context, userID, id string, e storage.Event) (storage.Event, error) {
+	e.ID, e.UserID = id, userID
+	return e, b.store.Update(ctx, userID, id, e)
+}
+
+func (b testBackend) DeleteEvent(ctx context.Context, userID, id string) error {
+	return b.store.Delete(ctx, userID, id)
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	h := New(log, testBackend{store: inmemory.New()}, "/dav", func(r *http.Request) string {
+		userID, _, _ := r.BasicAuth()
+		return userID
+	})
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func do(t *testing.T, method, url, user, body string, headers ...string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if user != "" {
+		req.SetBasicAuth(user, "secret")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(data)
+}
+
+// testMultistatus — ответ 207 в виде, удобном для проверок.
+type testMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Status    string `xml:"DAV: status"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				Inner string `xml:",innerxml"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+func multistatusOf(t *testing.T, resp *http.Response, body string) testMultistatus {
+	t.Helper()
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode, body)
+	var ms testMultistatus
+	require.NoError(t, xml.Unmarshal([]byte(body), &ms))
+	return ms
+}
+
+func eventICS(uid, start, extra string) string {
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:test",
+		"BEGIN:VEVENT", "UID:" + uid, "DTSTAMP:20240101T000000Z",
+		"DTSTART:" + start, "DURATION:PT1H", "SUMMARY:Meeting " + uid, extra,
+		"END:VEVENT", "END:VCALENDAR", "",
+	}, "\r\n")
+}
+
+const calendarPath = "/dav/calendars/user1/default/"
+
+func TestCalDAV_Discovery(t *testing.T) {
+	ts := newTestServer(t)
+
+	// Корень указывает на принципала
+	resp, body := do(t, "PROPFIND", ts.URL+"/dav/", "user1",
+		`<propfind xmlns="DAV:"><prop><current-user-principal/></prop></propfind>`, "Depth", "0")
+	ms := multistatusOf(t, resp, body)
+	require.Len(t, ms.Responses, 1)
+	assert.Contains(t, ms.Responses[0].Propstats[0].Prop.Inner, "/dav/principals/user1/")
+
+	// Принципал — на домашнюю коллекцию, неизвестное свойство возвращается с 404
+	resp, body = do(t, "PROPFIND", ts.URL+"/dav/principals/user1/", "user1",
+		`<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`+
+			`<prop><C:calendar-home-set/><C:calendar-user-address-set/></prop></propfind>`, "Depth", "0")
+	ms = multistatusOf(t, resp, body)
+	require.Len(t, ms.Responses, 1)
+	require.Len(t, ms.Responses[0].Propstats, 2)
+	assert.Contains(t, ms.Responses[0].Propstats[0].Status, "200")
+	assert.Contains(t, ms.Responses[0].Propstats[0].Prop.Inner, "/dav/calendars/user1/")
+	assert.Contains(t, ms.Responses[0].Propstats[1].Status, "404")
+	assert.Contains(t, ms.Responses[0].Propstats[1].Prop.Inner, "calendar-user-address-set")
+
+	// Домашняя коллекция содержит единственный календарь
+	resp, body = do(t, "PROPFIND", ts.URL+"/dav/calendars/user1/", "user1", "", "Depth", "1")
+	ms = multistatusOf(t, resp, body)
+	require.Len(t, ms.Responses, 2)
+	assert.Equal(t, calendarPath, ms.Responses[1].Href)
+	assert.Contains(t, ms.Responses[1].Propstats[0].Prop.Inner, "urn:ietf:params:xml:ns:caldav")
+	assert.Contains(t, ms.Responses[1].Propstats[0].Prop.Inner, `name="VEVENT"`)
+
+	resp, _ = do(t, http.MethodOptions, ts.URL+calendarPath, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("DAV"), "calendar-access")
+}
+
+func TestCalDAV_ReadWrite(t *testing.T) {
+	ts := newTestServer(t)
+	url := ts.URL + calendarPath + "meeting.ics"
+
+	// Создание
+	resp, _ := do(t, http.MethodPut, url, "user1", eventICS("meeting", "20240510T100000Z", ""),
+		"If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	created := resp.Header.Get("ETag")
+	require.NotEmpty(t, created)
+
+	resp, body := do(t, http.MethodGet, url, "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, created, resp.Header.Get("ETag"))
+	assert.Contains(t, body, "UID:meeting")
+	assert.Contains(t, body, "DTSTART:20240510T100000Z")
+
+	// Повторное создание и изменение по устаревшему ETag отклоняются
+	resp, _ = do(t, http.MethodPut, url, "user1", eventICS("meeting", "20240510T110000Z", ""),
+		"If-None-Match", "*")
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do(t, http.MethodPut, url, "user1", eventICS("meeting", "20240510T110000Z", ""),
+		"If-Match", `"stale"`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// Изменение меняет ETag и getctag календаря
+	resp, body = do(t, "PROPFIND", ts.URL+calendarPath, "user1",
+		`<propfind xmlns="DAV:"><prop><getctag xmlns="http://calendarserver.org/ns/"/></prop></propfind>`,
+		"Depth", "0")
+	ctag := multistatusOf(t, resp, body).Responses[0].Propstats[0].Prop.Inner
+
+	resp, _ = do(t, http.MethodPut, url, "user1", eventICS("meeting", "20240510T110000Z", ""),
+		"If-Match", created)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	updated := resp.Header.Get("ETag")
+	assert.NotEqual(t, created, updated)
+
+	resp, body = do(t, "PROPFIND", ts.URL+calendarPath, "user1",
+		`<propfind xmlns="DAV:"><prop><getctag xmlns="http://calendarserver.org/ns/"/><getetag/></prop></propfind>`,
+		"Depth", "1")
+	ms := multistatusOf(t, resp, body)
+	require.Len(t, ms.Responses, 2)
+	assert.NotEqual(t, ctag, ms.Responses[0].Propstats[0].Prop.Inner)
+	assert.Equal(t, calendarPath+"meeting.ics", ms.Responses[1].Href)
+	assert.Contains(t, ms.Responses[1].Propstats[0].Prop.Inner, strings.ReplaceAll(updated, `"`, "&#34;"))
+
+	// Удаление
+	resp, _ = do(t, http.MethodDelete, url, "user1", "", "If-Match", created)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do(t, http.MethodDelete, url, "user1", "", "If-Match", updated)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(t, http.MethodGet, url, "user1", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCalDAV_Reports(t *testing.T) {
+	ts := newTestServer(t)
+	put := func(uid, start, extra string) {
+		resp, body := do(t, http.MethodPut, ts.URL+calendarPath+uid+".ics", "user1", eventICS(uid, start, extra))
+		require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	}
+	put("may", "20240510T100000Z", "")
+	put("june", "20240610T100000Z", "")
+	put("weekly", "20240101T080000Z", "RRULE:FREQ=WEEKLY;COUNT=30")
+
+	query := func(start, end string) []string {
+		resp, body := do(t, "REPORT", ts.URL+calendarPath, "user1",
+			`<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`+
+				`<D:prop><D:getetag/><C:calendar-data/></D:prop>`+
+				`<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">`+
+				`<C:time-range start="`+start+`" end="`+end+`"/>`+
+				`</C:comp-filter></C:comp-filter></C:filter></C:calendar-query>`, "Depth", "1")
+		var hrefs []string
+		for _, r := range multistatusOf(t, resp, body).Responses {
+			hrefs = append(hrefs, strings.TrimPrefix(r.Href, calendarPath))
+			assert.Contains(t, r.Propstats[0].Prop.Inner, "BEGIN:VCALENDAR")
+		}
+		return hrefs
+	}
+
+	assert.ElementsMatch(t, []string{"may.ics", "weekly.ics"}, query("20240501T000000Z", "20240601T000000Z"))
+	// Экземпляр серии 10 июня заканчивается в 9:00
+	assert.ElementsMatch(t, []string{"june.ics"}, query("20240610T093000Z", "20240610T120000Z"))
+	// Последний экземпляр еженедельной серии — 22 июля
+	assert.Empty(t, query("20240723T000000Z", "20240901T000000Z"))
+	// Событие, закончившееся ровно к началу интервала, в него не попадает
+	assert.Empty(t, query("20240610T110000Z", "20240611T000000Z"))
+
+	resp, body := do(t, "REPORT", ts.URL+calendarPath, "user1",
+		`<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`+
+			`<D:prop><D:getetag/></D:prop>`+
+			`<D:href>`+calendarPath+`june.ics</D:href><D:href>`+calendarPath+`missing.ics</D:href>`+
+			`</C:calendar-multiget>`, "Depth", "1")
+	ms := multistatusOf(t, resp, body)
+	require.Len(t, ms.Responses, 2)
+	assert.Contains(t, ms.Responses[0].Propstats[0].Prop.Inner, "getetag")
+	assert.NotContains(t, ms.Responses[0].Propstats[0].Prop.Inner, "calendar-data")
+	assert.Contains(t, ms.Responses[1].Status, "404")
+}
+
+func TestCalDAV_Errors(t *testing.T) {
+	ts := newTestServer(t)
+	resp, _ := do(t, http.MethodPut, ts.URL+calendarPath+"a.ics", "user1", eventICS("a", "20240510T100000Z", ""))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   string
+		body   string
+		status int
+	}{
+		{"no user", "PROPFIND", calendarPath, "", "", http.StatusUnauthorized},
+		{"other user", "PROPFIND", "/dav/calendars/user2/default/", "user1", "", http.StatusForbidden},
+		{"unknown path", "PROPFIND", "/dav/calendars/user1/other/", "user1", "", http.StatusNotFound},
+		{"object not found", http.MethodGet, calendarPath + "missing.ics", "user1", "", http.StatusNotFound},
+		{"uid mismatch", http.MethodPut, calendarPath + "b.ics", "user1",
+			eventICS("c", "20240511T100000Z", ""), http.StatusBadRequest},
+		{"malformed", http.MethodPut, calendarPath + "b.ics", "user1", "BEGIN:VEVENT", http.StatusBadRequest},
+		{"date busy", http.MethodPut, calendarPath + "b.ics", "user1",
+			eventICS("b", "20240510T103000Z", ""), http.StatusConflict},
+		{"id of another user", http.MethodPut, calendarPath + "a.ics", "user2",
+			eventICS("a", "20240510T100000Z", ""), http.StatusForbidden},
+		{"bad xml", "PROPFIND", calendarPath, "user1", "<propfind", http.StatusBadRequest},
+		{"unsupported report", "REPORT", calendarPath, "user1",
+			`<sync-collection xmlns="DAV:"/>`, http.StatusForbidden},
+		{"put collection", http.MethodPut, calendarPath, "user1", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := do(t, tc.method, ts.URL+tc.path, tc.user, tc.body)
+			assert.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+
+	// Клиенту без пользователя предлагается Basic-авторизация
+	resp, _ = do(t, "PROPFIND", ts.URL+calendarPath, "", "")
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Basic")
+
+	// Событие с тем же ID у другого пользователя — конфликт
+	resp, _ = do(t, http.MethodPut, ts.URL+"/dav/calendars/user2/default/a.ics", "user2",
+		eventICS("a", "20240510T100000Z", ""))
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
@@ -0,0 +1,407 @@
+package caldav
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/ical"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+)
+
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+// timeRangeLayout — формат атрибутов start и end в CALDAV:time-range.
+const timeRangeLayout = "20060102T150405Z"
+
+var calendarDataName = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"response"`
+}
+
+type response struct {
+	Href      string     `xml:"href"`
+	Propstats []propstat `xml:"propstat,omitempty"`
+	Status    string     `xml:"status,omitempty"`
+}
+
+type propstat struct {
+	Prop   propList `xml:"prop"`
+	Status string   `xml:"status"`
+}
+
+type propList struct {
+	Props []property `xml:",any"`
+}
+
+// property — свойство ресурса. Inner — готовый XML, поэтому вложенные
+// элементы объявляют своё пространство имён сами.
+type property struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+func statusResponse(href string, code int) response {
+	return response{Href: href, Status: statusLine(code)}
+}
+
+// propRequest — какие свойства запрошены в PROPFIND или REPORT.
+type propRequest struct {
+	all   bool // allprop или пустое тело
+	names bool // propname
+	props []xml.Name
+}
+
+func (p propRequest) wants(name xml.Name) bool {
+	for _, n := range p.props {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalXML читает DAV:prop — список имён свойств.
+func (p *propRequest) UnmarshalXML(d *xml.Decoder, _ xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			p.props = append(p.props, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+type propfind struct {
+	XMLName  xml.Name    `xml:"DAV: propfind"`
+	AllProp  *struct{}   `xml:"DAV: allprop"`
+	PropName *struct{}   `xml:"DAV: propname"`
+	Prop     propRequest `xml:"DAV: prop"`
+}
+
+func decodePropfind(r io.Reader) (propRequest, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return propRequest{}, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return propRequest{all: true}, nil
+	}
+	var req propfind
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return propRequest{}, fmt.Errorf("%w: invalid XML: %w", errBadRequest, err)
+	}
+	switch {
+	case req.PropName != nil:
+		return propRequest{names: true}, nil
+	case req.AllProp != nil:
+		return propRequest{all: true}, nil
+	}
+	return req.Prop, nil
+}
+
+// resource — ресурс со всеми своими свойствами.
+type resource struct {
+	href  string
+	props []property
+}
+
+// response оставляет запрошенные свойства; отсутствующие попадают в propstat со статусом 404.
+func (r resource) response(req propRequest) response {
+	resp := response{Href: r.href}
+	if req.all || req.names {
+		found := propstat{Status: statusLine(http.StatusOK)}
+		for _, p := range r.props {
+			if req.names {
+				p.Inner = ""
+			}
+			found.Prop.Props = append(found.Prop.Props, p)
+		}
+		resp.Propstats = append(resp.Propstats, found)
+		return resp
+	}
+
+	found := propstat{Status: statusLine(http.StatusOK)}
+	missing := propstat{Status: statusLine(http.StatusNotFound)}
+	for _, name := range req.props {
+		p, ok := r.prop(name)
+		if ok {
+			found.Prop.Props = append(found.Prop.Props, p)
+		} else {
+			missing.Prop.Props = append(missing.Prop.Props, property{XMLName: name})
+		}
+	}
+	for _, ps := range []propstat{found, missing} {
+		if len(ps.Prop.Props) > 0 {
+			resp.Propstats = append(resp.Propstats, ps)
+		}
+	}
+	return resp
+}
+
+func (r resource) prop(name xml.Name) (property, bool) {
+	for _, p := range r.props {
+		if p.XMLName == name {
+			return p, true
+		}
+	}
+	return property{}, false
+}
+
+func rawProp(space, local, inner string) property {
+	return property{XMLName: xml.Name{Space: space, Local: local}, Inner: inner}
+}
+
+func textProp(space, local, text string) property {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return rawProp(space, local, buf.String())
+}
+
+func hrefProp(space, local, href string) property {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(href))
+	return rawProp(space, local, `<href xmlns="DAV:">`+buf.String()+`</href>`)
+}
+
+func (h *Handler) root(userID string) resource {
+	return resource{
+		href: h.href(target{kind: kindRoot}),
+		props: []property{
+			rawProp(nsDAV, "resourcetype", `<collection xmlns="DAV:"/>`),
+			hrefProp(nsDAV, "current-user-principal", h.href(target{kind: kindPrincipal, user: userID})),
+		},
+	}
+}
+
+func (h *Handler) principal(userID string) resource {
+	principal := h.href(target{kind: kindPrincipal, user: userID})
+	return resource{
+		href: principal,
+		props: []property{
+			rawProp(nsDAV, "resourcetype", `<principal xmlns="DAV:"/>`),
+			textProp(nsDAV, "displayname", userID),
+			hrefProp(nsDAV, "current-user-principal", principal),
+			hrefProp(nsDAV, "principal-URL", principal),
+			hrefProp(nsCalDAV, "calendar-home-set", h.href(target{kind: kindHome, user: userID})),
+		},
+	}
+}
+
+func (h *Handler) home(userID string) resource {
+	return resource{
+		href: h.href(target{kind: kindHome, user: userID}),
+		props: []property{
+			rawProp(nsDAV, "resourcetype", `<collection xmlns="DAV:"/>`),
+			hrefProp(nsDAV, "current-user-principal", h.href(target{kind: kindPrincipal, user: userID})),
+		},
+	}
+}
+
+// calendar описывает календарь; getctag меняется при любом изменении его событий.
+func (h *Handler) calendar(userID string, events []storage.Event) resource {
+	tags := make([]string, 0, len(events))
+	for _, e := range events {
+		tags = append(tags, e.ID+":"+etag(e))
+	}
+	sort.Strings(tags)
+	sum := sha256.Sum256([]byte(strings.Join(tags, "\n")))
+
+	return resource{
+		href: h.href(target{kind: kindCalendar, user: userID}),
+		props: []property{
+			rawProp(nsDAV, "resourcetype", `<collection xmlns="DAV:"/><calendar xmlns="urn:ietf:params:xml:ns:caldav"/>`),
+			textProp(nsDAV, "displayname", "Calendar"),
+			hrefProp(nsDAV, "current-user-principal", h.href(target{kind: kindPrincipal, user: userID})),
+			hrefProp(nsDAV, "owner", h.href(target{kind: kindPrincipal, user: userID})),
+			rawProp(nsDAV, "current-user-privilege-set", privileges("read", "write", "write-content", "bind", "unbind")),
+			rawProp(nsDAV, "supported-report-set", supportedReports("calendar-query", "calendar-multiget")),
+			rawProp(nsCalDAV, "supported-calendar-component-set", `<comp xmlns="urn:ietf:params:xml:ns:caldav" name="VEVENT"/>`),
+			textProp(nsCS, "getctag", hex.EncodeToString(sum[:8])),
+		},
+	}
+}
+
+func privileges(names ...string) string {
+	var b strings.Builder
+	for _, n := range names {
+		b.WriteString(`<privilege xmlns="DAV:"><` + n + `/></privilege>`)
+	}
+	return b.String()
+}
+
+func supportedReports(names ...string) string {
+	var b strings.Builder
+	for _, n := range names {
+		b.WriteString(`<supported-report xmlns="DAV:"><report>`)
+		b.WriteString(`<` + n + ` xmlns="` + nsCalDAV + `"/>`)
+		b.WriteString(`</report></supported-report>`)
+	}
+	return b.String()
+}
+
+// object описывает событие. Тело .ics формируется, только если запрошено calendar-data.
+func (h *Handler) object(userID string, e storage.Event, withData bool) (resource, error) {
+	res := resource{
+		href: h.href(target{kind: kindObject, user: userID, id: e.ID}),
+		props: []property{
+			rawProp(nsDAV, "resourcetype", ""),
+			textProp(nsDAV, "getetag", etag(e)),
+			textProp(nsDAV, "getcontenttype", calendarType+"; component=vevent"),
+		},
+	}
+	if withData {
+		var buf bytes.Buffer
+		if err := ical.Encode(&buf, []storage.Event{e}); err != nil {
+			return resource{}, err
+		}
+		res.props = append(res.props, textProp(nsCalDAV, "calendar-data", buf.String()))
+	}
+	return res, nil
+}
+
+// etag вычисляется по содержимому события, поэтому не зависит от хранилища
+// и часового пояса, в котором оно вернуло время.
+func etag(e storage.Event) string {
+	e.DateTime = e.DateTime.UTC()
+	exDates := make([]time.Time, 0, len(e.ExDates))
+	for _, t := range e.ExDates {
+		exDates = append(exDates, t.UTC())
+	}
+	e.ExDates = exDates
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// report — тело REPORT calendar-query или calendar-multiget.
+type report struct {
+	XMLName xml.Name
+	Prop    propRequest `xml:"DAV: prop"`
+	Hrefs   []string    `xml:"DAV: href"`
+	Filter  struct {
+		Comp *compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// compFilter — CALDAV:comp-filter. Фильтры по свойствам (prop-filter) не поддерживаются и игнорируются.
+type compFilter struct {
+	Name         string       `xml:"name,attr"`
+	IsNotDefined *struct{}    `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	Comps        []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+
+	start, end time.Time
+}
+
+// parse разбирает границы всех time-range фильтра.
+func (f *compFilter) parse() error {
+	if f == nil {
+		return nil
+	}
+	if tr := f.TimeRange; tr != nil {
+		var err error
+		if tr.Start != "" {
+			if tr.start, err = time.Parse(timeRangeLayout, tr.Start); err != nil {
+				return fmt.Errorf("%w: invalid time-range start %q", errBadRequest, tr.Start)
+			}
+		}
+		if tr.End != "" {
+			if tr.end, err = time.Parse(timeRangeLayout, tr.End); err != nil {
+				return fmt.Errorf("%w: invalid time-range end %q", errBadRequest, tr.End)
+			}
+		}
+		if tr.Start == "" && tr.End == "" {
+			return fmt.Errorf("%w: time-range requires start or end", errBadRequest)
+		}
+	}
+	for i := range f.Comps {
+		if err := f.Comps[i].parse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match проверяет событие фильтром верхнего уровня (VCALENDAR). Без фильтра подходят все события.
+func (f *compFilter) match(e storage.Event) bool {
+	if f == nil {
+		return true
+	}
+	if f.Name != "VCALENDAR" {
+		return false
+	}
+	for _, c := range f.Comps {
+		if !c.matchEvent(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchEvent проверяет фильтр второго уровня. Кроме VEVENT, других компонентов в календаре нет.
+func (f compFilter) matchEvent(e storage.Event) bool {
+	if f.Name != "VEVENT" {
+		return f.IsNotDefined != nil
+	}
+	if f.IsNotDefined != nil {
+		return false
+	}
+	return f.TimeRange == nil || f.TimeRange.match(e)
+}
+
+// match проверяет, пересекается ли хотя бы один экземпляр события с интервалом (RFC 4791, 9.9).
+func (tr *timeRange) match(e storage.Event) bool {
+	duration := time.Duration(e.Duration) * time.Second
+	overlaps := func(start time.Time) bool {
+		if duration == 0 {
+			return !start.Before(tr.start)
+		}
+		return start.Add(duration).After(tr.start)
+	}
+
+	if tr.end.IsZero() {
+		last, ok := e.LastOccurrence()
+		return !ok || overlaps(last)
+	}
+	if tr.start.IsZero() {
+		return e.DateTime.Before(tr.end)
+	}
+	for _, o := range e.Occurrences(tr.start.Add(-duration), tr.end) {
+		if overlaps(o.DateTime) {
+			return true
+		}
+	}
+	return false
+}
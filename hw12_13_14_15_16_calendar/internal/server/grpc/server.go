@@ -0,0 +1,351 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/api/eventpb"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/logger"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Application — бизнес-логика календаря, которую обслуживает GRPC API.
+type Application interface {
+	CreateEvent(ctx context.Context, userID string, event storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, userID, id string, event storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, userID, id string) error
+	GetEvent(ctx context.Context, userID, id string) (storage.Event, error)
+	ListEvents(
+		ctx context.Context, userID string, from, to time.Time, cursor string, limit int,
+	) ([]storage.Event, string, error)
+	ListDay(ctx context.Context, userID string, date time.Time, mode storage.RangeMode) ([]storage.Event, error)
+	ListWeek(ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode) ([]storage.Event, error)
+	ListMonth(ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode) ([]storage.Event, error)
+}
+
+type Server struct {
+	eventpb.UnimplementedEventServiceServer
+
+	logger *logrus.Logger
+	app    Application
+	addr   string
+	server *grpc.Server
+}
+
+func New(logger *logrus.Logger, app Application, host string, port int) *Server {
+	s := &Server{
+		logger: logger,
+		app:    app,
+		addr:   fmt.Sprintf("%s:%d", host, port),
+	}
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		metricsInterceptor, requestIDInterceptor, s.loggingInterceptor, userInterceptor,
+	))
+	eventpb.RegisterEventServiceServer(s.server, s)
+	return s
+}
+
+func (s *Server) Start() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen %s: %w", s.addr, err)
+	}
+	return s.Serve(lis)
+}
+
+// Serve обслуживает запросы на уже открытом listener (используется в тестах).
+func (s *Server) Serve(lis net.Listener) error {
+	s.logger.Infof("Starting GRPC server on %s", lis.Addr())
+	return s.server.Serve(lis)
+}
+
+// Stop дожидается завершения текущих вызовов. Если ctx истёк раньше,
+// оставшиеся вызовы прерываются.
+func (s *Server) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Server) Create(ctx context.Context, req *eventpb.CreateRequest) (*eventpb.CreateResponse, error) {
+	e, err := fromProto(req.GetEvent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	event, err := s.app.CreateEvent(ctx, userIDFromContext(ctx), e)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.CreateResponse{Event: toProto(event)}, nil
+}
+
+func (s *Server) Update(ctx context.Context, req *eventpb.UpdateRequest) (*eventpb.UpdateResponse, error) {
+	e, err := fromProto(req.GetEvent())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	event, err := s.app.UpdateEvent(ctx, userIDFromContext(ctx), req.GetId(), e)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.UpdateResponse{Event: toProto(event)}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *eventpb.DeleteRequest) (*eventpb.DeleteResponse, error) {
+	if err := s.app.DeleteEvent(ctx, userIDFromContext(ctx), req.GetId()); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.DeleteResponse{}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *eventpb.GetRequest) (*eventpb.GetResponse, error) {
+	event, err := s.app.GetEvent(ctx, userIDFromContext(ctx), req.GetId())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.GetResponse{Event: toProto(event)}, nil
+}
+
+func (s *Server) List(ctx context.Context, req *eventpb.ListRangeRequest) (*eventpb.ListRangeResponse, error) {
+	if req.GetFrom() == nil || req.GetTo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	events, next, err := s.app.ListEvents(ctx, userIDFromContext(ctx),
+		req.GetFrom().AsTime(), req.GetTo().AsTime(), req.GetCursor(), int(req.GetLimit()))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.ListRangeResponse{Events: make([]*eventpb.Event, 0, len(events)), NextCursor: next}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toProto(e))
+	}
+	return resp, nil
+}
+
+func (s *Server) ListDay(ctx context.Context, req *eventpb.ListRequest) (*eventpb.ListResponse, error) {
+	return s.list(ctx, req, s.app.ListDay)
+}
+
+func (s *Server) ListWeek(ctx context.Context, req *eventpb.ListRequest) (*eventpb.ListResponse, error) {
+	return s.list(ctx, req, s.app.ListWeek)
+}
+
+func (s *Server) ListMonth(ctx context.Context, req *eventpb.ListRequest) (*eventpb.ListResponse, error) {
+	return s.list(ctx, req, s.app.ListMonth)
+}
+
+func (s *Server) list(
+	ctx context.Context,
+	req *eventpb.ListRequest,
+	list func(context.Context, string, time.Time, storage.RangeMode) ([]storage.Event, error),
+) (*eventpb.ListResponse, error) {
+	if req.GetDate() == nil {
+		return nil, status.Error(codes.InvalidArgument, "date is required")
+	}
+	mode := storage.StartsInRange
+	if req.GetOverlapping() {
+		mode = storage.OverlapsRange
+	}
+	events, err := list(ctx, userIDFromContext(ctx), req.GetDate().AsTime(), mode)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.ListResponse{Events: make([]*eventpb.Event, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toProto(e))
+	}
+	return resp, nil
+}
+
+// toStatus переводит ошибку бизнес-логики в GRPC-статус.
+func (s *Server) toStatus(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrInvalidEvent), errors.Is(err, storage.ErrInvalidQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrEventNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrEventExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		// Детали внутренних ошибок наружу не отдаём
+		s.logger.WithContext(ctx).WithError(err).Error("Request failed")
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// UserIDMetadataKey — ключ метаданных, в котором клиент передаёт ID пользователя.
+const UserIDMetadataKey = "x-user-id"
+
+type userIDKey struct{}
+
+// userInterceptor извлекает ID пользователя из метаданных и отклоняет запросы без него.
+func userInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	var userID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(UserIDMetadataKey); len(values) > 0 {
+			userID = values[0]
+		}
+	}
+	if userID == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "user id is required in %q metadata", UserIDMetadataKey)
+	}
+	return handler(context.WithValue(ctx, userIDKey{}, userID), req)
+}
+
+func userIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+// metricsInterceptor считает вызовы и время их обработки по методу и коду статуса.
+func metricsInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	code := status.Code(err).String()
+	metrics.GRPCRequests.WithLabelValues(info.FullMethod, code).Inc()
+	metrics.GRPCDuration.WithLabelValues(info.FullMethod, code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// RequestIDMetadataKey — ключ метаданных с ID запроса, аналог заголовка X-Request-ID в HTTP API.
+const RequestIDMetadataKey = "x-request-id"
+
+// requestIDInterceptor берёт ID запроса из метаданных или генерирует новый
+// и возвращает его клиенту в заголовке ответа.
+func requestIDInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	id = logger.EnsureRequestID(id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+	return handler(logger.WithRequestID(ctx, id), req)
+}
+
+// loggingInterceptor — логирует каждый запрос по аналогии с HTTP API.
+func (s *Server) loggingInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	addr := "-"
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	s.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"remote_addr": addr,
+		"method":      info.FullMethod,
+		"code":        status.Code(err).String(),
+		"duration_ms": time.Since(start).Milliseconds(),
+	}).Info("GRPC request")
+	return resp, err
+}
+
+func toProto(e storage.Event) *eventpb.Event {
+	pb := &eventpb.Event{
+		Id:           e.ID,
+		Title:        e.Title,
+		Datetime:     timestamppb.New(e.DateTime),
+		Duration:     e.Duration,
+		Description:  e.Description,
+		UserId:       e.UserID,
+		NotifyBefore: e.NotifyBefore,
+		Rrule:        e.RRule,
+		Exdates:      make([]*timestamppb.Timestamp, 0, len(e.ExDates)),
+		Timezone:     timezone(e.DateTime),
+	}
+	for _, t := range e.ExDates {
+		pb.Exdates = append(pb.Exdates, timestamppb.New(t))
+	}
+	return pb
+}
+
+func fromProto(e *eventpb.Event) (storage.Event, error) {
+	event := storage.Event{
+		ID:           e.GetId(),
+		Title:        e.GetTitle(),
+		Duration:     e.GetDuration(),
+		Description:  e.GetDescription(),
+		UserID:       e.GetUserId(),
+		NotifyBefore: e.GetNotifyBefore(),
+		RRule:        e.GetRrule(),
+	}
+	loc, err := parseTimezone(e.GetTimezone())
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if e.GetDatetime() != nil {
+		event.DateTime = e.GetDatetime().AsTime().In(loc)
+	}
+	for _, t := range e.GetExdates() {
+		event.ExDates = append(event.ExDates, t.AsTime().In(loc))
+	}
+	return event, nil
+}
+
+// timezone возвращает имя пояса t для поля timezone, а если имени нет — смещение.
+func timezone(t time.Time) string {
+	if name, _ := storage.ZoneOf(t); name != "" {
+		return name
+	}
+	return t.Format("-07:00")
+}
+
+// parseTimezone разбирает поле timezone: имя IANA или смещение ±hh:mm (Z); пусто — UTC.
+func parseTimezone(s string) (*time.Location, error) {
+	if s == "" {
+		return time.UTC, nil
+	}
+	if t, err := time.Parse("Z07:00", s); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone("", offset), nil
+	}
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", s)
+	}
+	return loc, nil
+}
@@ -0,0 +1,299 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/api/eventpb"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testApp — минимальная реализация Application поверх inmemory-хранилища.
+type testApp struct {
+	store  *inmemory.Storage
+	nextID int
+}
+
+func (a *testApp) CreateEvent(ctx context.Context, userID string, event storage.Event) (storage.Event, error) {
+	event.UserID = userID
+	if err := event.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	a.nextID++
+	event.ID = fmt.Sprintf("event-%d", a.nextID)
+	return event, a.store.Add(ctx, event)
+}
+
+func (a *testApp) UpdateEvent(ctx context.Context, userID, id string, event storage.Event) (storage.Event, error) {
+	event.ID, event.UserID = id, userID
+	return event, a.store.Update(ctx, userID, id, event)
+}
+
+func (a *testApp) DeleteEvent(ctx context.Context, userID, id string) error {
+	return a.store.Delete(ctx, userID, id)
+}
+
+func (a *testApp) GetEvent(ctx context.Context, userID, id string) (storage.Event, error) {
+	return a.store.Get(ctx, userID, id)
+}
+
+func (a *testApp) ListEvents(
+	ctx context.Context, userID string, from, to time.Time, cursor string, limit int,
+) ([]storage.Event, string, error) {
+	if limit == 0 {
+		limit = 100
+	}
+	return a.store.List(ctx, userID, from, to, cursor, limit)
+}
+
+func (a *testApp) ListDay(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListDay(ctx, userID, date, mode)
+}
+
+func (a *testApp) ListWeek(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListWeek(ctx, userID, date, mode)
+}
+
+func (a *testApp) ListMonth(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListMonth(ctx, userID, date, mode)
+}
+
+func withUser(userID string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), UserIDMetadataKey, userID)
+}
+
+func newTestClient(t *testing.T) eventpb.EventServiceClient {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := New(log, &testApp{store: inmemory.New()}, "localhost", 0)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(func() {
+		_ = s.Stop(context.Background())
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return eventpb.NewEventServiceClient(conn)
+}
+
+func TestServer_CRUD(t *testing.T) {
+	client := newTestClient(t)
+	ctx := withUser("user1")
+	date := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+
+	event := &eventpb.Event{
+		Title:    "Standup",
+		Datetime: timestamppb.New(date),
+		Duration: 900,
+	}
+
+	// Create
+	created, err := client.Create(ctx, &eventpb.CreateRequest{Event: event})
+	require.NoError(t, err)
+	id := created.GetEvent().GetId()
+	assert.NotEmpty(t, id)
+	assert.Equal(t, "user1", created.GetEvent().GetUserId())
+
+	// ListDay
+	list, err := client.ListDay(ctx, &eventpb.ListRequest{Date: timestamppb.New(date)})
+	require.NoError(t, err)
+	require.Len(t, list.GetEvents(), 1)
+	assert.Equal(t, id, list.GetEvents()[0].GetId())
+	assert.True(t, date.Equal(list.GetEvents()[0].GetDatetime().AsTime()))
+
+	// Update
+	event.Title = "Retro"
+	updated, err := client.Update(ctx, &eventpb.UpdateRequest{Id: id, Event: event})
+	require.NoError(t, err)
+	assert.Equal(t, "Retro", updated.GetEvent().GetTitle())
+
+	// ListWeek / ListMonth
+	list, err = client.ListWeek(ctx, &eventpb.ListRequest{Date: timestamppb.New(date)})
+	require.NoError(t, err)
+	assert.Len(t, list.GetEvents(), 1)
+	list, err = client.ListMonth(ctx, &eventpb.ListRequest{Date: timestamppb.New(date)})
+	require.NoError(t, err)
+	assert.Len(t, list.GetEvents(), 1)
+
+	// Get
+	got, err := client.Get(ctx, &eventpb.GetRequest{Id: id})
+	require.NoError(t, err)
+	assert.Equal(t, "Retro", got.GetEvent().GetTitle())
+
+	// Delete
+	_, err = client.Delete(ctx, &eventpb.DeleteRequest{Id: id})
+	require.NoError(t, err)
+}
+
+func TestServer_List(t *testing.T) {
+	client := newTestClient(t)
+	ctx := withUser("user1")
+	start := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		_, err := client.Create(ctx, &eventpb.CreateRequest{Event: &eventpb.Event{
+			Title:    fmt.Sprintf("Event %d", i),
+			Datetime: timestamppb.New(start.Add(time.Duration(i) * time.Hour)),
+			Duration: 600,
+		}})
+		require.NoError(t, err)
+	}
+
+	req := &eventpb.ListRangeRequest{
+		From:  timestamppb.New(start),
+		To:    timestamppb.New(start.AddDate(0, 0, 1)),
+		Limit: 2,
+	}
+	var titles []string
+	for {
+		resp, err := client.List(ctx, req)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(resp.GetEvents()), 2)
+		for _, e := range resp.GetEvents() {
+			titles = append(titles, e.GetTitle())
+		}
+		if resp.GetNextCursor() == "" {
+			break
+		}
+		req.Cursor = resp.GetNextCursor()
+	}
+	assert.Equal(t, []string{"Event 0", "Event 1", "Event 2", "Event 3", "Event 4"}, titles)
+
+	_, err := client.List(ctx, &eventpb.ListRangeRequest{From: timestamppb.New(start)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	req.Cursor = "not a cursor"
+	_, err = client.List(ctx, req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Errors(t *testing.T) {
+	client := newTestClient(t)
+	ctx := withUser("user1")
+	event := &eventpb.Event{
+		Title:    "Standup",
+		Datetime: timestamppb.New(time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)),
+	}
+
+	_, err := client.Create(ctx, &eventpb.CreateRequest{Event: event})
+	require.NoError(t, err)
+
+	_, err = client.Create(ctx, &eventpb.CreateRequest{Event: event})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.Create(ctx, &eventpb.CreateRequest{Event: &eventpb.Event{}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Update(ctx, &eventpb.UpdateRequest{Id: "missing", Event: event})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Delete(ctx, &eventpb.DeleteRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.ListDay(ctx, &eventpb.ListRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Без ID пользователя запрос отклоняется
+	_, err = client.Create(context.Background(), &eventpb.CreateRequest{Event: event})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Чужое событие недоступно
+	created, err := client.Create(withUser("user2"), &eventpb.CreateRequest{Event: event})
+	require.NoError(t, err)
+	_, err = client.Delete(ctx, &eventpb.DeleteRequest{Id: created.GetEvent().GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Get(ctx, &eventpb.GetRequest{Id: created.GetEvent().GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_TimeZone(t *testing.T) {
+	client := newTestClient(t)
+	ctx := withUser("user1")
+	msk := time.FixedZone("", 3*3600)
+	// Понедельник 01:00 по Москве — воскресенье по UTC
+	start := time.Date(2024, 1, 1, 1, 0, 0, 0, msk)
+
+	created, err := client.Create(ctx, &eventpb.CreateRequest{Event: &eventpb.Event{
+		Title:    "Planning",
+		Datetime: timestamppb.New(start),
+		Duration: 600,
+		Rrule:    "FREQ=WEEKLY;BYDAY=MO;COUNT=3",
+		Timezone: "+03:00",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "+03:00", created.GetEvent().GetTimezone())
+
+	resp, err := client.List(ctx, &eventpb.ListRangeRequest{
+		From:  timestamppb.New(start.AddDate(0, 0, -7)),
+		To:    timestamppb.New(start.AddDate(0, 1, 0)),
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.GetEvents(), 3)
+	for _, e := range resp.GetEvents() {
+		local := e.GetDatetime().AsTime().In(msk)
+		assert.Equal(t, "01:00 Monday", local.Format("15:04 Monday"))
+		assert.Equal(t, "+03:00", e.GetTimezone())
+	}
+
+	created, err = client.Create(ctx, &eventpb.CreateRequest{Event: &eventpb.Event{
+		Title:    "Named",
+		Datetime: timestamppb.New(start.AddDate(0, 2, 0)),
+		Timezone: "Europe/Moscow",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Moscow", created.GetEvent().GetTimezone())
+
+	_, err = client.Create(ctx, &eventpb.CreateRequest{Event: &eventpb.Event{
+		Title:    "Nowhere",
+		Datetime: timestamppb.New(start.AddDate(0, 3, 0)),
+		Timezone: "Mars/Olympus",
+	}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_RequestID(t *testing.T) {
+	client := newTestClient(t)
+	ctx := metadata.AppendToOutgoingContext(withUser("user1"), RequestIDMetadataKey, "req-42")
+
+	// ID возвращается клиенту даже при ошибке
+	var header metadata.MD
+	_, err := client.Get(ctx, &eventpb.GetRequest{Id: "missing"}, grpc.Header(&header))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, []string{"req-42"}, header.Get(RequestIDMetadataKey))
+
+	_, err = client.ListDay(withUser("user1"), &eventpb.ListRequest{Date: timestamppb.Now()}, grpc.Header(&header))
+	require.NoError(t, err)
+	require.Len(t, header.Get(RequestIDMetadataKey), 1)
+	assert.Len(t, header.Get(RequestIDMetadataKey)[0], 36)
+}
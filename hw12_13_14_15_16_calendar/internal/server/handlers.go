@@ -0,0 +1,302 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/server/caldav"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+)
+
+// CalDAVPrefix — путь, под которым доступен CalDAV-сервер.
+const CalDAVPrefix = "/dav"
+
+// dateLayout — формат параметра date в запросах списков.
+const dateLayout = "2006-01-02"
+
+var (
+	errBadRequest   = errors.New("bad request")
+	errUnauthorized = errors.New("user id is required in " + UserIDHeader + " header")
+)
+
+// eventDTO — представление события в JSON API.
+type eventDTO struct {
+	ID           string      `json:"id"`
+	Title        string      `json:"title"`
+	DateTime     time.Time   `json:"datetime"`
+	Duration     int64       `json:"duration"` // seconds
+	Description  string      `json:"description,omitempty"`
+	UserID       string      `json:"user_id"`
+	NotifyBefore int64       `json:"notify_before,omitempty"` // seconds
+	RRule        string      `json:"rrule,omitempty"`
+	ExDates      []time.Time `json:"exdates,omitempty"`
+}
+
+type eventsResponse struct {
+	Events     []eventDTO `json:"events"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+func toDTO(e storage.Event) eventDTO {
+	return eventDTO{
+		ID:           e.ID,
+		Title:        e.Title,
+		DateTime:     e.DateTime,
+		Duration:     e.Duration,
+		Description:  e.Description,
+		UserID:       e.UserID,
+		NotifyBefore: e.NotifyBefore,
+		RRule:        e.RRule,
+		ExDates:      e.ExDates,
+	}
+}
+
+func (d eventDTO) toEvent() storage.Event {
+	return storage.Event{
+		ID:           d.ID,
+		Title:        d.Title,
+		DateTime:     d.DateTime,
+		Duration:     d.Duration,
+		Description:  d.Description,
+		UserID:       d.UserID,
+		NotifyBefore: d.NotifyBefore,
+		RRule:        d.RRule,
+		ExDates:      d.ExDates,
+	}
+}
+
+func (s *Server) routes() http.Handler {
+	events := http.NewServeMux()
+	events.HandleFunc("POST /events", s.handleCreate)
+	events.HandleFunc("PUT /events/{id}", s.handleUpdate)
+	events.HandleFunc("DELETE /events/{id}", s.handleDelete)
+	events.HandleFunc("GET /events/{id}", s.handleGet)
+	events.HandleFunc("GET /events", s.handleListRange)
+	events.HandleFunc("GET /events/search", s.handleSearch)
+	events.HandleFunc("GET /events/day", s.handleList(s.app.ListDay))
+	events.HandleFunc("GET /events/week", s.handleList(s.app.ListWeek))
+	events.HandleFunc("GET /events/month", s.handleList(s.app.ListMonth))
+	events.HandleFunc("POST /events/import", s.handleImport)
+	events.HandleFunc("GET /events/export", s.handleExport)
+
+	mux := http.NewServeMux()
+	mux.Handle("/events", s.userMiddleware(withRoute(events)))
+	mux.Handle("/events/", s.userMiddleware(withRoute(events)))
+	mux.Handle(CalDAVPrefix+"/", caldav.New(s.logger, s.app, CalDAVPrefix, davUser))
+	mux.Handle("/.well-known/caldav", http.RedirectHandler(CalDAVPrefix+"/", http.StatusMovedPermanently))
+	mux.Handle("GET "+metrics.Path, metrics.Handler())
+	mux.Handle("GET "+health.LivePath, health.LiveHandler())
+	mux.Handle("GET "+health.ReadyPath, s.checks.ReadyHandler())
+	return mux
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	dto, err := decodeEvent(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	event, err := s.app.CreateEvent(r.Context(), userIDFromContext(r.Context()), dto.toEvent())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusCreated, toDTO(event))
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	dto, err := decodeEvent(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	event, err := s.app.UpdateEvent(r.Context(), userIDFromContext(r.Context()), r.PathValue("id"), dto.toEvent())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, toDTO(event))
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.app.DeleteEvent(r.Context(), userIDFromContext(r.Context()), r.PathValue("id")); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	event, err := s.app.GetEvent(r.Context(), userIDFromContext(r.Context()), r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, toDTO(event))
+}
+
+// handleListRange отдаёт страницу экземпляров событий, начинающихся в [from, to).
+// Параметры: from и to в RFC 3339, cursor — next_cursor предыдущей страницы, limit — размер страницы.
+func (s *Server) handleListRange(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		s.writeError(w, r, fmt.Errorf("%w: from must be in RFC 3339 format", errBadRequest))
+		return
+	}
+	to, err := time.Parse(time.RFC3339, query.Get("to"))
+	if err != nil {
+		s.writeError(w, r, fmt.Errorf("%w: to must be in RFC 3339 format", errBadRequest))
+		return
+	}
+	limit, err := parseLimit(query.Get("limit"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	events, next, err := s.app.ListEvents(r.Context(), userIDFromContext(r.Context()),
+		from, to, query.Get("cursor"), limit)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	resp := eventsResponse{Events: make([]eventDTO, 0, len(events)), NextCursor: next}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toDTO(e))
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// handleSearch ищет события по словам из параметра q; limit — число результатов.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := parseLimit(query.Get("limit"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	events, err := s.app.SearchEvents(r.Context(), userIDFromContext(r.Context()), query.Get("q"), limit)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	resp := eventsResponse{Events: make([]eventDTO, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toDTO(e))
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// parseLimit разбирает необязательный параметр limit; пустой — 0 (значение по умолчанию).
+func parseLimit(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: limit must be an integer", errBadRequest)
+	}
+	return limit, nil
+}
+
+func (s *Server) handleList(
+	list func(context.Context, string, time.Time, storage.RangeMode) ([]storage.Event, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		date, err := time.Parse(dateLayout, query.Get("date"))
+		if err != nil {
+			s.writeError(w, r, fmt.Errorf("%w: date must be in format %s", errBadRequest, dateLayout))
+			return
+		}
+		mode := storage.StartsInRange
+		if v := query.Get("overlapping"); v != "" {
+			overlapping, err := strconv.ParseBool(v)
+			if err != nil {
+				s.writeError(w, r, fmt.Errorf("%w: overlapping must be a boolean", errBadRequest))
+				return
+			}
+			if overlapping {
+				mode = storage.OverlapsRange
+			}
+		}
+		events, err := list(r.Context(), userIDFromContext(r.Context()), date, mode)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		resp := eventsResponse{Events: make([]eventDTO, 0, len(events))}
+		for _, e := range events {
+			resp.Events = append(resp.Events, toDTO(e))
+		}
+		s.writeJSON(w, r, http.StatusOK, resp)
+	}
+}
+
+// maxEventSize ограничивает размер JSON с одним событием, как и тело одного объекта в CalDAV.
+const maxEventSize = 1 << 20
+
+func decodeEvent(w http.ResponseWriter, r *http.Request) (eventDTO, error) {
+	var dto eventDTO
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEventSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&dto); err != nil {
+		return eventDTO{}, fmt.Errorf("%w: invalid JSON: %w", errBadRequest, err)
+	}
+	return dto, nil
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to write response")
+	}
+}
+
+// writeError переводит ошибку бизнес-логики в HTTP-статус и тело ответа.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := http.StatusInternalServerError, "internal"
+	message := err.Error()
+
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytes):
+		status, code = http.StatusRequestEntityTooLarge, "too_large"
+		message = fmt.Sprintf("request body must not exceed %d bytes", maxBytes.Limit)
+	case errors.Is(err, errBadRequest), errors.Is(err, storage.ErrInvalidEvent),
+		errors.Is(err, storage.ErrInvalidQuery):
+		status, code = http.StatusBadRequest, "bad_request"
+	case errors.Is(err, errUnauthorized):
+		status, code = http.StatusUnauthorized, "unauthorized"
+	case errors.Is(err, storage.ErrEventNotFound):
+		status, code = http.StatusNotFound, "not_found"
+	case errors.Is(err, storage.ErrDateBusy):
+		status, code = http.StatusConflict, "date_busy"
+	case errors.Is(err, storage.ErrEventExists):
+		status, code = http.StatusConflict, "already_exists"
+	default:
+		// Детали внутренних ошибок наружу не отдаём
+		s.logger.WithContext(r.Context()).WithError(err).Error("Request failed")
+		message = http.StatusText(status)
+	}
+
+	s.writeJSON(w, r, status, errorResponse{Error: errorBody{Code: code, Message: message}})
+}
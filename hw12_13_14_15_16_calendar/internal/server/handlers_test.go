@@ -0,0 +1,434 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/ical"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testApp — минимальная реализация Application поверх inmemory-хранилища.
+type testApp struct {
+	store  *inmemory.Storage
+	nextID int
+}
+
+func (a *testApp) CreateEvent(ctx context.Context, userID string, event storage.Event) (storage.Event, error) {
+	event.UserID = userID
+	if err := event.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	if event.ID == "" {
+		a.nextID++
+		event.ID = fmt.Sprintf("event-%d", a.nextID)
+	}
+	return event, a.store.Add(ctx, event)
+}
+
+func (a *testApp) UpdateEvent(ctx context.Context, userID, id string, event storage.Event) (storage.Event, error) {
+	event.ID, event.UserID = id, userID
+	return event, a.store.Update(ctx, userID, id, event)
+}
+
+func (a *testApp) DeleteEvent(ctx context.Context, userID, id string) error {
+	return a.store.Delete(ctx, userID, id)
+}
+
+func (a *testApp) GetEvent(ctx context.Context, userID, id string) (storage.Event, error) {
+	return a.store.Get(ctx, userID, id)
+}
+
+func (a *testApp) ListAllEvents(ctx context.Context, userID string) ([]storage.Event, error) {
+	return a.store.ListAll(ctx, userID)
+}
+
+func (a *testApp) ListEvents(
+	ctx context.Context, userID string, from, to time.Time, cursor string, limit int,
+) ([]storage.Event, string, error) {
+	if limit == 0 {
+		limit = 100
+	}
+	return a.store.List(ctx, userID, from, to, cursor, limit)
+}
+
+func (a *testApp) SearchEvents(ctx context.Context, userID, query string, limit int) ([]storage.Event, error) {
+	if limit == 0 {
+		limit = 20
+	}
+	return a.store.Search(ctx, userID, query, limit)
+}
+
+func (a *testApp) ListDay(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListDay(ctx, userID, date, mode)
+}
+
+func (a *testApp) ListWeek(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListWeek(ctx, userID, date, mode)
+}
+
+func (a *testApp) ListMonth(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	return a.store.ListMonth(ctx, userID, date, mode)
+}
+
+func (a *testApp) ImportEvents(
+	ctx context.Context, userID string, events []storage.Event, dryRun bool,
+) ([]ical.ImportResult, error) {
+	results := make([]ical.ImportResult, 0, len(events))
+	for _, e := range events {
+		e.UserID = userID
+		r := ical.ImportResult{ID: e.ID, Title: e.Title, DateTime: e.DateTime, Status: ical.ImportCreated}
+		if dryRun {
+			r.Status = ical.ImportOK
+		} else if err := a.store.Add(ctx, e); err != nil {
+			r.Status, r.Error = ical.ImportConflict, err.Error()
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func (a *testApp) ExportEvents(ctx context.Context, userID string, from, _ time.Time) ([]storage.Event, error) {
+	return a.store.ListMonth(ctx, userID, from, storage.StartsInRange)
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	s := New(log, &testApp{store: inmemory.New()}, health.NewRegistry(0), "localhost", 0)
+	ts := httptest.NewServer(s.server.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func doRequest(t *testing.T, method, url, userID, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	if userID != "" {
+		req.Header.Set(UserIDHeader, userID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, data
+}
+
+const eventJSON = `{"title":"Standup","datetime":"2024-05-10T10:00:00Z","duration":900}`
+
+func TestServer_CRUD(t *testing.T) {
+	ts := newTestServer(t)
+
+	// Create
+	resp, body := doRequest(t, http.MethodPost, ts.URL+"/events", "user1", eventJSON)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created eventDTO
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, "Standup", created.Title)
+	assert.Equal(t, "user1", created.UserID)
+
+	// List
+	resp, body = doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-05-10", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list eventsResponse
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Len(t, list.Events, 1)
+	assert.Equal(t, created.ID, list.Events[0].ID)
+
+	// Get
+	resp, body = doRequest(t, http.MethodGet, ts.URL+"/events/"+created.ID, "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "Standup")
+
+	// Update
+	updated := strings.Replace(eventJSON, "Standup", "Retro", 1)
+	resp, body = doRequest(t, http.MethodPut, ts.URL+"/events/"+created.ID, "user1", updated)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "Retro")
+
+	// Delete
+	resp, _ = doRequest(t, http.MethodDelete, ts.URL+"/events/"+created.ID, "user1", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, _ = doRequest(t, http.MethodGet, ts.URL+"/events/week?date=2024-05-10", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_ListOverlapping(t *testing.T) {
+	ts := newTestServer(t)
+
+	night := `{"title":"Night shift","datetime":"2024-05-09T22:00:00Z","duration":28800}`
+	resp, _ := doRequest(t, http.MethodPost, ts.URL+"/events", "user1", night)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, body := doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-05-10", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list eventsResponse
+	require.NoError(t, json.Unmarshal(body, &list))
+	assert.Empty(t, list.Events)
+
+	resp, body = doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-05-10&overlapping=true", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Len(t, list.Events, 1)
+	assert.Equal(t, "Night shift", list.Events[0].Title)
+}
+
+func TestServer_Errors(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp, _ := doRequest(t, http.MethodPost, ts.URL+"/events", "user1", eventJSON)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"date busy", http.MethodPost, "/events", eventJSON, http.StatusConflict, "date_busy"},
+		{"invalid json", http.MethodPost, "/events", "{", http.StatusBadRequest, "bad_request"},
+		{"body too large", http.MethodPost, "/events", `{"title":"` + strings.Repeat("a", maxEventSize) + `"}`,
+			http.StatusRequestEntityTooLarge, "too_large"},
+		{"missing title", http.MethodPost, "/events", `{"datetime":"2024-05-10T10:00:00Z"}`,
+			http.StatusBadRequest, "bad_request"},
+		{"update not found", http.MethodPut, "/events/missing", eventJSON, http.StatusNotFound, "not_found"},
+		{"delete not found", http.MethodDelete, "/events/missing", "", http.StatusNotFound, "not_found"},
+		{"invalid rrule", http.MethodPost, "/events",
+			`{"title":"x","datetime":"2024-06-01T10:00:00Z","rrule":"FREQ=SOMETIMES"}`,
+			http.StatusBadRequest, "bad_request"},
+		{"bad date", http.MethodGet, "/events/month?date=10.05.2024", "", http.StatusBadRequest, "bad_request"},
+		{"bad overlapping", http.MethodGet, "/events/day?date=2024-05-10&overlapping=maybe", "",
+			http.StatusBadRequest, "bad_request"},
+		{"get not found", http.MethodGet, "/events/missing", "", http.StatusNotFound, "not_found"},
+		{"range without to", http.MethodGet, "/events?from=2024-05-01T00:00:00Z", "",
+			http.StatusBadRequest, "bad_request"},
+		{"bad cursor", http.MethodGet, "/events?from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00Z&cursor=%25",
+			"", http.StatusBadRequest, "bad_request"},
+		{"bad limit", http.MethodGet, "/events?from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00Z&limit=x",
+			"", http.StatusBadRequest, "bad_request"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := doRequest(t, tc.method, ts.URL+tc.path, "user1", tc.body)
+			assert.Equal(t, tc.status, resp.StatusCode)
+
+			var errResp errorResponse
+			require.NoError(t, json.Unmarshal(body, &errResp))
+			assert.Equal(t, tc.code, errResp.Error.Code)
+			assert.NotEmpty(t, errResp.Error.Message)
+		})
+	}
+}
+
+func TestServer_UserScope(t *testing.T) {
+	ts := newTestServer(t)
+
+	// Без заголовка пользователя запрос отклоняется
+	resp, body := doRequest(t, http.MethodPost, ts.URL+"/events", "", eventJSON)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, string(body), "unauthorized")
+
+	resp, body = doRequest(t, http.MethodPost, ts.URL+"/events", "user1", eventJSON)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created eventDTO
+	require.NoError(t, json.Unmarshal(body, &created))
+
+	// Другой пользователь не видит и не может изменить чужое событие
+	resp, body = doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-05-10", "user2", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"events":[]}`, string(body))
+
+	resp, _ = doRequest(t, http.MethodPut, ts.URL+"/events/"+created.ID, "user2", eventJSON)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = doRequest(t, http.MethodDelete, ts.URL+"/events/"+created.ID, "user2", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Пересечение проверяется только среди событий одного пользователя
+	resp, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "user2", eventJSON)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestServer_Recurring(t *testing.T) {
+	ts := newTestServer(t)
+
+	body := `{"title":"Standup","datetime":"2024-05-06T09:00:00Z","duration":900,` +
+		`"rrule":"FREQ=DAILY","exdates":["2024-05-08T09:00:00Z"]}`
+	resp, data := doRequest(t, http.MethodPost, ts.URL+"/events", "user1", body)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created eventDTO
+	require.NoError(t, json.Unmarshal(data, &created))
+	assert.Equal(t, "FREQ=DAILY", created.RRule)
+	assert.Len(t, created.ExDates, 1)
+
+	resp, data = doRequest(t, http.MethodGet, ts.URL+"/events/week?date=2024-05-06", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list eventsResponse
+	require.NoError(t, json.Unmarshal(data, &list))
+	require.Len(t, list.Events, 6)
+	for _, e := range list.Events {
+		assert.Equal(t, created.ID, e.ID)
+	}
+}
+
+func TestServer_ListRange(t *testing.T) {
+	ts := newTestServer(t)
+
+	series := `{"title":"Daily","datetime":"2024-05-06T09:00:00Z","duration":900,"rrule":"FREQ=DAILY;COUNT=3"}`
+	resp, _ := doRequest(t, http.MethodPost, ts.URL+"/events", "user1", series)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "user1",
+		`{"title":"Review","datetime":"2024-05-07T12:00:00Z","duration":900}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var starts []time.Time
+	url := ts.URL + "/events?from=2024-05-06T00:00:00Z&to=2024-05-09T00:00:00Z&limit=3"
+	for {
+		resp, body := doRequest(t, http.MethodGet, url, "user1", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var page eventsResponse
+		require.NoError(t, json.Unmarshal(body, &page))
+		for _, e := range page.Events {
+			starts = append(starts, e.DateTime)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		url = ts.URL + "/events?from=2024-05-06T00:00:00Z&to=2024-05-09T00:00:00Z&limit=3&cursor=" + page.NextCursor
+	}
+
+	day := func(d, h int) time.Time { return time.Date(2024, 5, d, h, 0, 0, 0, time.UTC) }
+	require.Len(t, starts, 4)
+	for i, want := range []time.Time{day(6, 9), day(7, 9), day(7, 12), day(8, 9)} {
+		assert.True(t, want.Equal(starts[i]), "event %d: %s", i, starts[i])
+	}
+}
+
+func TestServer_Search(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, body := range []string{
+		`{"title":"Budget review","datetime":"2024-05-06T09:00:00Z"}`,
+		`{"title":"Standup","description":"budget follow-up","datetime":"2024-05-07T09:00:00Z"}`,
+		`{"title":"Lunch","datetime":"2024-05-08T09:00:00Z"}`,
+	} {
+		resp, _ := doRequest(t, http.MethodPost, ts.URL+"/events", "user1", body)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	resp, body := doRequest(t, http.MethodGet, ts.URL+"/events/search?q=budget", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var found eventsResponse
+	require.NoError(t, json.Unmarshal(body, &found))
+	require.Len(t, found.Events, 2)
+	assert.Equal(t, "Budget review", found.Events[0].Title)
+	assert.Equal(t, "Standup", found.Events[1].Title)
+
+	resp, body = doRequest(t, http.MethodGet, ts.URL+"/events/search?q=budget", "user2", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"events":[]}`, string(body))
+
+	resp, _ = doRequest(t, http.MethodGet, ts.URL+"/events/search", "user1", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// blockingApp ждёт отмены контекста запроса в ListDay.
+type blockingApp struct {
+	testApp
+	canceled chan struct{}
+}
+
+func (a *blockingApp) ListDay(
+	ctx context.Context, _ string, _ time.Time, _ storage.RangeMode,
+) ([]storage.Event, error) {
+	<-ctx.Done()
+	close(a.canceled)
+	return nil, ctx.Err()
+}
+
+func TestServer_ClientDisconnectCancelsContext(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	app := &blockingApp{testApp: testApp{store: inmemory.New()}, canceled: make(chan struct{})}
+	ts := httptest.NewServer(New(log, app, health.NewRegistry(0), "localhost", 0).server.Handler)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events/day?date=2024-05-10", nil)
+	require.NoError(t, err)
+	req.Header.Set(UserIDHeader, "user1")
+
+	_, err = http.DefaultClient.Do(req)
+	require.Error(t, err)
+
+	select {
+	case <-app.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("request context was not canceled")
+	}
+}
+
+func TestServer_CalDAV(t *testing.T) {
+	ts := newTestServer(t)
+
+	// Клиенты находят сервер через /.well-known/caldav
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(ts.URL + "/.well-known/caldav")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, CalDAVPrefix+"/", resp.Header.Get("Location"))
+
+	// Событие, созданное через REST API, доступно в календаре пользователя
+	resp, body := doRequest(t, http.MethodPost, ts.URL+"/events", "user1",
+		`{"id":"standup","title":"Standup","datetime":"2024-05-10T10:00:00Z","duration":900}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+	resp, body = doRequest(t, http.MethodGet, ts.URL+CalDAVPrefix+"/calendars/user1/default/standup.ics", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "SUMMARY:Standup")
+	assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+	// Пользователя можно передать и именем Basic-авторизации
+	req, err := http.NewRequest("PROPFIND", ts.URL+CalDAVPrefix+"/calendars/user1/default/", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("user1", "")
+	req.Header.Set("Depth", "1")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Contains(t, string(data), "standup.ics")
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/ical"
+)
+
+// maxImportSize ограничивает размер загружаемого .ics файла.
+const maxImportSize = 10 << 20
+
+type importResultDTO struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	DateTime time.Time `json:"datetime"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type importResponse struct {
+	DryRun  bool              `json:"dry_run"`
+	Results []importResultDTO `json:"results"`
+}
+
+// handleImport принимает тело text/calendar. Параметры: dry_run — только проверить,
+// tz — часовой пояс для времени без TZID (по умолчанию UTC).
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dryRun := false
+	if v := query.Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			s.writeError(w, r, fmt.Errorf("%w: dry_run must be a boolean", errBadRequest))
+			return
+		}
+	}
+	loc, err := parseLocation(query.Get("tz"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	events, err := ical.Decode(http.MaxBytesReader(w, r.Body, maxImportSize), loc)
+	if err != nil {
+		s.writeError(w, r, fmt.Errorf("%w: %w", errBadRequest, err))
+		return
+	}
+	results, err := s.app.ImportEvents(r.Context(), userIDFromContext(r.Context()), events, dryRun)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	resp := importResponse{DryRun: dryRun, Results: make([]importResultDTO, 0, len(results))}
+	for _, res := range results {
+		resp.Results = append(resp.Results, importResultDTO{
+			ID:       res.ID,
+			Title:    res.Title,
+			DateTime: res.DateTime,
+			Status:   string(res.Status),
+			Error:    res.Error,
+		})
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// handleExport отдаёт события в [from, to) как text/calendar. Даты from и to
+// трактуются в часовом поясе tz (по умолчанию UTC); период не длиннее ical.MaxExportSpan.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	loc, err := parseLocation(query.Get("tz"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	from, err1 := time.ParseInLocation(dateLayout, query.Get("from"), loc)
+	to, err2 := time.ParseInLocation(dateLayout, query.Get("to"), loc)
+	if err1 != nil || err2 != nil || !from.Before(to) {
+		s.writeError(w, r,
+			fmt.Errorf("%w: from and to must be dates in format %s, from before to", errBadRequest, dateLayout))
+		return
+	}
+	if to.Sub(from) > ical.MaxExportSpan {
+		s.writeError(w, r,
+			fmt.Errorf("%w: range must not exceed %d days", errBadRequest, ical.MaxExportSpan/(24*time.Hour)))
+		return
+	}
+
+	events, err := s.app.ExportEvents(r.Context(), userIDFromContext(r.Context()), from, to)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="calendar.ics"`)
+	if err := ical.Encode(w, events); err != nil {
+		s.logger.WithContext(r.Context()).WithError(err).Warn("Failed to write response")
+	}
+}
+
+func parseLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", errBadRequest, name)
+	}
+	return loc, nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const icsBody = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\nUID:standup\r\nDTSTART;TZID=Europe/Moscow:20240510T100000\r\nDURATION:PT15M\r\n" +
+	"SUMMARY:Standup\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:clash\r\nDTSTART:20240510T070500Z\r\nDURATION:PT5M\r\n" +
+	"SUMMARY:Clash\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestServer_ICalImportExport(t *testing.T) {
+	ts := newTestServer(t)
+
+	// Пробный прогон ничего не сохраняет
+	resp, body := doRequest(t, http.MethodPost, ts.URL+"/events/import?dry_run=true", "user1", icsBody)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var report importResponse
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, "ok", report.Results[0].Status)
+
+	resp, body = doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-05-10", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"events":[]}`, string(body))
+
+	// Импорт: второе событие пересекается с первым (10:00 MSK = 07:00 UTC)
+	resp, body = doRequest(t, http.MethodPost, ts.URL+"/events/import", "user1", icsBody)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.Unmarshal(body, &report))
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, "created", report.Results[0].Status)
+	assert.Equal(t, "conflict", report.Results[1].Status)
+	assert.NotEmpty(t, report.Results[1].Error)
+
+	resp, body = doRequest(t, http.MethodGet, ts.URL+"/events/export?from=2024-05-01&to=2024-06-01", "user1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/calendar; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), "UID:standup\r\n")
+	assert.Contains(t, string(body), "DTSTART:20240510T070000Z\r\n")
+	assert.Equal(t, 1, strings.Count(string(body), "BEGIN:VEVENT"))
+}
+
+func TestServer_ICalErrors(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"malformed ics", http.MethodPost, "/events/import", "not a calendar"},
+		{"bad dry_run", http.MethodPost, "/events/import?dry_run=maybe", icsBody},
+		{"bad tz", http.MethodPost, "/events/import?tz=Mars/Olympus", icsBody},
+		{"missing range", http.MethodGet, "/events/export?from=2024-05-01", ""},
+		{"reversed range", http.MethodGet, "/events/export?from=2024-06-01&to=2024-05-01", ""},
+		{"range too long", http.MethodGet, "/events/export?from=0001-01-01&to=9999-12-31", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := doRequest(t, tc.method, ts.URL+tc.path, "user1", tc.body)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Contains(t, string(body), "bad_request")
+		})
+	}
+
+	// Слишком большой файл — 413, а не ошибка разбора
+	resp, body := doRequest(t, http.MethodPost, ts.URL+"/events/import", "user1",
+		icsBody+strings.Repeat("X-PAD:padding\r\n", maxImportSize/15))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Contains(t, string(body), "too_large")
+}
@@ -1,51 +1,102 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/ical"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/logger"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
 	"github.com/sirupsen/logrus"
 )
 
+// Application — бизнес-логика календаря, которую обслуживает HTTP API.
+type Application interface {
+	CreateEvent(ctx context.Context, userID string, event storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, userID, id string, event storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, userID, id string) error
+	GetEvent(ctx context.Context, userID, id string) (storage.Event, error)
+	ListAllEvents(ctx context.Context, userID string) ([]storage.Event, error)
+	ListEvents(
+		ctx context.Context, userID string, from, to time.Time, cursor string, limit int,
+	) ([]storage.Event, string, error)
+	SearchEvents(ctx context.Context, userID, query string, limit int) ([]storage.Event, error)
+	ListDay(ctx context.Context, userID string, date time.Time, mode storage.RangeMode) ([]storage.Event, error)
+	ListWeek(ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode) ([]storage.Event, error)
+	ListMonth(ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode) ([]storage.Event, error)
+	ImportEvents(ctx context.Context, userID string, events []storage.Event, dryRun bool) ([]ical.ImportResult, error)
+	ExportEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error)
+}
+
 type Server struct {
 	logger *logrus.Logger
+	app    Application
+	checks *health.Registry
 	server *http.Server
+	// cancel отменяет контексты всех запросов при остановке сервера
+	cancel context.CancelFunc
 }
 
-func New(logger *logrus.Logger, host string, port int) *Server {
-	return &Server{
+// New создаёт HTTP-сервер; checks — проверки, которые отдаются на health.ReadyPath.
+func New(logger *logrus.Logger, app Application, checks *health.Registry, host string, port int) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
 		logger: logger,
+		app:    app,
+		checks: checks,
 		server: &http.Server{
 			Addr:         fmt.Sprintf("%s:%d", host, port),
 			ReadTimeout:  10 * time.Second,
 			WriteTimeout: 10 * time.Second,
+			BaseContext: func(net.Listener) context.Context {
+				return ctx
+			},
 		},
+		cancel: cancel,
 	}
+	s.server.Handler = requestIDMiddleware(metricsMiddleware(s.loggingMiddleware(s.routes())))
+	return s
 }
 
+// Start слушает адрес из конфигурации. После Stop возвращает nil.
 func (s *Server) Start() error {
-	// Простой "hello-world" handler
-	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		_, err := w.Write([]byte("Hello from Calendar Service!\n"))
-		if err != nil {
-			// Запишем в лог, но не можем повлиять на ответ — клиент уже отключился
-			s.logger.WithError(err).Warn("Failed to write response")
-		}
-	})
-
-	// Оборачиваем в middleware
-	s.server.Handler = s.loggingMiddleware(handler)
+	lis, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen %s: %w", s.server.Addr, err)
+	}
+	return s.Serve(lis)
+}
 
-	s.logger.Infof("Starting HTTP server on %s", s.server.Addr)
-	return s.server.ListenAndServe()
+// Serve обслуживает запросы на уже открытом listener (используется в тестах).
+func (s *Server) Serve(lis net.Listener) error {
+	s.logger.Infof("Starting HTTP server on %s", lis.Addr())
+	if err := s.server.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
-func (s *Server) Stop() error {
-	return s.server.Close()
+// Stop перестаёт принимать новые соединения и ждёт завершения текущих запросов.
+// Если ctx истёк раньше, контексты оставшихся запросов отменяются, а соединения закрываются.
+func (s *Server) Stop(ctx context.Context) error {
+	defer s.cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.cancel()
+		_ = s.server.Close()
+		return err
+	}
+	return nil
 }
 
-// loggingMiddleware — соответсвует требованиям ТЗ.
+// loggingMiddleware пишет по записи на каждый запрос: адрес клиента, метод, путь, протокол,
+// код ответа, длительность и User-Agent.
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -55,23 +106,99 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(lw, r)
 
-		duration := time.Since(start)
-
-		// Формат лога: IP [timestamp] METHOD PATH PROTO STATUS DURATION_MS "USER_AGENT"
-		s.logger.Infof(
-			`%s [%s] %s %s %s %d %d "%s"`,
-			r.RemoteAddr,
-			time.Now().Format("02/Jan/2006:15:04:05 -0700"),
-			r.Method,
-			r.URL.String(),
-			r.Proto,
-			lw.statusCode,
-			duration.Milliseconds(),
-			r.UserAgent(),
-		)
+		s.logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"remote_addr": r.RemoteAddr,
+			"method":      r.Method,
+			"path":        r.URL.String(),
+			"proto":       r.Proto,
+			"status":      lw.statusCode,
+			"duration_ms": time.Since(start).Milliseconds(),
+			"user_agent":  r.UserAgent(),
+		}).Info("HTTP request")
+	})
+}
+
+// RequestIDHeader — заголовок с ID запроса: его можно передать, иначе ID генерируется.
+// ID возвращается в ответе и попадает во все записи лога, сделанные при обработке запроса.
+const RequestIDHeader = "X-Request-ID"
+
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := logger.EnsureRequestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
 	})
 }
 
+type routeKey struct{}
+
+// metricsMiddleware считает запросы и время их обработки по шаблону маршрута,
+// а не по пути, чтобы число временных рядов не зависело от ID в URL.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		route := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), routeKey{}, route))
+
+		next.ServeHTTP(lw, r)
+
+		// Шаблон вложенного mux (см. withRoute) точнее шаблона внешнего
+		if *route == "" {
+			*route = r.Pattern
+		}
+		if *route == "" {
+			*route = "unmatched"
+		}
+		status := strconv.Itoa(lw.statusCode)
+		metrics.HTTPRequests.WithLabelValues(r.Method, *route, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(r.Method, *route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// withRoute сообщает metricsMiddleware шаблон, которому запрос соответствует во вложенном mux.
+func withRoute(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			_, *route = mux.Handler(r)
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// UserIDHeader — заголовок, в котором клиент передаёт ID пользователя.
+const UserIDHeader = "X-User-ID"
+
+type userIDKey struct{}
+
+// userMiddleware извлекает ID пользователя из заголовка и отклоняет запросы без него.
+func (s *Server) userMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(UserIDHeader)
+		if userID == "" {
+			s.writeError(w, r, errUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey{}, userID)))
+	})
+}
+
+// davUser возвращает пользователя CalDAV-запроса: из заголовка X-User-ID, а для клиентов,
+// которые не умеют его передавать, — из имени пользователя Basic-авторизации.
+// Пароль, как и заголовок в REST API, не проверяется: аутентификацию выполняет прокси перед сервисом.
+func davUser(r *http.Request) string {
+	if userID := r.Header.Get(UserIDHeader); userID != "" {
+		return userID
+	}
+	userID, _, _ := r.BasicAuth()
+	return userID
+}
+
+func userIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
 // loggingResponseWriter — позволяет получить реальный код ответа.
 type loggingResponseWriter struct {
 	http.ResponseWriter
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/health"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/logger"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/metrics"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/inmemory"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowApp отвечает на ListDay после сигнала release или отмены контекста запроса.
+type slowApp struct {
+	testApp
+	started chan struct{}
+	release chan struct{}
+}
+
+func (a *slowApp) ListDay(ctx context.Context, _ string, _ time.Time, _ storage.RangeMode) ([]storage.Event, error) {
+	close(a.started)
+	select {
+	case <-a.release:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func startSlowServer(t *testing.T) (*Server, *slowApp, string) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	app := &slowApp{
+		testApp: testApp{store: inmemory.New()},
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	s := New(log, app, health.NewRegistry(0), "localhost", 0)
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(lis)
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, <-served, "Serve must return nil after Stop")
+	})
+	return s, app, "http://" + lis.Addr().String()
+}
+
+// getDay выполняет запрос в фоне и возвращает канал с кодом ответа (0 — ошибка соединения).
+func getDay(url string) <-chan int {
+	result := make(chan int, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, url+"/events/day?date=2024-05-10", nil)
+		req.Header.Set(UserIDHeader, "user1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			result <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		result <- resp.StatusCode
+	}()
+	return result
+}
+
+func TestServer_StopWaitsForRequests(t *testing.T) {
+	s, app, url := startSlowServer(t)
+	result := getDay(url)
+	<-app.started
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Stop(context.Background())
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned before the request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(app.release)
+	assert.Equal(t, http.StatusOK, <-result)
+	require.NoError(t, <-stopped)
+}
+
+func TestServer_StopCancelsRequestsAfterTimeout(t *testing.T) {
+	s, app, url := startSlowServer(t)
+	result := getDay(url)
+	<-app.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded)
+
+	// Запрос не получает успешного ответа: его контекст отменён, соединение закрыто
+	assert.NotEqual(t, http.StatusOK, <-result)
+}
+
+func TestServer_Metrics(t *testing.T) {
+	ts := newTestServer(t)
+	requests := func(method, route, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(method, route, status))
+	}
+	getByID := requests(http.MethodGet, "GET /events/{id}", "404")
+	unauthorized := requests(http.MethodGet, "/events/", "401")
+	unmatched := requests(http.MethodGet, "unmatched", "404")
+
+	doRequest(t, http.MethodGet, ts.URL+"/events/missing-1", "user1", "")
+	doRequest(t, http.MethodGet, ts.URL+"/events/missing-2", "user1", "")
+	doRequest(t, http.MethodGet, ts.URL+"/events/missing-3", "", "")
+	doRequest(t, http.MethodGet, ts.URL+"/unknown", "", "")
+
+	// Запросы группируются по шаблону маршрута, а не по пути
+	assert.Equal(t, getByID+2, requests(http.MethodGet, "GET /events/{id}", "404"))
+	assert.Equal(t, unauthorized+1, requests(http.MethodGet, "/events/", "401"))
+	assert.Equal(t, unmatched+1, requests(http.MethodGet, "unmatched", "404"))
+
+	resp, body := doRequest(t, http.MethodGet, ts.URL+metrics.Path, "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `calendar_http_request_duration_seconds_count{method="GET",route="GET /events/{id}"`)
+}
+
+func TestServer_Probes(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	checks := health.NewRegistry(0)
+	var storageErr error
+	checks.Register("storage", func(context.Context) error { return storageErr })
+	ts := httptest.NewServer(New(log, &testApp{store: inmemory.New()}, checks, "localhost", 0).server.Handler)
+	t.Cleanup(ts.Close)
+
+	resp, _ := doRequest(t, http.MethodGet, ts.URL+health.LivePath, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, body := doRequest(t, http.MethodGet, ts.URL+health.ReadyPath, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"status":"ok","checks":{"storage":{"status":"ok"}}}`, string(body))
+
+	storageErr = errors.New("connection refused")
+	resp, body = doRequest(t, http.MethodGet, ts.URL+health.ReadyPath, "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.JSONEq(t, `{"status":"fail","checks":{"storage":{"status":"fail","error":"connection refused"}}}`,
+		string(body))
+	// Живость от зависимостей не зависит
+	resp, _ = doRequest(t, http.MethodGet, ts.URL+health.LivePath, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// brokenApp отвечает на GetEvent внутренней ошибкой хранилища.
+type brokenApp struct {
+	testApp
+}
+
+func (a *brokenApp) GetEvent(context.Context, string, string) (storage.Event, error) {
+	return storage.Event{}, errors.New("connection reset by peer")
+}
+
+func TestServer_RequestID(t *testing.T) {
+	log, err := logger.New(logger.Options{Format: "json"})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	ts := httptest.NewServer(New(log, &brokenApp{testApp{store: inmemory.New()}}, health.NewRegistry(0),
+		"localhost", 0).server.Handler)
+	t.Cleanup(ts.Close)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/events/1", nil)
+	require.NoError(t, err)
+	req.Header.Set(UserIDHeader, "user1")
+	req.Header.Set(RequestIDHeader, "req-42")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "req-42", resp.Header.Get(RequestIDHeader))
+
+	// ID есть и в записи об ошибке хранилища, и в записи о запросе
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var failed, access map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &failed))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+	assert.Equal(t, "Request failed", failed["msg"])
+	assert.Equal(t, "connection reset by peer", failed["error"])
+	assert.Equal(t, "req-42", failed[logger.RequestIDField])
+	assert.Equal(t, "HTTP request", access["msg"])
+	assert.Equal(t, "req-42", access[logger.RequestIDField])
+	assert.Equal(t, "/events/1", access["path"])
+	assert.EqualValues(t, http.StatusInternalServerError, access["status"])
+
+	// Без заголовка ID генерируется
+	resp, _ = doRequest(t, http.MethodGet, ts.URL+health.LivePath, "", "")
+	assert.Len(t, resp.Header.Get(RequestIDHeader), 36)
+}
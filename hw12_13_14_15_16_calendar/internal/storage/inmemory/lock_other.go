@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package inmemory
+
+// lockDir на этих платформах не блокирует каталог: следить, чтобы его
+// использовал один процесс, должен администратор.
+func lockDir(string) (unlock func() error, err error) {
+	return func() error { return nil }, nil
+}
+
+// syncDir ничего не делает: каталоги не открываются для fsync на этих платформах.
+func syncDir(string) error {
+	return nil
+}
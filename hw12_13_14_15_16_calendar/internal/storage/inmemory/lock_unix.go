@@ -0,0 +1,34 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package inmemory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir захватывает каталог хранилища, чтобы второй процесс не писал в тот же журнал.
+// Блокировка снимается при закрытии файла, в том числе при падении процесса.
+func lockDir(dir string) (unlock func() error, err error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("storage directory %s is used by another process: %w", dir, err)
+	}
+	return f.Close, nil
+}
+
+// syncDir сбрасывает на диск запись каталога, чтобы переименование файла пережило сбой.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package inmemory
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_DirectoryLocked(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStorage(t, dir, Options{})
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	_, err := Open(dir, Options{}, log)
+	assert.ErrorContains(t, err, "used by another process")
+
+	require.NoError(t, s.Close())
+	s, err = Open(dir, Options{}, log)
+	require.NoError(t, err)
+	assert.NoError(t, s.Close())
+}
@@ -0,0 +1,470 @@
+package inmemory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// SyncPolicy определяет, когда журнал сбрасывается на диск (fsync).
+type SyncPolicy string
+
+const (
+	// SyncAlways — после каждого изменения: подтверждённое изменение не теряется.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval — раз в Options.SyncInterval: при сбое ОС теряются изменения за последний интервал.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever — сбросом управляет ОС: журнал переживает падение процесса, но не ОС.
+	SyncNever SyncPolicy = "never"
+)
+
+// Options — параметры хранения на диске.
+type Options struct {
+	Sync         SyncPolicy    // по умолчанию SyncInterval
+	SyncInterval time.Duration // по умолчанию секунда
+	// SnapshotInterval — период записи снимка; 0 — только при открытии и закрытии.
+	SnapshotInterval time.Duration
+}
+
+const (
+	snapshotFile = "snapshot.json"
+	lockFile     = "LOCK"
+	// Сегмент журнала называется по номеру первой записи, которая может в нём быть
+	walPrefix = "wal-"
+	walSuffix = ".log"
+)
+
+type op string
+
+const (
+	opPut          op = "put" // Add и Update
+	opDelete       op = "delete"
+	opDeleteBefore op = "delete_before"
+)
+
+// record — запись журнала. Записи нумеруются подряд, снимок помнит номер последней вошедшей в него.
+type record struct {
+	Seq    uint64          `json:"seq"`
+	Op     op              `json:"op"`
+	Event  *persistedEvent `json:"event,omitempty"`
+	ID     string          `json:"id,omitempty"`
+	Before *time.Time      `json:"before,omitempty"`
+}
+
+// persistedEvent — событие на диске. JSON сохраняет только смещение часового пояса,
+// а повторения считаются в поясе DateTime, поэтому его имя и смещение хранятся отдельно
+// (у времени из JSON API имени нет, только смещение).
+type persistedEvent struct {
+	storage.Event
+	Zone   string `json:"zone,omitempty"`
+	Offset int    `json:"offset,omitempty"` // секунды к востоку от UTC
+}
+
+func newPersisted(e storage.Event) *persistedEvent {
+	zone, offset := storage.ZoneOf(e.DateTime)
+	return &persistedEvent{Event: e, Zone: zone, Offset: offset}
+}
+
+func (p persistedEvent) event() storage.Event {
+	e := p.Event
+	e.DateTime = storage.InZone(e.DateTime, p.Zone, p.Offset)
+	return e
+}
+
+type snapshot struct {
+	Seq    uint64           `json:"seq"`
+	Events []persistedEvent `json:"events"`
+}
+
+// persister ведёт журнал и снимки хранилища в каталоге dir.
+type persister struct {
+	dir    string
+	opts   Options
+	logger *logrus.Logger
+	unlock func() error
+
+	mu    sync.Mutex // защищает журнал от фонового fsync
+	wal   *os.File
+	size  int64  // длина текущего сегмента без недописанных записей
+	seq   uint64 // номер последней записи
+	dirty bool   // есть записи, не сброшенные на диск
+
+	snapMu    sync.Mutex // снимки пишутся по одному
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Open создаёт хранилище, которое сохраняет изменения в каталоге dir. Каждое
+// изменение дописывается в журнал (WAL) до применения, а периодически состояние
+// записывается в снимок и покрытые им сегменты журнала удаляются. При открытии
+// загружается снимок и проигрывается журнал. Каталог может использовать только один процесс.
+func Open(dir string, opts Options, logger *logrus.Logger) (*Storage, error) {
+	switch opts.Sync {
+	case "":
+		opts.Sync = SyncInterval
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy %q", opts.Sync)
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	unlock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := New()
+	p := &persister{dir: dir, opts: opts, logger: logger, unlock: unlock, stop: make(chan struct{})}
+	s.persist = p
+	if err := s.load(); err != nil {
+		_ = unlock()
+		return nil, err
+	}
+	// Проигранный журнал сразу сворачивается в снимок
+	if err := s.snapshot(); err != nil {
+		if p.wal != nil {
+			_ = p.wal.Close()
+		}
+		_ = unlock()
+		return nil, err
+	}
+	logger.Infof("In-memory storage loaded %d events from %s", len(s.events), dir)
+
+	p.wg.Add(1)
+	go s.background()
+	return s, nil
+}
+
+// logOp дописывает изменение в журнал. Вызывается под s.mu до изменения данных.
+func (s *Storage) logOp(r record) error {
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist.append(r)
+}
+
+func (p *persister) append(r record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r.Seq = p.seq + 1
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := p.wal.Write(data); err != nil {
+		// Отрезаем недописанную запись, чтобы следующие не оказались после неё
+		_ = p.wal.Truncate(p.size)
+		return fmt.Errorf("failed to write WAL: %w", err)
+	}
+	if p.opts.Sync == SyncAlways {
+		if err := p.wal.Sync(); err != nil {
+			_ = p.wal.Truncate(p.size)
+			return fmt.Errorf("failed to sync WAL: %w", err)
+		}
+	} else {
+		p.dirty = true
+	}
+	p.size += int64(len(data))
+	p.seq = r.Seq
+	return nil
+}
+
+func (p *persister) sync() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.dirty {
+		return nil
+	}
+	p.dirty = false
+	return p.wal.Sync()
+}
+
+// rotate начинает новый сегмент журнала и возвращает номер последней записи в предыдущих.
+func (p *persister) rotate() (uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	path := filepath.Join(p.dir, fmt.Sprintf("%s%020d%s", walPrefix, p.seq+1, walSuffix))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return 0, err
+	}
+	if p.wal != nil {
+		if err := p.wal.Sync(); err != nil {
+			_ = f.Close()
+			return 0, fmt.Errorf("failed to sync WAL: %w", err)
+		}
+		_ = p.wal.Close()
+	}
+	p.wal, p.size, p.dirty = f, info.Size(), false
+	return p.seq, nil
+}
+
+// snapshot записывает все события в снимок и удаляет покрытые им сегменты журнала.
+// Изменения ждут только копирования событий, запись файла идёт без блокировки.
+func (s *Storage) snapshot() error {
+	p := s.persist
+	p.snapMu.Lock()
+	defer p.snapMu.Unlock()
+
+	s.mu.RLock()
+	snap := snapshot{Events: make([]persistedEvent, 0, len(s.events))}
+	for _, e := range s.events {
+		snap.Events = append(snap.Events, *newPersisted(e))
+	}
+	seq, err := p.rotate()
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	snap.Seq = seq
+
+	if err := writeSnapshot(p.dir, snap); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	segments, err := p.segments()
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		if seg.first <= seq {
+			if err := os.Remove(seg.path); err != nil {
+				return fmt.Errorf("failed to remove WAL segment: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeSnapshot атомарно заменяет файл снимка: пишет во временный файл и переименовывает.
+func writeSnapshot(dir string, snap snapshot) error {
+	f, err := os.CreateTemp(dir, snapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	w := bufio.NewWriter(f)
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(dir, snapshotFile)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+type segment struct {
+	path  string
+	first uint64
+}
+
+// segments возвращает сегменты журнала по возрастанию номеров.
+func (p *persister) segments() ([]segment, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, err
+	}
+	var result []segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, walPrefix) || !strings.HasSuffix(name, walSuffix) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, walPrefix), walSuffix), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected file %s in storage directory", name)
+		}
+		result = append(result, segment{path: filepath.Join(p.dir, name), first: first})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].first < result[j].first })
+	return result, nil
+}
+
+// load загружает снимок и проигрывает записи журнала, которых в нём нет.
+func (s *Storage) load() error {
+	p := s.persist
+	data, err := os.ReadFile(filepath.Join(p.dir, snapshotFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	default:
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return fmt.Errorf("corrupted snapshot: %w", err)
+		}
+		for _, e := range snap.Events {
+			s.put(e.event())
+		}
+		p.seq = snap.Seq
+	}
+
+	segments, err := p.segments()
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		if err := s.replay(seg.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replay применяет записи сегмента. Недописанная последняя запись (процесс упал
+// во время записи) отрезается; любая другая повреждённая запись — ошибка.
+func (s *Storage) replay(path string) error {
+	p := s.persist
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open WAL: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				p.logger.Warnf("WAL %s: dropping incomplete record at offset %d", path, offset)
+				return f.Truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read WAL: %w", err)
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("corrupted WAL %s at offset %d: %w", path, offset, err)
+		}
+		offset += int64(len(line))
+		if rec.Seq <= p.seq {
+			continue // уже в снимке
+		}
+		if rec.Seq != p.seq+1 {
+			return fmt.Errorf("corrupted WAL %s: expected record %d, got %d", path, p.seq+1, rec.Seq)
+		}
+		if err := s.apply(rec); err != nil {
+			return fmt.Errorf("corrupted WAL %s at offset %d: %w", path, offset, err)
+		}
+		p.seq = rec.Seq
+	}
+}
+
+// apply повторяет изменение из журнала. Проверки не нужны: в журнал попадают только успешные изменения.
+func (s *Storage) apply(r record) error {
+	switch r.Op {
+	case opPut:
+		if r.Event == nil {
+			return errors.New("put without event")
+		}
+		e := r.Event.event()
+		if old, ok := s.events[e.ID]; ok {
+			s.remove(old)
+		}
+		s.put(e)
+	case opDelete:
+		if e, ok := s.events[r.ID]; ok {
+			s.remove(e)
+		}
+	case opDeleteBefore:
+		if r.Before == nil {
+			return errors.New("delete_before without time")
+		}
+		for _, e := range s.expiredBefore(*r.Before) {
+			s.remove(e)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", r.Op)
+	}
+	return nil
+}
+
+// background периодически сбрасывает журнал на диск и записывает снимки.
+func (s *Storage) background() {
+	p := s.persist
+	defer p.wg.Done()
+
+	var syncC, snapshotC <-chan time.Time
+	if p.opts.Sync == SyncInterval {
+		t := time.NewTicker(p.opts.SyncInterval)
+		defer t.Stop()
+		syncC = t.C
+	}
+	if p.opts.SnapshotInterval > 0 {
+		t := time.NewTicker(p.opts.SnapshotInterval)
+		defer t.Stop()
+		snapshotC = t.C
+	}
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-syncC:
+			if err := p.sync(); err != nil {
+				p.logger.WithError(err).Error("Failed to sync WAL")
+			}
+		case <-snapshotC:
+			if err := s.snapshot(); err != nil {
+				p.logger.WithError(err).Error("Failed to write snapshot")
+			}
+		}
+	}
+}
+
+func (p *persister) close(s *Storage) error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+		err := s.snapshot()
+
+		p.mu.Lock()
+		closeErr := p.wal.Close()
+		p.mu.Unlock()
+		p.closeErr = errors.Join(err, closeErr, p.unlock())
+	})
+	return p.closeErr
+}
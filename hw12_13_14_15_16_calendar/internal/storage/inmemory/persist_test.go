@@ -0,0 +1,235 @@
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/storagetest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestStorage(t *testing.T, dir string, opts Options) *Storage {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s, err := Open(dir, opts, log)
+	require.NoError(t, err)
+	return s
+}
+
+// crash останавливает хранилище без снимка, как при падении процесса.
+func crash(s *Storage) {
+	p := s.persist
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+		_ = p.wal.Close()
+		_ = p.unlock()
+	})
+}
+
+func walFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, walPrefix+"*"))
+	require.NoError(t, err)
+	return files
+}
+
+func TestPersistentStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s := openTestStorage(t, t.TempDir(), Options{Sync: SyncAlways})
+		t.Cleanup(func() { _ = s.Close() })
+		return s
+	})
+}
+
+func TestPersistentStorage_ReplayWAL(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	day := time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC)
+
+	s := openTestStorage(t, dir, Options{Sync: SyncAlways})
+	for i, title := range []string{"old", "a", "b", "c"} {
+		e := storage.Event{ID: title, Title: title, DateTime: day.AddDate(0, 0, i-1), Duration: 60, UserID: "user1"}
+		require.NoError(t, s.Add(ctx, e))
+	}
+	updated := storage.Event{Title: "b2", DateTime: day.Add(time.Hour), Duration: 60}
+	require.NoError(t, s.Update(ctx, "user1", "b", updated))
+	require.NoError(t, s.Delete(ctx, "user1", "c"))
+	n, err := s.DeleteBefore(ctx, day)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	crash(s)
+
+	s = openTestStorage(t, dir, Options{})
+	defer func() { _ = s.Close() }()
+	events, err := s.ListAll(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "a", events[0].ID)
+	assert.Equal(t, "b2", events[1].Title)
+	assert.True(t, day.Add(time.Hour).Equal(events[1].DateTime))
+
+	// Индексы восстановлены вместе с событиями
+	found, err := s.Search(ctx, "user1", "b2", 10)
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.ErrorIs(t, s.Add(ctx, storage.Event{ID: "x", Title: "x", DateTime: day, UserID: "user1"}),
+		storage.ErrDateBusy)
+}
+
+func TestPersistentStorage_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	start := time.Date(2024, time.May, 10, 10, 0, 0, 0, time.UTC)
+
+	s := openTestStorage(t, dir, Options{Sync: SyncNever})
+	for i := range 10 {
+		e := storage.Event{ID: string(rune('a' + i)), Title: "e", DateTime: start.Add(time.Duration(i) * time.Hour),
+			Duration: 60, UserID: "user1"}
+		require.NoError(t, s.Add(ctx, e))
+	}
+	require.NoError(t, s.snapshot())
+	// Снимок покрыл журнал: остался один пустой сегмент для новых записей
+	files := walFiles(t, dir)
+	require.Len(t, files, 1)
+	info, err := os.Stat(files[0])
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	require.NoError(t, s.Delete(ctx, "user1", "a"))
+	crash(s)
+
+	s = openTestStorage(t, dir, Options{})
+	events, err := s.ListAll(ctx, "user1")
+	require.NoError(t, err)
+	assert.Len(t, events, 9)
+	require.NoError(t, s.Close())
+	// Повторное закрытие безопасно
+	require.NoError(t, s.Close())
+}
+
+func TestPersistentStorage_PeriodicSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := openTestStorage(t, dir, Options{SyncInterval: 10 * time.Millisecond, SnapshotInterval: 20 * time.Millisecond})
+	defer func() { _ = s.Close() }()
+	e := storage.Event{ID: "1", Title: "e", DateTime: time.Now(), Duration: 60, UserID: "user1"}
+	require.NoError(t, s.Add(ctx, e))
+
+	// Снимок при открытии был пустым, фоновый должен содержать событие
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+		var snap snapshot
+		return err == nil && json.Unmarshal(data, &snap) == nil && len(snap.Events) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPersistentStorage_TornRecord(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	e := storage.Event{ID: "1", Title: "e", DateTime: time.Now(), Duration: 60, UserID: "user1"}
+
+	s := openTestStorage(t, dir, Options{Sync: SyncAlways})
+	require.NoError(t, s.Add(ctx, e))
+	crash(s)
+
+	// Процесс упал посреди записи: в конце сегмента неполная строка
+	files := walFiles(t, dir)
+	f, err := os.OpenFile(files[len(files)-1], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":2,"op":"del`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s = openTestStorage(t, dir, Options{})
+	defer func() { _ = s.Close() }()
+	_, err = s.Get(ctx, "user1", "1")
+	assert.NoError(t, err)
+}
+
+func TestPersistentStorage_CorruptedWAL(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	e := storage.Event{ID: "1", Title: "e", DateTime: time.Now(), Duration: 60, UserID: "user1"}
+
+	s := openTestStorage(t, dir, Options{Sync: SyncAlways})
+	require.NoError(t, s.Add(ctx, e))
+	crash(s)
+
+	files := walFiles(t, dir)
+	path := files[len(files)-1]
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append([]byte("garbage\n"), data...), 0o600))
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	_, err = Open(dir, Options{}, log)
+	assert.ErrorContains(t, err, "corrupted WAL")
+}
+
+func TestPersistentStorage_TimeZone(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// Еженедельное событие в 10:00 по Берлину через переход на летнее время
+	e := storage.Event{ID: "1", Title: "e", DateTime: time.Date(2024, time.March, 25, 10, 0, 0, 0, berlin),
+		Duration: 60, UserID: "user1", RRule: "FREQ=WEEKLY;COUNT=3"}
+	s := openTestStorage(t, dir, Options{Sync: SyncAlways})
+	require.NoError(t, s.Add(ctx, e))
+	crash(s)
+
+	s = openTestStorage(t, dir, Options{})
+	defer func() { _ = s.Close() }()
+	events, err := s.ListDay(ctx, "user1", time.Date(2024, time.April, 1, 0, 0, 0, 0, berlin), storage.StartsInRange)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, 10, events[0].DateTime.In(berlin).Hour())
+}
+
+func TestPersistentStorage_FixedOffset(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// Так время приходит из JSON API: смещение без имени пояса
+	start, err := time.Parse(time.RFC3339, "2024-01-01T01:00:00+03:00")
+	require.NoError(t, err)
+	e := storage.Event{ID: "1", Title: "e", DateTime: start, Duration: 60, UserID: "user1",
+		RRule: "FREQ=WEEKLY;BYDAY=MO"}
+
+	s := openTestStorage(t, dir, Options{Sync: SyncAlways})
+	require.NoError(t, s.Add(ctx, e))
+	require.NoError(t, s.Close())
+
+	// Повторное открытие читает снимок, затем — журнал
+	for range 2 {
+		s = openTestStorage(t, dir, Options{Sync: SyncAlways})
+		events, err := s.ListMonth(ctx, "user1", start, storage.StartsInRange)
+		require.NoError(t, err)
+		require.NotEmpty(t, events)
+		assert.Equal(t, "2024-01-01T01:00:00+03:00", events[0].DateTime.Format(time.RFC3339))
+		for _, occ := range events {
+			assert.Equal(t, time.Monday, occ.DateTime.Weekday(), occ.DateTime)
+			assert.Equal(t, 1, occ.DateTime.Hour(), occ.DateTime)
+		}
+		crash(s)
+	}
+}
+
+func TestOpen_InvalidOptions(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	_, err := Open(t.TempDir(), Options{Sync: "sometimes"}, log)
+	assert.Error(t, err)
+}
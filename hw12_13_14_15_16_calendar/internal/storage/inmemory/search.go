@@ -0,0 +1,76 @@
+package inmemory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+)
+
+func (idx *userIndex) indexTerms(e storage.Event) {
+	for t, w := range storage.SearchTerms(e) {
+		postings, ok := idx.terms[t]
+		if !ok {
+			postings = make(map[string]float64)
+			idx.terms[t] = postings
+		}
+		postings[e.ID] = w
+	}
+}
+
+func (idx *userIndex) unindexTerms(e storage.Event) {
+	for t := range storage.SearchTerms(e) {
+		delete(idx.terms[t], e.ID)
+		if len(idx.terms[t]) == 0 {
+			delete(idx.terms, t)
+		}
+	}
+}
+
+// Search находит события, содержащие все слова запроса, по инвертированному индексу.
+// Релевантность — сумма весов слов запроса в событии.
+func (s *Storage) Search(_ context.Context, userID, query string, limit int) ([]storage.Event, error) {
+	if err := storage.ValidateSearch(query, limit); err != nil {
+		return nil, err
+	}
+	words := storage.SearchWords(query)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx, ok := s.byUser[userID]
+	if !ok || len(words) == 0 {
+		return nil, nil
+	}
+	// Пересечение списков начинаем с самого короткого
+	sort.Slice(words, func(i, j int) bool { return len(idx.terms[words[i]]) < len(idx.terms[words[j]]) })
+	scores := make(map[string]float64)
+	for id, w := range idx.terms[words[0]] {
+		scores[id] = w
+	}
+	for _, word := range words[1:] {
+		postings := idx.terms[word]
+		for id := range scores {
+			if w, ok := postings[id]; ok {
+				scores[id] += w
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	result := make([]storage.Event, 0, len(scores))
+	for id := range scores {
+		result = append(result, s.events[id])
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if si, sj := scores[result[i].ID], scores[result[j].ID]; si != sj {
+			return si > sj
+		}
+		return storage.CursorOf(result[i]).Before(storage.CursorOf(result[j]))
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
@@ -1,6 +1,8 @@
 package inmemory
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,93 +12,271 @@ import (
 type Storage struct {
 	mu     sync.RWMutex
 	events map[string]storage.Event
+	// byUser — индекс событий пользователя для постраничной выборки List
+	byUser map[string]*userIndex
+	// persist — журнал и снимки на диске; nil, если хранилище создано New
+	persist *persister
+}
+
+// userIndex хранит позиции разовых событий пользователя в порядке (DateTime, ID)
+// и ID повторяющихся: их экземпляры вычисляются при выборке.
+// terms — инвертированный индекс для Search: слово → ID события → вес.
+type userIndex struct {
+	single    []storage.Cursor
+	recurring map[string]struct{}
+	terms     map[string]map[string]float64
 }
 
 func New() *Storage {
 	return &Storage{
 		events: make(map[string]storage.Event),
+		byUser: make(map[string]*userIndex),
 	}
 }
 
-func (s *Storage) Add(event storage.Event) error {
+// put сохраняет событие и добавляет его в индекс.
+func (s *Storage) put(e storage.Event) {
+	s.events[e.ID] = e
+	idx, ok := s.byUser[e.UserID]
+	if !ok {
+		idx = &userIndex{recurring: make(map[string]struct{}), terms: make(map[string]map[string]float64)}
+		s.byUser[e.UserID] = idx
+	}
+	idx.indexTerms(e)
+	if e.IsRecurring() {
+		idx.recurring[e.ID] = struct{}{}
+		return
+	}
+	pos := storage.CursorOf(e)
+	i := sort.Search(len(idx.single), func(i int) bool { return pos.Before(idx.single[i]) })
+	idx.single = append(idx.single, storage.Cursor{})
+	copy(idx.single[i+1:], idx.single[i:])
+	idx.single[i] = pos
+}
+
+// remove удаляет событие и его запись в индексе.
+func (s *Storage) remove(e storage.Event) {
+	delete(s.events, e.ID)
+	idx := s.byUser[e.UserID]
+	idx.unindexTerms(e)
+	if e.IsRecurring() {
+		delete(idx.recurring, e.ID)
+	} else {
+		pos := storage.CursorOf(e)
+		i := sort.Search(len(idx.single), func(i int) bool { return !idx.single[i].Before(pos) })
+		idx.single = append(idx.single[:i], idx.single[i+1:]...)
+	}
+	if len(idx.single) == 0 && len(idx.recurring) == 0 {
+		delete(s.byUser, e.UserID)
+	}
+}
+
+func (s *Storage) Add(_ context.Context, event storage.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for _, e := range s.events {
-		if e.UserID == event.UserID && e.DateTime.Equal(event.DateTime) {
-			return storage.ErrDateBusy
-		}
+	if _, exists := s.events[event.ID]; exists {
+		return storage.ErrEventExists
+	}
+	if s.isBusy(event, "") {
+		return storage.ErrDateBusy
 	}
-	s.events[event.ID] = event
+	if err := s.logOp(record{Op: opPut, Event: newPersisted(event)}); err != nil {
+		return err
+	}
+	s.put(event)
 	return nil
 }
 
-func (s *Storage) Update(id string, event storage.Event) error {
+func (s *Storage) Update(_ context.Context, userID, id string, event storage.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.events[id]; !exists {
+	old, exists := s.events[id]
+	if !exists || old.UserID != userID {
 		return storage.ErrEventNotFound
 	}
-	s.events[id] = event
+	event.ID, event.UserID = id, userID
+	if s.isBusy(event, id) {
+		return storage.ErrDateBusy
+	}
+	if err := s.logOp(record{Op: opPut, Event: newPersisted(event)}); err != nil {
+		return err
+	}
+	s.remove(old)
+	s.put(event)
 	return nil
 }
 
-func (s *Storage) Delete(id string) error {
+// isBusy проверяет, пересекается ли событие (любой его экземпляр) с другими
+// событиями того же пользователя. Событие с идентификатором skipID (обновляемое) не учитывается.
+func (s *Storage) isBusy(event storage.Event, skipID string) bool {
+	for id, e := range s.events {
+		if id != skipID && e.UserID == event.UserID && e.Conflicts(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) Delete(_ context.Context, userID, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.events[id]; !exists {
+	e, exists := s.events[id]
+	if !exists || e.UserID != userID {
 		return storage.ErrEventNotFound
 	}
-	delete(s.events, id)
+	if err := s.logOp(record{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+	s.remove(e)
 	return nil
 }
 
-func (s *Storage) ListDay(date time.Time) ([]storage.Event, error) {
+func (s *Storage) Get(_ context.Context, userID, id string) (storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var result []storage.Event
-	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	end := start.Add(24 * time.Hour)
+	e, exists := s.events[id]
+	if !exists || e.UserID != userID {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	return e, nil
+}
+
+func (s *Storage) ListAll(_ context.Context, userID string) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
+	var result []storage.Event
 	for _, e := range s.events {
-		if e.DateTime.After(start) && e.DateTime.Before(end) {
+		if e.UserID == userID {
 			result = append(result, e)
 		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return storage.CursorOf(result[i]).Before(storage.CursorOf(result[j]))
+	})
 	return result, nil
 }
 
-func (s *Storage) ListWeek(startDate time.Time) ([]storage.Event, error) {
+func (s *Storage) ListDay(
+	_ context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.DayRange(date)
+	return s.listBetween(userID, start, end, mode), nil
+}
+
+func (s *Storage) ListWeek(
+	_ context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.WeekRange(startDate)
+	return s.listBetween(userID, start, end, mode), nil
+}
+
+func (s *Storage) ListMonth(
+	_ context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.MonthRange(startDate)
+	return s.listBetween(userID, start, end, mode), nil
+}
+
+// listBetween возвращает экземпляры событий пользователя за [start, end) в режиме mode.
+func (s *Storage) listBetween(userID string, start, end time.Time, mode storage.RangeMode) []storage.Event {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var result []storage.Event
-	start := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
-	end := start.Add(7 * 24 * time.Hour)
-
 	for _, e := range s.events {
-		if e.DateTime.After(start) && e.DateTime.Before(end) {
-			result = append(result, e)
+		if e.UserID == userID {
+			result = append(result, e.OccurrencesIn(start, end, mode)...)
 		}
 	}
-	return result, nil
+	return result
+}
+
+func (s *Storage) List(
+	_ context.Context, userID string, from, to time.Time, cursor string, limit int,
+) ([]storage.Event, string, error) {
+	c, err := storage.ValidateList(from, to, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx, ok := s.byUser[userID]
+	if !ok {
+		return nil, "", nil
+	}
+	// Первая позиция, которая не раньше from и идёт после курсора
+	i := sort.Search(len(idx.single), func(i int) bool {
+		pos := idx.single[i]
+		return !pos.DateTime.Before(from) && c.Before(pos)
+	})
+	var single []storage.Event
+	for ; i < len(idx.single) && len(single) <= limit && idx.single[i].DateTime.Before(to); i++ {
+		single = append(single, s.events[idx.single[i].ID])
+	}
+	series := make([]storage.Event, 0, len(idx.recurring))
+	for id := range idx.recurring {
+		series = append(series, s.events[id])
+	}
+	events, next := storage.MergePage(single, series, c, from, to, limit)
+	return events, next, nil
 }
 
-func (s *Storage) ListMonth(startDate time.Time) ([]storage.Event, error) {
+func (s *Storage) ListToNotify(_ context.Context, from, to time.Time) ([]storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var result []storage.Event
-	start := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, startDate.Location())
-	end := start.AddDate(0, 1, 0)
+	for _, e := range s.events {
+		if e.NotifyBefore <= 0 {
+			continue
+		}
+		// Уведомление в [from, to) ⇔ начало экземпляра в [from+NotifyBefore, to+NotifyBefore)
+		before := time.Duration(e.NotifyBefore) * time.Second
+		result = append(result, e.Occurrences(from.Add(before), to.Add(before))...)
+	}
+	return result, nil
+}
+
+func (s *Storage) DeleteBefore(_ context.Context, t time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := s.expiredBefore(t)
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	if err := s.logOp(record{Op: opDeleteBefore, Before: &t}); err != nil {
+		return 0, err
+	}
+	for _, e := range expired {
+		s.remove(e)
+	}
+	return len(expired), nil
+}
 
+// expiredBefore возвращает события, последний экземпляр которых начался раньше t.
+func (s *Storage) expiredBefore(t time.Time) []storage.Event {
+	var result []storage.Event
 	for _, e := range s.events {
-		if e.DateTime.After(start) && e.DateTime.Before(end) {
+		if last, ok := e.LastOccurrence(); ok && last.Before(t) {
 			result = append(result, e)
 		}
 	}
-	return result, nil
+	return result
+}
+
+// Close сохраняет снимок и закрывает журнал, если хранилище создано Open;
+// хранилищу из New освобождать нечего.
+func (s *Storage) Close() error {
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist.close(s)
 }
@@ -1,16 +1,20 @@
 package inmemory
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/storagetest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInMemoryStorage(t *testing.T) {
+	ctx := context.Background()
 	s := New()
 	now := time.Now()
 	event := storage.Event{
@@ -22,23 +26,27 @@ func TestInMemoryStorage(t *testing.T) {
 	}
 
 	// Add
-	assert.NoError(t, s.Add(event))
-	assert.ErrorIs(t, s.Add(event), storage.ErrDateBusy)
+	assert.NoError(t, s.Add(ctx, event))
+	assert.ErrorIs(t, s.Add(ctx, event), storage.ErrEventExists)
+	busy := event
+	busy.ID = "2"
+	assert.ErrorIs(t, s.Add(ctx, busy), storage.ErrDateBusy)
 
 	// List
-	events, _ := s.ListDay(now)
+	events, _ := s.ListDay(ctx, "user1", now, storage.StartsInRange)
 	assert.Len(t, events, 1)
 
 	// Update
 	event.Title = "Updated"
-	assert.NoError(t, s.Update("1", event))
+	assert.NoError(t, s.Update(ctx, "user1", "1", event))
 
 	// Delete
-	assert.NoError(t, s.Delete("1"))
-	assert.ErrorIs(t, s.Delete("1"), storage.ErrEventNotFound)
+	assert.NoError(t, s.Delete(ctx, "user1", "1"))
+	assert.ErrorIs(t, s.Delete(ctx, "user1", "1"), storage.ErrEventNotFound)
 }
 
 func TestInMemoryStorage_DateBusyPerUser(t *testing.T) {
+	ctx := context.Background()
 	s := New()
 	now := time.Now()
 
@@ -64,12 +72,13 @@ func TestInMemoryStorage_DateBusyPerUser(t *testing.T) {
 		Duration: 3600,
 	}
 
-	assert.NoError(t, s.Add(event1))
-	assert.ErrorIs(t, s.Add(event2), storage.ErrDateBusy) // ← ожидаем ошибку
-	assert.NoError(t, s.Add(event3))                      // ← разрешено
+	assert.NoError(t, s.Add(ctx, event1))
+	assert.ErrorIs(t, s.Add(ctx, event2), storage.ErrDateBusy) // ← ожидаем ошибку
+	assert.NoError(t, s.Add(ctx, event3))                      // ← разрешено
 }
 
 func TestInMemoryStorage_ListWeekAndMonth(t *testing.T) {
+	ctx := context.Background()
 	s := New()
 	now := time.Now()
 
@@ -83,23 +92,24 @@ func TestInMemoryStorage_ListWeekAndMonth(t *testing.T) {
 	nextMonth := now.AddDate(0, 1, 0)
 	eventNextMonth := storage.Event{ID: "4", UserID: "user1", DateTime: nextMonth, Duration: 3600}
 
-	assert.NoError(t, s.Add(eventToday))
-	assert.NoError(t, s.Add(eventIn3Days))
-	assert.NoError(t, s.Add(eventIn10Days))
-	assert.NoError(t, s.Add(eventNextMonth))
+	assert.NoError(t, s.Add(ctx, eventToday))
+	assert.NoError(t, s.Add(ctx, eventIn3Days))
+	assert.NoError(t, s.Add(ctx, eventIn10Days))
+	assert.NoError(t, s.Add(ctx, eventNextMonth))
 
 	// ListWeek
-	weekEvents, err := s.ListWeek(now)
+	weekEvents, err := s.ListWeek(ctx, "user1", now, storage.StartsInRange)
 	assert.NoError(t, err)
 	assert.Len(t, weekEvents, 2) // today + in 3 days
 
 	// ListMonth
-	monthEvents, err := s.ListMonth(now)
+	monthEvents, err := s.ListMonth(ctx, "user1", now, storage.StartsInRange)
 	assert.NoError(t, err)
 	assert.Len(t, monthEvents, 3) // все, кроме nextMonth
 }
 
 func TestInMemoryStorage_Concurrency(t *testing.T) {
+	ctx := context.Background()
 	s := New()
 	now := time.Now()
 	const numWorkers = 10
@@ -119,7 +129,7 @@ func TestInMemoryStorage_Concurrency(t *testing.T) {
 					Duration: 3600,
 					Title:    fmt.Sprintf("Event %d-%d", workerID, j),
 				}
-				err := s.Add(event)
+				err := s.Add(ctx, event)
 				// Должно быть без ошибок, так как у каждого свой UserID и время
 				assert.NoError(t, err)
 			}
@@ -129,6 +139,299 @@ func TestInMemoryStorage_Concurrency(t *testing.T) {
 	wg.Wait()
 
 	// Проверим общее количество
-	events, _ := s.ListMonth(now)
-	assert.Len(t, events, numWorkers*eventsPerWorker)
+	total := 0
+	for i := 0; i < numWorkers; i++ {
+		events, _ := s.ListMonth(ctx, fmt.Sprintf("user-%d", i), now, storage.StartsInRange)
+		total += len(events)
+	}
+	assert.Equal(t, numWorkers*eventsPerWorker, total)
+}
+
+func TestInMemoryStorage_ListToNotify(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	base := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	// Уведомление за час — в 11:00
+	assert.NoError(t, s.Add(ctx, storage.Event{ID: "1", UserID: "user1", DateTime: base, NotifyBefore: 3600}))
+	// Уведомление за сутки — 9 мая, 12:00
+	assert.NoError(t, s.Add(ctx, storage.Event{
+		ID: "2", UserID: "user1", DateTime: base.Add(time.Hour), NotifyBefore: 86400,
+	}))
+	// Без уведомления
+	assert.NoError(t, s.Add(ctx, storage.Event{ID: "3", UserID: "user1", DateTime: base.Add(2 * time.Hour)}))
+
+	events, err := s.ListToNotify(ctx, base.Add(-2*time.Hour), base.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, events) // граница to не включается
+
+	events, err = s.ListToNotify(ctx, base.Add(-time.Hour), base)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "1", events[0].ID)
+}
+
+func TestInMemoryStorage_DeleteBefore(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	now := time.Now()
+
+	assert.NoError(t, s.Add(ctx, storage.Event{ID: "old", UserID: "user1", DateTime: now.AddDate(-1, 0, -1)}))
+	assert.NoError(t, s.Add(ctx, storage.Event{ID: "recent", UserID: "user1", DateTime: now.AddDate(0, -1, 0)}))
+
+	deleted, err := s.DeleteBefore(ctx, now.AddDate(-1, 0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	assert.ErrorIs(t, s.Delete(ctx, "user1", "old"), storage.ErrEventNotFound)
+	assert.NoError(t, s.Delete(ctx, "user1", "recent"))
+}
+
+func TestInMemoryStorage_Overlap(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+	existing := storage.Event{ID: "1", UserID: "user1", DateTime: base, Duration: 3600} // 10:00–11:00
+
+	tests := []struct {
+		name  string
+		event storage.Event
+		err   error
+	}{
+		{"starts inside", storage.Event{DateTime: base.Add(30 * time.Minute), Duration: 3600}, storage.ErrDateBusy},
+		{"ends inside", storage.Event{DateTime: base.Add(-30 * time.Minute), Duration: 3600}, storage.ErrDateBusy},
+		{"covers", storage.Event{DateTime: base.Add(-time.Hour), Duration: 3 * 3600}, storage.ErrDateBusy},
+		{"zero duration inside", storage.Event{DateTime: base.Add(time.Minute)}, storage.ErrDateBusy},
+		{"right before", storage.Event{DateTime: base.Add(-time.Hour), Duration: 3600}, nil},
+		{"right after", storage.Event{DateTime: base.Add(time.Hour), Duration: 3600}, nil},
+		{"other user", storage.Event{DateTime: base, Duration: 3600, UserID: "user2"}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New()
+			assert.NoError(t, s.Add(ctx, existing))
+
+			event := tc.event
+			event.ID = "2"
+			if event.UserID == "" {
+				event.UserID = "user1"
+			}
+			assert.ErrorIs(t, s.Add(ctx, event), tc.err)
+		})
+	}
+}
+
+func TestInMemoryStorage_UpdateOverlap(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	base := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+	first := storage.Event{ID: "1", UserID: "user1", DateTime: base, Duration: 3600}
+	second := storage.Event{ID: "2", UserID: "user1", DateTime: base.Add(2 * time.Hour), Duration: 3600}
+	assert.NoError(t, s.Add(ctx, first))
+	assert.NoError(t, s.Add(ctx, second))
+
+	// Сдвиг события в пределах собственного интервала допустим
+	first.Duration = 5400
+	assert.NoError(t, s.Update(ctx, "user1", "1", first))
+
+	// Пересечение со вторым событием — нет
+	second.DateTime = base.Add(time.Hour)
+	assert.ErrorIs(t, s.Update(ctx, "user1", "2", second), storage.ErrDateBusy)
+}
+
+func TestInMemoryStorage_Ownership(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	now := time.Now()
+	event := storage.Event{ID: "1", UserID: "user1", DateTime: now, Duration: 3600, Title: "Private"}
+	assert.NoError(t, s.Add(ctx, event))
+
+	// Чужие события не видны
+	events, err := s.ListDay(ctx, "user2", now, storage.StartsInRange)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// И не могут быть изменены или удалены
+	event.Title = "Hijacked"
+	assert.ErrorIs(t, s.Update(ctx, "user2", "1", event), storage.ErrEventNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, "user2", "1"), storage.ErrEventNotFound)
+	// Или перезаписаны созданием события с тем же ID
+	event.UserID = "user2"
+	assert.ErrorIs(t, s.Add(ctx, event), storage.ErrEventExists)
+	_, err = s.Get(ctx, "user2", "1")
+	assert.ErrorIs(t, err, storage.ErrEventNotFound)
+	all, err := s.ListAll(ctx, "user2")
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+
+	events, err = s.ListDay(ctx, "user1", now, storage.StartsInRange)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Private", events[0].Title)
+}
+
+func TestInMemoryStorage_Recurring(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	monday := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	standup := storage.Event{
+		ID: "standup", UserID: "user1", Title: "Standup", DateTime: monday, Duration: 900,
+		NotifyBefore: 600, RRule: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=10",
+		ExDates: []time.Time{monday.AddDate(0, 0, 2)},
+	}
+	assert.NoError(t, s.Add(ctx, standup))
+
+	// Пересечение с любым экземпляром серии запрещено
+	clash := storage.Event{ID: "clash", UserID: "user1", DateTime: monday.AddDate(0, 0, 8), Duration: 60}
+	assert.ErrorIs(t, s.Add(ctx, clash), storage.ErrDateBusy)
+	// Отменённый экземпляр время не занимает
+	clash.DateTime = monday.AddDate(0, 0, 2)
+	assert.NoError(t, s.Add(ctx, clash))
+
+	events, err := s.ListWeek(ctx, "user1", monday, storage.StartsInRange)
+	assert.NoError(t, err)
+	assert.Len(t, events, 5) // 4 экземпляра серии и разовое событие
+
+	events, err = s.ListDay(ctx, "user1", monday.AddDate(0, 0, 7), storage.StartsInRange)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "standup", events[0].ID)
+		assert.True(t, monday.AddDate(0, 0, 7).Equal(events[0].DateTime))
+	}
+
+	// Get и ListAll возвращают серию целиком, без разворачивания
+	got, err := s.Get(ctx, "user1", "standup")
+	assert.NoError(t, err)
+	assert.Equal(t, standup, got)
+	events, err = s.ListAll(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	// Уведомление приходит для каждого экземпляра отдельно
+	notifyAt := monday.AddDate(0, 0, 1).Add(-10 * time.Minute)
+	events, err = s.ListToNotify(ctx, notifyAt, notifyAt.Add(time.Minute))
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.True(t, monday.AddDate(0, 0, 1).Equal(events[0].DateTime))
+	}
+
+	// Серия удаляется, только когда закончился её последний экземпляр
+	deleted, err := s.DeleteBefore(ctx, monday.AddDate(0, 0, 7))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted) // разовое событие
+	deleted, err = s.DeleteBefore(ctx, monday.AddDate(0, 0, 12))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
+
+func TestInMemoryStorage_List(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	base := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	// Десять разовых событий и ежедневная серия
+	for i := range 10 {
+		assert.NoError(t, s.Add(ctx, storage.Event{
+			ID: fmt.Sprintf("e%d", i), UserID: "user1", Title: "Once",
+			DateTime: base.Add(time.Duration(i/2) * time.Hour).Add(time.Duration(i%2) * time.Minute),
+			Duration: 0,
+		}))
+	}
+	assert.NoError(t, s.Add(ctx, storage.Event{
+		ID: "daily", UserID: "user1", Title: "Daily", DateTime: base.Add(-time.Hour), Duration: 1800,
+		RRule: "FREQ=DAILY",
+	}))
+	assert.NoError(t, s.Add(ctx, storage.Event{ID: "other", UserID: "user2", Title: "Other", DateTime: base}))
+
+	from, to := base.Add(-2*time.Hour), base.AddDate(0, 0, 2)
+	var all []storage.Event
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 10)
+		page, next, err := s.List(ctx, "user1", from, to, cursor, 3)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), 3)
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	require.Len(t, all, 13) // 10 разовых и 3 экземпляра серии
+	for i := 1; i < len(all); i++ {
+		assert.True(t, storage.CursorOf(all[i-1]).Before(storage.CursorOf(all[i])), "order at %d", i)
+	}
+	assert.Equal(t, "daily", all[0].ID)
+	assert.Equal(t, "daily", all[12].ID)
+
+	// Индекс обновляется при изменении и удалении
+	moved := storage.Event{ID: "e0", UserID: "user1", Title: "Moved", DateTime: base.AddDate(0, 0, 3)}
+	assert.NoError(t, s.Update(ctx, "user1", "e0", moved))
+	assert.NoError(t, s.Delete(ctx, "user1", "e1"))
+	page, next, err := s.List(ctx, "user1", base, base.Add(time.Hour), "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Empty(t, page)
+	page, _, err = s.List(ctx, "user1", base.AddDate(0, 0, 3), base.AddDate(0, 0, 4), "", 10)
+	assert.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "e0", page[0].ID)
+
+	_, _, err = s.List(ctx, "user1", from, to, "broken!", 3)
+	assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+	page, _, err = s.List(ctx, "nobody", from, to, "", 3)
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+func TestInMemoryStorage_Search(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	base := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	add := func(id, title, description string, day int) {
+		assert.NoError(t, s.Add(ctx, storage.Event{
+			ID: id, UserID: "user1", Title: title, Description: description, DateTime: base.AddDate(0, 0, day),
+		}))
+	}
+	add("notes", "Planning", "Notes about the budget", 0)
+	add("budget", "Budget review", "Quarterly budget, final numbers", 1)
+	add("ёлка", "Ёлка в офисе", "Бюджет на украшения", 2)
+	add("sync", "Team sync", "", 3)
+	assert.NoError(t, s.Add(ctx, storage.Event{ID: "other", UserID: "user2", Title: "Budget", DateTime: base}))
+
+	ids := func(query string) []string {
+		events, err := s.Search(ctx, "user1", query, 10)
+		assert.NoError(t, err)
+		result := make([]string, 0, len(events))
+		for _, e := range events {
+			result = append(result, e.ID)
+		}
+		return result
+	}
+
+	// Совпадение в названии весит больше, чем в описании
+	assert.Equal(t, []string{"budget", "notes"}, ids("BUDGET"))
+	// Нужны все слова запроса
+	assert.Equal(t, []string{"budget"}, ids("budget numbers"))
+	assert.Equal(t, []string{"ёлка"}, ids("елка бюджет"))
+	assert.Empty(t, ids("budget lunch"))
+	assert.Empty(t, ids("!!!"))
+
+	// Индекс следует за изменениями
+	assert.NoError(t, s.Update(ctx, "user1", "sync", storage.Event{
+		UserID: "user1", Title: "Budget sync", DateTime: base.AddDate(0, 0, 3),
+	}))
+	assert.NoError(t, s.Delete(ctx, "user1", "notes"))
+	assert.Equal(t, []string{"budget", "sync"}, ids("budget"))
+
+	events, err := s.Search(ctx, "user1", "budget", 1)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	_, err = s.Search(ctx, "user1", "  ", 10)
+	assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+}
+
+func TestInMemoryStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) storage.Storage { return New() })
 }
@@ -1,13 +1,17 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
 var (
 	ErrEventNotFound = errors.New("event not found")
 	ErrDateBusy      = errors.New("date is already occupied")
+	ErrInvalidEvent  = errors.New("invalid event")
+	ErrEventExists   = errors.New("event with this id already exists")
 )
 
 type Event struct {
@@ -18,13 +22,95 @@ type Event struct {
 	Description  string    `db:"description"`
 	UserID       string    `db:"user_id"`
 	NotifyBefore int64     `db:"notify_before"` // seconds
+	// RRule — правило повторения RFC 5545 без префикса RRULE:, например
+	// "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10". Пустое — событие разовое.
+	// Частота — не чаще DAILY, COUNT и UNTIL ограничены MaxRecurrenceCount и MaxRecurrenceSpan.
+	RRule string `db:"rrule"`
+	// ExDates — начала экземпляров повторяющегося события, которые отменены.
+	ExDates []time.Time `db:"-"`
 }
 
+// End возвращает момент окончания события. Событие нулевой длительности
+// занимает минимальный слот в одну секунду, чтобы пересекаться с событиями,
+// начинающимися в то же время.
+func (e Event) End() time.Time {
+	return e.DateTime.Add(time.Duration(max(e.Duration, 1)) * time.Second)
+}
+
+// Overlaps сообщает, пересекаются ли интервалы [DateTime, End()) двух событий.
+func (e Event) Overlaps(o Event) bool {
+	return e.DateTime.Before(o.End()) && o.DateTime.Before(e.End())
+}
+
+// Validate проверяет обязательные поля события.
+func (e Event) Validate() error {
+	switch {
+	case e.Title == "":
+		return fmt.Errorf("%w: title is required", ErrInvalidEvent)
+	case e.UserID == "":
+		return fmt.Errorf("%w: user_id is required", ErrInvalidEvent)
+	case e.DateTime.IsZero():
+		return fmt.Errorf("%w: datetime is required", ErrInvalidEvent)
+	case e.Duration < 0:
+		return fmt.Errorf("%w: duration must not be negative", ErrInvalidEvent)
+	case e.NotifyBefore < 0:
+		return fmt.Errorf("%w: notify_before must not be negative", ErrInvalidEvent)
+	case len(e.ExDates) > 0 && !e.IsRecurring():
+		return fmt.Errorf("%w: exdates require rrule", ErrInvalidEvent)
+	}
+	if e.IsRecurring() {
+		r, err := e.rule()
+		if err == nil {
+			err = e.checkRule(r)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: invalid rrule: %w", ErrInvalidEvent, err)
+		}
+	}
+	return nil
+}
+
+// Storage — хранилище событий. Пользовательские операции ограничены
+// событиями пользователя userID: чужие события не возвращаются, а попытка
+// изменить или удалить их завершается ErrEventNotFound.
 type Storage interface {
-	Add(event Event) error
-	Update(id string, event Event) error
-	Delete(id string) error
-	ListDay(date time.Time) ([]Event, error)
-	ListWeek(startDate time.Time) ([]Event, error)
-	ListMonth(startDate time.Time) ([]Event, error)
+	// Add сохраняет событие; владелец задаётся полем event.UserID.
+	// Если событие с таким ID уже есть (в том числе у другого пользователя) — ErrEventExists,
+	// если оно пересекается с другим событием пользователя — ErrDateBusy.
+	Add(ctx context.Context, event Event) error
+	// Update заменяет событие id пользователя userID; ID и владелец берутся из аргументов.
+	// Если события нет — ErrEventNotFound, если новое время занято — ErrDateBusy.
+	Update(ctx context.Context, userID, id string, event Event) error
+	Delete(ctx context.Context, userID, id string) error
+	// Get возвращает событие пользователя в том виде, в каком оно сохранено.
+	Get(ctx context.Context, userID, id string) (Event, error)
+	// ListAll возвращает все события пользователя без разворачивания повторений,
+	// упорядоченные по началу, а при одинаковом начале — по ID.
+	ListAll(ctx context.Context, userID string) ([]Event, error)
+	// ListDay, ListWeek и ListMonth возвращают экземпляры событий за период [start, end)
+	// в режиме mode (см. Event.OccurrencesIn): повторяющееся событие попадает в результат
+	// столько раз, сколько его экземпляров в периоде. Период начинается в полночь date
+	// (для ListMonth — первого числа месяца) в часовом поясе date.
+	ListDay(ctx context.Context, userID string, date time.Time, mode RangeMode) ([]Event, error)
+	ListWeek(ctx context.Context, userID string, startDate time.Time, mode RangeMode) ([]Event, error)
+	ListMonth(ctx context.Context, userID string, startDate time.Time, mode RangeMode) ([]Event, error)
+	// List возвращает не более limit экземпляров событий, начинающихся в [from, to),
+	// в порядке (DateTime, ID), начиная с позиции после cursor (пустой — с начала),
+	// и курсор следующей страницы — пустой, если страница последняя.
+	// Некорректные параметры — ErrInvalidQuery.
+	List(ctx context.Context, userID string, from, to time.Time, cursor string, limit int) ([]Event, string, error)
+	// Search ищет события пользователя, в Title или Description которых есть все слова query,
+	// и возвращает не более limit событий без разворачивания повторений — сначала
+	// наиболее релевантные; совпадение в названии весит больше, чем в описании.
+	// Слова сравниваются после Tokenize, поэтому словоформы с общим началом совпадают.
+	// Синтаксиса запросов нет: OR — обычное слово, кавычки и «-» разделяют слова.
+	Search(ctx context.Context, userID, query string, limit int) ([]Event, error)
+	// ListToNotify возвращает экземпляры событий, время уведомления которых
+	// (DateTime - NotifyBefore) попадает в полуинтервал [from, to).
+	ListToNotify(ctx context.Context, from, to time.Time) ([]Event, error)
+	// DeleteBefore удаляет события, последний экземпляр которых начался раньше t,
+	// и возвращает их количество. Бесконечные повторяющиеся события не удаляются.
+	DeleteBefore(ctx context.Context, t time.Time) (int, error)
+	// Close освобождает ресурсы хранилища (соединения с БД и т.п.).
+	Close() error
 }
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery — некорректные параметры выборки (курсор, лимит, поисковый запрос).
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Cursor — позиция экземпляра события в выдаче List. Экземпляры упорядочены
+// по началу, а при одинаковом начале — по ID. Нулевой Cursor предшествует всем событиям.
+type Cursor struct {
+	DateTime time.Time
+	ID       string
+}
+
+// CursorOf возвращает позицию экземпляра e.
+func CursorOf(e Event) Cursor {
+	return Cursor{DateTime: e.DateTime, ID: e.ID}
+}
+
+// Before сообщает, идёт ли позиция c раньше o.
+func (c Cursor) Before(o Cursor) bool {
+	if !c.DateTime.Equal(o.DateTime) {
+		return c.DateTime.Before(o.DateTime)
+	}
+	return c.ID < o.ID
+}
+
+// String кодирует курсор в непрозрачную строку для передачи клиенту.
+func (c Cursor) String() string {
+	raw := strconv.FormatInt(c.DateTime.UnixNano(), 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor разбирает строку, полученную из Cursor.String. Пустая строка — нулевой курсор.
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return Cursor{DateTime: time.Unix(0, n).UTC(), ID: id}, nil
+}
+
+// OccurrencesAfter возвращает не более n экземпляров события из [from, to),
+// идущих после позиции c. В отличие от Occurrences, правило разворачивается
+// только до n-го подходящего экземпляра. Курсор и период задаёт клиент, поэтому
+// перебор, как и в Occurrences, ограничен maxScanned экземплярами от DTSTART.
+func (e Event) OccurrencesAfter(c Cursor, from, to time.Time, n int) []Event {
+	if !e.IsRecurring() {
+		if c.Before(CursorOf(e)) && !e.DateTime.Before(from) && e.DateTime.Before(to) && n > 0 {
+			return []Event{e}
+		}
+		return nil
+	}
+
+	r, err := e.rule()
+	if err != nil {
+		return nil
+	}
+	var result []Event
+	next := r.Iterator()
+	for scanned := 0; scanned < maxScanned && len(result) < n; scanned++ {
+		t, ok := next()
+		if !ok || !t.Before(to) {
+			break
+		}
+		if t.Before(from) || e.isExcluded(t) || !c.Before(Cursor{DateTime: t, ID: e.ID}) {
+			continue
+		}
+		occ := e
+		occ.DateTime = t
+		result = append(result, occ)
+	}
+	return result
+}
+
+// MergePage собирает страницу List из разовых событий single, уже отобранных
+// после курсора и упорядоченных (достаточно первых limit+1), и повторяющихся серий series.
+// Возвращает не более limit экземпляров и курсор следующей страницы (пустой, если её нет).
+func MergePage(single, series []Event, c Cursor, from, to time.Time, limit int) ([]Event, string) {
+	result := single
+	for _, e := range series {
+		result = append(result, e.OccurrencesAfter(c, from, to, limit+1)...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return CursorOf(result[i]).Before(CursorOf(result[j]))
+	})
+	if len(result) <= limit {
+		return result, ""
+	}
+	return result[:limit], CursorOf(result[limit-1]).String()
+}
+
+// ValidateList проверяет параметры List и разбирает курсор.
+func ValidateList(from, to time.Time, cursor string, limit int) (Cursor, error) {
+	if limit <= 0 {
+		return Cursor{}, fmt.Errorf("%w: limit must be positive", ErrInvalidQuery)
+	}
+	if !from.Before(to) {
+		return Cursor{}, fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+	return ParseCursor(cursor)
+}
+
+// ValidateSearch проверяет параметры Search.
+func ValidateSearch(query string, limit int) error {
+	if limit <= 0 {
+		return fmt.Errorf("%w: limit must be positive", ErrInvalidQuery)
+	}
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("%w: search query is empty", ErrInvalidQuery)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	c := Cursor{DateTime: base.Add(123 * time.Nanosecond), ID: "id:with:colons"}
+	parsed, err := ParseCursor(c.String())
+	require.NoError(t, err)
+	assert.True(t, c.DateTime.Equal(parsed.DateTime))
+	assert.Equal(t, c.ID, parsed.ID)
+
+	zero, err := ParseCursor("")
+	require.NoError(t, err)
+	assert.True(t, zero.Before(c))
+
+	for _, s := range []string{"%%%", "bm9jb2xvbg", "eDox"} { // "nocolon", "x:1"
+		_, err := ParseCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidQuery, s)
+	}
+
+	// При одинаковом начале порядок определяется ID
+	assert.True(t, Cursor{DateTime: base, ID: "a"}.Before(Cursor{DateTime: base, ID: "b"}))
+	assert.False(t, Cursor{DateTime: base, ID: "b"}.Before(Cursor{DateTime: base, ID: "a"}))
+	assert.True(t, Cursor{DateTime: base, ID: "z"}.Before(Cursor{DateTime: base.Add(time.Second), ID: "a"}))
+}
+
+func TestEvent_OccurrencesAfter(t *testing.T) {
+	daily := Event{ID: "d", DateTime: base, RRule: "FREQ=DAILY", ExDates: []time.Time{base.AddDate(0, 0, 2)}}
+	to := base.AddDate(1, 0, 0)
+
+	assert.Equal(t, []time.Time{base, base.AddDate(0, 0, 1), base.AddDate(0, 0, 3)},
+		starts(daily.OccurrencesAfter(Cursor{}, base, to, 3)))
+	// Экземпляр на позиции курсора уже выдан
+	c := Cursor{DateTime: base.AddDate(0, 0, 1), ID: "d"}
+	assert.Equal(t, []time.Time{base.AddDate(0, 0, 3)}, starts(daily.OccurrencesAfter(c, base, to, 1)))
+	// Экземпляр с тем же началом, но большим ID идёт после курсора
+	c.ID = "a"
+	assert.Equal(t, []time.Time{base.AddDate(0, 0, 1)}, starts(daily.OccurrencesAfter(c, base, to, 1)))
+
+	// Далёкий курсор не заставляет перебирать бесконечную серию до него
+	far := Cursor{DateTime: time.Date(9000, 1, 1, 0, 0, 0, 0, time.UTC), ID: "d"}
+	started := time.Now()
+	assert.Empty(t, daily.OccurrencesAfter(far, base, far.DateTime.AddDate(1, 0, 0), 10))
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	once := Event{ID: "o", DateTime: base}
+	assert.Len(t, once.OccurrencesAfter(Cursor{}, base, to, 1), 1)
+	assert.Empty(t, once.OccurrencesAfter(CursorOf(once), base, to, 1))
+	assert.Empty(t, once.OccurrencesAfter(Cursor{}, base, to, 0))
+}
+
+func TestMergePage(t *testing.T) {
+	single := []Event{
+		{ID: "b", DateTime: base},
+		{ID: "c", DateTime: base.Add(2 * time.Hour)},
+	}
+	series := []Event{{ID: "a", DateTime: base, RRule: "FREQ=HOURLY;COUNT=3"}}
+	to := base.AddDate(0, 0, 1)
+
+	page, next := MergePage(single, series, Cursor{}, base, to, 3)
+	require.Len(t, page, 3)
+	assert.Equal(t, []string{"a", "b", "a"}, []string{page[0].ID, page[1].ID, page[2].ID})
+	require.NotEmpty(t, next)
+
+	c, err := ParseCursor(next)
+	require.NoError(t, err)
+	page, next = MergePage(single[1:], series, c, base, to, 3)
+	require.Len(t, page, 2)
+	assert.Equal(t, "a", page[0].ID)
+	assert.Equal(t, "c", page[1].ID)
+	assert.Empty(t, next)
+}
+
+func TestValidateList(t *testing.T) {
+	_, err := ValidateList(base, base.Add(time.Hour), "", 10)
+	assert.NoError(t, err)
+	_, err = ValidateList(base, base.Add(time.Hour), "", 0)
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = ValidateList(base, base, "", 10)
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = ValidateList(base, base.Add(time.Hour), "!", 10)
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
@@ -0,0 +1,48 @@
+package storage
+
+import "time"
+
+// RangeMode определяет, какие экземпляры попадают в выборку за период [start, end).
+type RangeMode int
+
+const (
+	// StartsInRange — экземпляры, начинающиеся в периоде.
+	StartsInRange RangeMode = iota
+	// OverlapsRange — экземпляры, пересекающиеся с периодом с учётом длительности,
+	// в том числе начавшиеся раньше start и ещё идущие.
+	OverlapsRange
+)
+
+// OccurrencesIn возвращает экземпляры события, попадающие в [start, end) в режиме mode.
+func (e Event) OccurrencesIn(start, end time.Time, mode RangeMode) []Event {
+	if mode != OverlapsRange {
+		return e.Occurrences(start, end)
+	}
+	// Экземпляр, начавшийся раньше start, пересекается с периодом, если ещё не закончился
+	duration := e.End().Sub(e.DateTime)
+	var result []Event
+	for _, o := range e.Occurrences(start.Add(-duration), end) {
+		if o.End().After(start) {
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+// DayRange возвращает сутки, начинающиеся в полночь date в её часовом поясе.
+func DayRange(date time.Time) (start, end time.Time) {
+	start = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// WeekRange возвращает семь суток, начиная с полуночи date.
+func WeekRange(date time.Time) (start, end time.Time) {
+	start, _ = DayRange(date)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// MonthRange возвращает календарный месяц, в который попадает date.
+func MonthRange(date time.Time) (start, end time.Time) {
+	start = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 1, 0)
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRanges_DST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// 31 марта 2024 в Берлине длится 23 часа
+	start, end := DayRange(time.Date(2024, time.March, 31, 15, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2024, time.March, 31, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2024, time.April, 1, 0, 0, 0, 0, loc), end)
+
+	start, end = WeekRange(time.Date(2024, time.March, 28, 9, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2024, time.March, 28, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2024, time.April, 4, 0, 0, 0, 0, loc), end)
+
+	start, end = MonthRange(time.Date(2024, time.March, 31, 23, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2024, time.April, 1, 0, 0, 0, 0, loc), end)
+}
+
+func TestEvent_OccurrencesIn(t *testing.T) {
+	day := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	e := Event{ID: "1", DateTime: day.Add(-time.Hour), Duration: 3600, RRule: "FREQ=HOURLY;COUNT=3"}
+
+	// Экземпляр 23:00 заканчивается ровно в начале периода и не пересекается с ним
+	assert.Len(t, e.OccurrencesIn(day, day.Add(2*time.Hour), StartsInRange), 2)
+	assert.Len(t, e.OccurrencesIn(day, day.Add(2*time.Hour), OverlapsRange), 2)
+	assert.Len(t, e.OccurrencesIn(day.Add(30*time.Minute), day.Add(2*time.Hour), OverlapsRange), 2)
+	assert.Len(t, e.OccurrencesIn(day.Add(30*time.Minute), day.Add(2*time.Hour), StartsInRange), 1)
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// ConflictHorizon — насколько вперёд от начала событий проверяются пересечения,
+// если хотя бы одно из них повторяющееся и не ограничено COUNT/UNTIL.
+const ConflictHorizon = 366 * 24 * time.Hour
+
+// Ограничения правил повторения: разворачивание правила выполняется при каждой записи
+// под блокировкой хранилища, поэтому число экземпляров должно быть предсказуемым.
+const (
+	// MaxRecurrenceCount — наибольшее значение COUNT.
+	MaxRecurrenceCount = 1000
+	// MaxRecurrenceSpan — наибольший промежуток от начала события до UNTIL.
+	MaxRecurrenceSpan = 10 * 366 * 24 * time.Hour
+	// MaxOccurrences — сколько экземпляров наибольшее возвращает Occurrences.
+	MaxOccurrences = 10000
+)
+
+// maxScanned ограничивает число экземпляров, которые перебираются от DTSTART
+// до конца запрошенного периода, включая пропущенные.
+const maxScanned = 100000
+
+// IsRecurring сообщает, задано ли у события правило повторения.
+func (e Event) IsRecurring() bool {
+	return e.RRule != ""
+}
+
+// rule разбирает RRule; DTSTART берётся из DateTime, повторения считаются в его часовом поясе.
+func (e Event) rule() (*rrule.RRule, error) {
+	if strings.ContainsAny(e.RRule, "\r\n") {
+		return nil, fmt.Errorf("rrule must be a single RRULE value without DTSTART")
+	}
+	opt, err := rrule.StrToROptionInLocation(strings.TrimPrefix(e.RRule, "RRULE:"), e.DateTime.Location())
+	if err != nil {
+		return nil, err
+	}
+	opt.Dtstart = e.DateTime
+	return rrule.NewRRule(*opt)
+}
+
+// checkRule проверяет, что правило порождает не больше нескольких десятков экземпляров в сутки
+// и ограничено по числу повторений и сроку, если эти ограничения заданы.
+func (e Event) checkRule(r *rrule.RRule) error {
+	opt := r.OrigOptions
+	switch {
+	case opt.Freq > rrule.DAILY:
+		return fmt.Errorf("freq must be DAILY, WEEKLY, MONTHLY or YEARLY")
+	case len(opt.Byminute) > 1 || len(opt.Bysecond) > 1:
+		return fmt.Errorf("byminute and bysecond must have at most one value")
+	case opt.Count > MaxRecurrenceCount:
+		return fmt.Errorf("count must not exceed %d", MaxRecurrenceCount)
+	case !opt.Until.IsZero() && opt.Until.Sub(e.DateTime) > MaxRecurrenceSpan:
+		return fmt.Errorf("until must be within %d days of datetime", MaxRecurrenceSpan/(24*time.Hour))
+	}
+	return nil
+}
+
+func (e Event) isExcluded(t time.Time) bool {
+	for _, ex := range e.ExDates {
+		if ex.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Occurrences возвращает экземпляры события, начинающиеся в полуинтервале [from, to).
+// Для повторяющегося события каждый экземпляр — копия события с DateTime,
+// равным началу повторения; даты из ExDates пропускаются. Возвращается не больше
+// MaxOccurrences первых экземпляров.
+func (e Event) Occurrences(from, to time.Time) []Event {
+	if !e.IsRecurring() {
+		if !e.DateTime.Before(from) && e.DateTime.Before(to) {
+			return []Event{e}
+		}
+		return nil
+	}
+
+	r, err := e.rule()
+	if err != nil {
+		// Правило проверяется в Validate, сюда попадают только корректные
+		return nil
+	}
+	var result []Event
+	next := r.Iterator()
+	for scanned := 0; scanned < maxScanned && len(result) < MaxOccurrences; scanned++ {
+		t, ok := next()
+		if !ok || !t.Before(to) {
+			break
+		}
+		if t.Before(from) || e.isExcluded(t) {
+			continue
+		}
+		occ := e
+		occ.DateTime = t
+		result = append(result, occ)
+	}
+	return result
+}
+
+// LastOccurrence возвращает начало последнего экземпляра события.
+// Для бесконечного правила (без COUNT и UNTIL) возвращает false, как и для правила,
+// у которого экземпляров больше, чем можно перебрать (сохранённого до введения ограничений).
+func (e Event) LastOccurrence() (time.Time, bool) {
+	if !e.IsRecurring() {
+		return e.DateTime, true
+	}
+	r, err := e.rule()
+	if err != nil || (r.OrigOptions.Count == 0 && r.OrigOptions.Until.IsZero()) {
+		return time.Time{}, false
+	}
+	last := e.DateTime
+	next := r.Iterator()
+	for scanned := 0; scanned < maxScanned; scanned++ {
+		t, ok := next()
+		if !ok {
+			return last, true
+		}
+		last = t
+	}
+	return time.Time{}, false
+}
+
+// Conflicts сообщает, пересекается ли хотя бы один экземпляр e с экземпляром o.
+// Для повторяющихся событий проверяется окно ConflictHorizon от позднего из начал.
+func (e Event) Conflicts(o Event) bool {
+	if !e.IsRecurring() && !o.IsRecurring() {
+		return e.Overlaps(o)
+	}
+
+	from, to := e.DateTime, o.DateTime
+	if to.Before(from) {
+		from, to = to, from
+	}
+	to = to.Add(ConflictHorizon)
+
+	// Оба списка упорядочены по началу: идём по ним навстречу, сдвигая тот,
+	// чей текущий экземпляр заканчивается раньше.
+	a, b := e.Occurrences(from, to), o.Occurrences(from, to)
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if a[i].Overlaps(b[j]) {
+			return true
+		}
+		if a[i].End().Before(b[j].End()) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return false
+}
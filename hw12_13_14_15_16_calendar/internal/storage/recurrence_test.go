@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC) // понедельник
+
+func starts(events []Event) []time.Time {
+	result := make([]time.Time, 0, len(events))
+	for _, e := range events {
+		result = append(result, e.DateTime)
+	}
+	return result
+}
+
+func TestEvent_Occurrences(t *testing.T) {
+	weekly := Event{ID: "1", DateTime: base, Duration: 3600, RRule: "FREQ=WEEKLY;BYDAY=MO,WE"}
+
+	occ := weekly.Occurrences(base, base.AddDate(0, 0, 14))
+	assert.Equal(t, []time.Time{
+		base,
+		base.AddDate(0, 0, 2),
+		base.AddDate(0, 0, 7),
+		base.AddDate(0, 0, 9),
+	}, starts(occ))
+	for _, o := range occ {
+		assert.Equal(t, "1", o.ID)
+	}
+
+	// Правая граница не входит в период
+	assert.Len(t, weekly.Occurrences(base, base.AddDate(0, 0, 2)), 1)
+
+	weekly.ExDates = []time.Time{base.AddDate(0, 0, 2)}
+	assert.Equal(t, []time.Time{base, base.AddDate(0, 0, 7)},
+		starts(weekly.Occurrences(base, base.AddDate(0, 0, 8))))
+
+	once := Event{DateTime: base}
+	assert.Len(t, once.Occurrences(base, base.Add(time.Hour)), 1)
+	assert.Empty(t, once.Occurrences(base.Add(time.Second), base.Add(time.Hour)))
+}
+
+func TestEvent_LastOccurrence(t *testing.T) {
+	tests := []struct {
+		name  string
+		rrule string
+		last  time.Time
+		ok    bool
+	}{
+		{"one-off", "", base, true},
+		{"count", "FREQ=DAILY;COUNT=3", base.AddDate(0, 0, 2), true},
+		{"until", "FREQ=WEEKLY;UNTIL=20240601T000000Z", base.AddDate(0, 0, 21), true},
+		{"infinite", "FREQ=DAILY", time.Time{}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			last, ok := Event{DateTime: base, RRule: tc.rrule}.LastOccurrence()
+			assert.Equal(t, tc.ok, ok)
+			assert.True(t, tc.last.Equal(last), "got %s", last)
+		})
+	}
+}
+
+func TestEvent_Conflicts(t *testing.T) {
+	daily := Event{
+		DateTime: base,
+		Duration: 1800,
+		RRule:    "FREQ=DAILY;COUNT=30",
+		ExDates:  []time.Time{base.AddDate(0, 0, 1)},
+	}
+
+	tests := []struct {
+		name     string
+		other    Event
+		conflict bool
+	}{
+		{"one-off on later day", Event{DateTime: base.AddDate(0, 0, 5).Add(15 * time.Minute), Duration: 60}, true},
+		{"one-off between occurrences", Event{DateTime: base.Add(time.Hour), Duration: 3600}, false},
+		{"one-off before series", Event{DateTime: base.AddDate(0, 0, -1), Duration: 60}, false},
+		{"excluded occurrence", Event{DateTime: base.AddDate(0, 0, 1)}, false},
+		{"weekly at same time", Event{DateTime: base.AddDate(0, 0, 3), RRule: "FREQ=WEEKLY"}, true},
+		{"weekly at other time", Event{DateTime: base.Add(2 * time.Hour), RRule: "FREQ=WEEKLY"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.conflict, daily.Conflicts(tc.other))
+			assert.Equal(t, tc.conflict, tc.other.Conflicts(daily), "must be symmetric")
+		})
+	}
+}
+
+func TestEvent_ValidateRecurrence(t *testing.T) {
+	e := Event{Title: "t", UserID: "u", DateTime: base}
+
+	e.RRule = "FREQ=WEEKLY;BYDAY=MO"
+	require.NoError(t, e.Validate())
+
+	e.RRule = "FREQ=SOMETIMES"
+	assert.ErrorIs(t, e.Validate(), ErrInvalidEvent)
+
+	e.RRule = "DTSTART:20240101T000000Z\nFREQ=DAILY"
+	assert.ErrorIs(t, e.Validate(), ErrInvalidEvent)
+
+	e.RRule = ""
+	e.ExDates = []time.Time{base}
+	assert.ErrorIs(t, e.Validate(), ErrInvalidEvent)
+}
+
+func TestEvent_ValidateRecurrenceLimits(t *testing.T) {
+	e := Event{Title: "t", UserID: "u", DateTime: base}
+
+	for _, rule := range []string{
+		"FREQ=DAILY;BYHOUR=9,13,17",
+		"FREQ=DAILY;COUNT=1000",
+		"FREQ=WEEKLY;UNTIL=20340101T000000Z",
+	} {
+		e.RRule = rule
+		assert.NoError(t, e.Validate(), rule)
+	}
+	for rule, problem := range map[string]string{
+		"FREQ=SECONDLY;INTERVAL=10":          "freq must be DAILY, WEEKLY, MONTHLY or YEARLY",
+		"FREQ=MINUTELY":                      "freq must be DAILY, WEEKLY, MONTHLY or YEARLY",
+		"FREQ=HOURLY;INTERVAL=24":            "freq must be DAILY, WEEKLY, MONTHLY or YEARLY",
+		"FREQ=DAILY;BYHOUR=9;BYMINUTE=0,30":  "byminute and bysecond must have at most one value",
+		"FREQ=DAILY;BYSECOND=0,1,2,3":        "byminute and bysecond must have at most one value",
+		"FREQ=DAILY;COUNT=1001":              "count must not exceed 1000",
+		"FREQ=YEARLY;UNTIL=20400101T000000Z": "until must be within 3660 days of datetime",
+	} {
+		e.RRule = rule
+		err := e.Validate()
+		assert.ErrorIs(t, err, ErrInvalidEvent, rule)
+		assert.ErrorContains(t, err, problem, rule)
+	}
+}
+
+func TestEvent_ExpansionLimits(t *testing.T) {
+	// Самое частое допустимое правило — экземпляр каждый час
+	hourly := Event{ID: "1", DateTime: base, Duration: 60, RRule: "FREQ=DAILY;" + everyHour()}
+	require.NoError(t, Event{Title: "t", UserID: "u", DateTime: base, RRule: hourly.RRule}.Validate())
+	assert.Len(t, hourly.Occurrences(base, base.AddDate(2, 0, 0)), MaxOccurrences)
+
+	other := Event{ID: "2", DateTime: base.Add(30 * time.Minute), Duration: 60, RRule: hourly.RRule}
+	start := time.Now()
+	assert.False(t, hourly.Conflicts(other))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Правило, сохранённое до введения ограничений, не разворачивается целиком
+	legacy := Event{ID: "3", DateTime: base, RRule: "FREQ=SECONDLY;UNTIL=20990101T000000Z"}
+	_, ok := legacy.LastOccurrence()
+	assert.False(t, ok)
+	assert.Empty(t, legacy.Occurrences(base.AddDate(1, 0, 0), base.AddDate(1, 0, 1)))
+}
+
+func everyHour() string {
+	hours := make([]string, 24)
+	for h := range hours {
+		hours[h] = strconv.Itoa(h)
+	}
+	return "BYHOUR=" + strings.Join(hours, ",")
+}
@@ -0,0 +1,68 @@
+package storage
+
+import "strings"
+
+// Веса совпадений как у ts_rank в Postgres для весов A (название) и B (описание).
+// Используются хранилищами, которые ищут без Postgres.
+const (
+	TitleWeight       = 1.0
+	DescriptionWeight = 0.4
+)
+
+// SearchPrefixLen — сколько первых букв слова учитывает поиск. Обрезка заменяет стемминг:
+// «встреча» и «встречи» совпадают. Должна совпадать с events_search_text из миграции 00006.
+const SearchPrefixLen = 5
+
+// Tokenize разбивает текст на слова для поиска: латиница, кириллица и цифры в нижнем регистре,
+// ё заменяется на е, от слова остаются первые SearchPrefixLen букв. Остальные символы
+// разделяют слова. Правила повторяют events_search_text в Postgres, чтобы хранилища
+// находили одни и те же события.
+func Tokenize(s string) []string {
+	words := strings.Fields(strings.Map(searchRune, s))
+	for i, w := range words {
+		if r := []rune(w); len(r) > SearchPrefixLen {
+			words[i] = string(r[:SearchPrefixLen])
+		}
+	}
+	return words
+}
+
+// searchRune приводит букву к нижнему регистру, а символ не из алфавита поиска — к пробелу.
+// strings.ToLower не подходит: он знает больше букв, чем translate в миграции.
+func searchRune(r rune) rune {
+	switch {
+	case r >= 'A' && r <= 'Z', r >= 'А' && r <= 'Я':
+		return r + 'a' - 'A'
+	case r == 'Ё', r == 'ё':
+		return 'е'
+	case r >= 'a' && r <= 'z', r >= 'а' && r <= 'я', r >= '0' && r <= '9':
+		return r
+	default:
+		return ' '
+	}
+}
+
+// SearchTerms возвращает вес каждого слова события.
+func SearchTerms(e Event) map[string]float64 {
+	result := make(map[string]float64)
+	for _, t := range Tokenize(e.Title) {
+		result[t] += TitleWeight
+	}
+	for _, t := range Tokenize(e.Description) {
+		result[t] += DescriptionWeight
+	}
+	return result
+}
+
+// SearchWords возвращает различные слова поискового запроса в порядке появления.
+func SearchWords(query string) []string {
+	unique := make(map[string]struct{})
+	var words []string
+	for _, w := range Tokenize(query) {
+		if _, ok := unique[w]; !ok {
+			unique[w] = struct{}{}
+			words = append(words, w)
+		}
+	}
+	return words
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"встре", "с", "коман", "2024", "елка", "relea", "notes"},
+		Tokenize("Встреча с КОМАНДОЙ (2024): Ёлка, RELEASE-notes!"))
+	// Буквы других алфавитов разделяют слова, как и в events_search_text
+	assert.Equal(t, []string{"abc", "de"}, Tokenize("abcßde 日本"))
+	assert.Empty(t, Tokenize("— … «»"))
+}
+
+func TestSearchWords(t *testing.T) {
+	assert.Equal(t, []string{"встре", "or"}, SearchWords("встреча OR встречи"))
+}
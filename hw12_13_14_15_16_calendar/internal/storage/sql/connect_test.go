@@ -0,0 +1,90 @@
+package sqlstorage
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB отвечает на Ping ошибками из errs, затем успешно.
+type fakeDB struct {
+	errs  []error
+	calls int
+}
+
+func (f *fakeDB) PingContext(context.Context) error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func discardLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestWaitForDB(t *testing.T) {
+	ctx := context.Background()
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	t.Run("retries until available", func(t *testing.T) {
+		db := &fakeDB{errs: []error{refused, &pq.Error{Code: "57P03", Message: "the database system is starting up"}}}
+		require.NoError(t, waitForDB(ctx, db, 5*time.Second, discardLogger()))
+		assert.Equal(t, 3, db.calls)
+	})
+
+	t.Run("single attempt without timeout", func(t *testing.T) {
+		db := &fakeDB{errs: []error{refused}}
+		assert.ErrorIs(t, waitForDB(ctx, db, 0, discardLogger()), syscall.ECONNREFUSED)
+		assert.Equal(t, 1, db.calls)
+	})
+
+	t.Run("gives up at deadline", func(t *testing.T) {
+		db := &fakeDB{errs: []error{refused, refused, refused, refused, refused}}
+		start := time.Now()
+		err := waitForDB(ctx, db, 500*time.Millisecond, discardLogger())
+		assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+		assert.Less(t, time.Since(start), time.Second)
+		// Паузы 200ms и 400ms: третья попытка уже не укладывается в срок
+		assert.Equal(t, 2, db.calls)
+	})
+
+	t.Run("does not retry server errors", func(t *testing.T) {
+		db := &fakeDB{errs: []error{&pq.Error{Code: "28P01", Message: "password authentication failed"}}}
+		err := waitForDB(ctx, db, 5*time.Second, discardLogger())
+		assert.ErrorContains(t, err, "password authentication failed")
+		assert.Equal(t, 1, db.calls)
+	})
+
+	t.Run("stops on cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		db := &fakeDB{errs: []error{refused}}
+		assert.ErrorIs(t, waitForDB(ctx, db, 5*time.Second, discardLogger()), syscall.ECONNREFUSED)
+		assert.Equal(t, 1, db.calls)
+	})
+}
+
+func TestConnect_Unavailable(t *testing.T) {
+	// Порт, на котором никто не слушает
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().(*net.TCPAddr)
+	require.NoError(t, lis.Close())
+
+	dsn := "postgres://calendar@127.0.0.1:" + strconv.Itoa(addr.Port) + "/calendar?sslmode=disable"
+	_, err = Connect(context.Background(), dsn, Options{MaxOpenConns: 2}, discardLogger())
+	assert.ErrorContains(t, err, "failed to connect to database (1 attempts)")
+}
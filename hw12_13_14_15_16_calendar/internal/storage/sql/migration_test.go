@@ -0,0 +1,58 @@
+package sqlstorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigration_ExistingOverlaps — база, созданная до проверки пересечений (схема 00001
+// без schema_migrations), может уже содержать пересекающиеся события.
+func TestMigration_ExistingOverlaps(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+	db, err := sqlx.Open("postgres", testDSN)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	// Одно соединение: search_path действует на все запросы теста и мигратора
+	db.SetMaxOpenConns(1)
+
+	const schema = "overlap_migration_test"
+	_, err = db.ExecContext(ctx, `DROP SCHEMA IF EXISTS `+schema+` CASCADE; CREATE SCHEMA `+schema+`;
+		SET search_path TO `+schema+`, public`)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = db.Exec(`DROP SCHEMA IF EXISTS ` + schema + ` CASCADE`) })
+
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE events (
+			id TEXT PRIMARY KEY, title TEXT NOT NULL, datetime TIMESTAMP WITH TIME ZONE NOT NULL,
+			duration BIGINT NOT NULL, description TEXT, user_id TEXT NOT NULL, notify_before BIGINT
+		);
+		INSERT INTO events (id, title, datetime, duration, user_id) VALUES
+			('a', 'Standup', '2024-05-10 10:00:00Z', 3600, 'user1'),
+			('b', 'Review', '2024-05-10 10:30:00Z', 3600, 'user1'),
+			('c', 'Lunch', '2024-05-10 11:00:00Z', 3600, 'user1'),
+			('d', 'Standup', '2024-05-10 10:00:00Z', 3600, 'user2')`)
+	require.NoError(t, err)
+
+	m, err := migration.New(db)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 pair(s) of overlapping events")
+	assert.Contains(t, err.Error(), "user1: a & b, user1: b & c")
+	assert.NotContains(t, err.Error(), "user2")
+
+	// Миграция применяется целиком или не применяется: после исправления данных проходит
+	_, err = db.ExecContext(ctx, `DELETE FROM events WHERE id = 'b'`)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, m.Latest(), version)
+}
@@ -1,9 +1,14 @@
 package sqlstorage
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/migration"
 	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
 	"github.com/sirupsen/logrus"
@@ -13,90 +18,495 @@ type Storage struct {
 	db *sqlx.DB
 }
 
-func New(dsn string, logger *logrus.Logger) (*Storage, error) {
-	db, err := sqlx.Connect("postgres", dsn)
+// Options — параметры подключения к Postgres. Нулевые значения полей пула
+// означают значения database/sql по умолчанию.
+type Options struct {
+	// ConnectTimeout — сколько повторять попытки подключения при запуске,
+	// например пока Postgres стартует рядом с сервисом; 0 — одна попытка.
+	ConnectTimeout  time.Duration
+	MaxOpenConns    int           // 0 — без ограничения
+	MaxIdleConns    int           // 0 — 2 соединения
+	ConnMaxLifetime time.Duration // 0 — соединения не пересоздаются по возрасту
+	ConnMaxIdleTime time.Duration // 0 — простаивающие не закрываются
+}
+
+const (
+	connectInitialBackoff = 200 * time.Millisecond
+	connectMaxBackoff     = 5 * time.Second
+)
+
+func New(ctx context.Context, dsn string, opts Options, logger *logrus.Logger) (*Storage, error) {
+	db, err := Connect(ctx, dsn, opts, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	logger.Info("Applying database migrations")
-	applied, err := migration.Apply(db)
+	if err := migrate(ctx, db, logger); err != nil {
+		logger.WithError(err).Error("Migration failed")
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Connect открывает пул соединений с параметрами opts и ждёт, пока БД станет доступна.
+func Connect(ctx context.Context, dsn string, opts Options, logger *logrus.Logger) (*sqlx.DB, error) {
+	db, err := sqlx.Open("postgres", dsn)
 	if err != nil {
-		logger.WithError(err).Errorf("Migration '%s' failed", migration.MigrationName)
 		return nil, err
 	}
+	// SetMaxIdleConns(0) отключил бы пул простаивающих соединений, поэтому 0 — «не задано»
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	}
 
-	if applied {
-		logger.Infof("Migration '%s' - applied successfully", migration.MigrationName)
-	} else {
-		logger.Infof("Migration '%s' - already applied", migration.MigrationName)
+	if err := waitForDB(ctx, db, opts.ConnectTimeout, logger); err != nil {
+		_ = db.Close()
+		return nil, err
 	}
+	return db, nil
+}
 
-	return &Storage{db: db}, nil
+type pinger interface {
+	PingContext(ctx context.Context) error
 }
 
-func (s *Storage) Add(event storage.Event) error {
-	query := `
-		INSERT INTO events (id, title, datetime, duration, description, user_id, notify_before)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := s.db.Exec(query,
-		event.ID,
-		event.Title,
-		event.DateTime,
-		event.Duration,
-		event.Description,
-		event.UserID,
-		event.NotifyBefore,
-	)
+// waitForDB проверяет соединение, повторяя попытки с экспоненциальной паузой,
+// пока не истечёт timeout. Ошибки, которые повтор не исправит (например, неверный пароль),
+// возвращаются сразу.
+func waitForDB(ctx context.Context, db pinger, timeout time.Duration, logger *logrus.Logger) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	delay := connectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		deadline, ok := ctx.Deadline()
+		if !retryable(err) || !ok || time.Until(deadline) < delay {
+			return fmt.Errorf("failed to connect to database (%d attempts): %w", attempt, err)
+		}
+
+		logger.WithError(err).Warnf("Database is not available, retrying in %s", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to connect to database (%d attempts): %w", attempt, err)
+		}
+		delay = min(delay*2, connectMaxBackoff)
+	}
+}
+
+// retryable сообщает, может ли повторная попытка подключения пройти успешно.
+// Если ответил сам Postgres, повторять имеет смысл, только пока он запускается.
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "57P03" // cannot_connect_now
+	}
+	return true
+}
+
+// migrate применяет недостающие миграции схемы.
+func migrate(ctx context.Context, db *sqlx.DB, logger *logrus.Logger) error {
+	logger.Info("Applying database migrations")
+	migrator, err := migration.New(db)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		logger.Infof("Migration %d '%s' - applied successfully", m.Version, m.Name)
+	}
 	return err
 }
 
-func (s *Storage) Update(id string, event storage.Event) error {
+// Close закрывает пул соединений с БД.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// Ping проверяет соединение с БД.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CheckSchema проверяет, что в БД применены все миграции, известные бинарнику.
+func (s *Storage) CheckSchema(ctx context.Context) error {
+	migrator, err := migration.New(s.db)
+	if err != nil {
+		return err
+	}
+	return migrator.Check(ctx)
+}
+
+func (s *Storage) Add(ctx context.Context, event storage.Event) error {
+	return s.inUserTx(ctx, event.UserID, func(tx *sqlx.Tx) error {
+		// Занятый ID проверяется до пересечений, как и в остальных реализациях;
+		// при гонке с другим пользователем его отсечёт первичный ключ
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM events WHERE id = $1)", event.ID); err != nil {
+			return err
+		}
+		if exists {
+			return storage.ErrEventExists
+		}
+		if err := checkConflicts(ctx, tx, event, ""); err != nil {
+			return err
+		}
+		query := `
+			INSERT INTO events (id, title, datetime, ends_at, duration, description, user_id, notify_before,
+			                    rrule, exdates, last_occurrence, tz_name, tz_offset)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+		tzName, tzOffset := storage.ZoneOf(event.DateTime)
+		_, err := tx.ExecContext(ctx, query,
+			event.ID,
+			event.Title,
+			event.DateTime,
+			event.End(),
+			event.Duration,
+			event.Description,
+			event.UserID,
+			event.NotifyBefore,
+			event.RRule,
+			exDates(event),
+			lastOccurrence(event),
+			tzName,
+			tzOffset,
+		)
+		return mapError(err)
+	})
+}
+
+func (s *Storage) Update(ctx context.Context, userID, id string, event storage.Event) error {
+	event.UserID = userID
+	return s.inUserTx(ctx, userID, func(tx *sqlx.Tx) error {
+		var exists bool
+		query := "SELECT EXISTS (SELECT 1 FROM events WHERE id = $1 AND user_id = $2)"
+		if err := tx.GetContext(ctx, &exists, query, id, userID); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrEventNotFound
+		}
+		if err := checkConflicts(ctx, tx, event, id); err != nil {
+			return err
+		}
+		query = `
+			UPDATE events
+			SET title = $1, datetime = $2, ends_at = $3, duration = $4, description = $5, notify_before = $6,
+			    rrule = $7, exdates = $8, last_occurrence = $9, tz_name = $10, tz_offset = $11
+			WHERE id = $12 AND user_id = $13`
+		tzName, tzOffset := storage.ZoneOf(event.DateTime)
+		res, err := tx.ExecContext(ctx, query,
+			event.Title,
+			event.DateTime,
+			event.End(),
+			event.Duration,
+			event.Description,
+			event.NotifyBefore,
+			event.RRule,
+			exDates(event),
+			lastOccurrence(event),
+			tzName,
+			tzOffset,
+			id,
+			userID,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+		return checkAffected(res)
+	})
+}
+
+// userLockClass — первый ключ advisory lock, которым сериализуются изменения событий одного пользователя.
+const userLockClass = 1
+
+// inUserTx выполняет fn в транзакции, удерживая блокировку событий пользователя userID:
+// проверка пересечений повторяющихся событий выполняется в Go и без блокировки
+// не защищена от параллельной вставки.
+func (s *Storage) inUserTx(ctx context.Context, userID string, fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", userLockClass, userID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkConflicts проверяет пересечения, в которых участвует повторяющееся событие.
+// Пересечения разовых событий отсекает ограничение events_no_overlap.
+func checkConflicts(ctx context.Context, tx *sqlx.Tx, event storage.Event, skipID string) error {
 	query := `
-		UPDATE events
-		SET title = $1, datetime = $2, duration = $3, description = $4, user_id = $5, notify_before = $6
-		WHERE id = $7`
-	_, err := s.db.Exec(query,
-		event.Title,
-		event.DateTime,
-		event.Duration,
-		event.Description,
-		event.UserID,
-		event.NotifyBefore,
-		id,
-	)
-	return err
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE user_id = $1 AND id <> $2
+		  AND (rrule <> '' OR $3)
+		  AND (last_occurrence IS NULL OR last_occurrence + GREATEST(duration, 1) * INTERVAL '1 second' > $4)`
+	candidates, err := selectEvents(ctx, tx, query, event.UserID, skipID, event.IsRecurring(), event.DateTime)
+	if err != nil {
+		return err
+	}
+	for _, c := range candidates {
+		if c.Conflicts(event) {
+			return storage.ErrDateBusy
+		}
+	}
+	return nil
 }
 
-func (s *Storage) Delete(id string) error {
-	_, err := s.db.Exec("DELETE FROM events WHERE id = $1", id)
+// eventColumns — колонки таблицы events, из которых собирается eventRow.
+const eventColumns = "id, title, datetime, duration, description, user_id, notify_before, rrule, exdates, " +
+	"tz_name, tz_offset"
+
+// eventRow — строка таблицы events. Массив exdates lib/pq отдаёт в текстовом виде,
+// datetime — в поясе сессии, поэтому пояс события восстанавливается из tz_name и tz_offset.
+type eventRow struct {
+	storage.Event
+	ExDates  pq.StringArray `db:"exdates"`
+	TZName   string         `db:"tz_name"`
+	TZOffset int            `db:"tz_offset"`
+}
+
+func selectEvents(ctx context.Context, q sqlx.QueryerContext, query string, args ...any) ([]storage.Event, error) {
+	var rows []eventRow
+	if err := sqlx.SelectContext(ctx, q, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	events := make([]storage.Event, 0, len(rows))
+	for _, row := range rows {
+		e := row.Event
+		e.DateTime = storage.InZone(e.DateTime, row.TZName, row.TZOffset)
+		for _, v := range row.ExDates {
+			t, err := pq.ParseTimestamp(nil, v)
+			if err != nil {
+				return nil, fmt.Errorf("event %s: bad exdate %q: %w", e.ID, v, err)
+			}
+			e.ExDates = append(e.ExDates, t)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// exDates возвращает значение для колонки exdates (NOT NULL, поэтому не nil).
+func exDates(e storage.Event) any {
+	return pq.Array(append(make([]time.Time, 0, len(e.ExDates)), e.ExDates...))
+}
+
+// lastOccurrence возвращает значение для колонки last_occurrence (NULL — правило бесконечное).
+func lastOccurrence(e storage.Event) sql.NullTime {
+	t, ok := e.LastOccurrence()
+	return sql.NullTime{Time: t, Valid: ok}
+}
+
+// Коды ошибок Postgres: нарушение ограничения events_no_overlap (пересечение
+// событий одного пользователя) и первичного ключа.
+const (
+	exclusionViolation = "23P01"
+	uniqueViolation    = "23505"
+)
+
+// mapError переводит ошибки БД в ошибки хранилища.
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case exclusionViolation:
+			return storage.ErrDateBusy
+		case uniqueViolation:
+			return storage.ErrEventExists
+		}
+	}
 	return err
 }
 
-func (s *Storage) ListDay(date time.Time) ([]storage.Event, error) {
-	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	end := start.Add(24 * time.Hour)
-	return s.listBetween(start, end)
+func (s *Storage) Delete(ctx context.Context, userID, id string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM events WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// checkAffected возвращает ErrEventNotFound, если запрос не затронул ни одной строки.
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrEventNotFound
+	}
+	return nil
+}
+
+func (s *Storage) Get(ctx context.Context, userID, id string) (storage.Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE id = $1 AND user_id = $2`
+	events, err := selectEvents(ctx, s.db, query, id, userID)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if len(events) == 0 {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	return events[0], nil
+}
+
+func (s *Storage) ListAll(ctx context.Context, userID string) ([]storage.Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE user_id = $1 ORDER BY datetime, id COLLATE "C"`
+	return selectEvents(ctx, s.db, query, userID)
+}
+
+func (s *Storage) ListDay(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.DayRange(date)
+	return s.listBetween(ctx, userID, start, end, mode)
+}
+
+func (s *Storage) ListWeek(
+	ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.WeekRange(startDate)
+	return s.listBetween(ctx, userID, start, end, mode)
+}
+
+func (s *Storage) ListMonth(
+	ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.MonthRange(startDate)
+	return s.listBetween(ctx, userID, start, end, mode)
 }
 
-func (s *Storage) ListWeek(startDate time.Time) ([]storage.Event, error) {
-	start := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
-	end := start.Add(7 * 24 * time.Hour)
-	return s.listBetween(start, end)
+// listBetween возвращает экземпляры событий пользователя за [start, end) в режиме mode.
+// SQL отбирает события, последний экземпляр которых не закончился к start
+// (подходит для обоих режимов), а экземпляры вычисляются в приложении.
+func (s *Storage) listBetween(
+	ctx context.Context, userID string, start, end time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE user_id = $1 AND datetime < $3
+		  AND (last_occurrence IS NULL OR last_occurrence + GREATEST(duration, 1) * INTERVAL '1 second' > $2)`
+	events, err := selectEvents(ctx, s.db, query, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var result []storage.Event
+	for _, e := range events {
+		result = append(result, e.OccurrencesIn(start, end, mode)...)
+	}
+	return result, nil
+}
+
+// List выбирает разовые события по индексу (user_id, datetime, id COLLATE "C") с продолжением
+// после курсора, а экземпляры повторяющихся серий из периода вычисляет в приложении.
+// ID сравниваются в collation "C", чтобы порядок совпадал с Cursor.Before, а нижняя граница
+// datetime — позднейшая из from и курсора, чтобы поиск по индексу начинался с курсора.
+func (s *Storage) List(
+	ctx context.Context, userID string, from, to time.Time, cursor string, limit int,
+) ([]storage.Event, string, error) {
+	c, err := storage.ValidateList(from, to, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	singleQuery := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE user_id = $1 AND rrule = '' AND datetime < $3
+		  AND datetime >= greatest($2::timestamptz, $4::timestamptz)
+		  AND (datetime, id COLLATE "C") > ($4, $5)
+		ORDER BY datetime, id COLLATE "C"
+		LIMIT $6`
+	single, err := selectEvents(ctx, s.db, singleQuery, userID, from, to, c.DateTime, c.ID, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	seriesQuery := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE user_id = $1 AND rrule <> '' AND datetime < $3
+		  AND (last_occurrence IS NULL OR last_occurrence >= $2)`
+	// Экземпляры до курсора уже выданы: серии, закончившиеся раньше, не нужны
+	after := from
+	if c.DateTime.After(after) {
+		after = c.DateTime
+	}
+	series, err := selectEvents(ctx, s.db, seriesQuery, userID, after, to)
+	if err != nil {
+		return nil, "", err
+	}
+
+	events, next := storage.MergePage(single, series, c, from, to, limit)
+	return events, next, nil
 }
 
-func (s *Storage) ListMonth(startDate time.Time) ([]storage.Event, error) {
-	start := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, startDate.Location())
-	end := start.AddDate(0, 1, 0)
-	return s.listBetween(start, end)
+// Search использует GIN-индекс по колонке search. Слова запроса нормализует та же
+// events_search_text, что и колонку, а plainto_tsquery требует их все.
+func (s *Storage) Search(ctx context.Context, userID, query string, limit int) ([]storage.Event, error) {
+	if err := storage.ValidateSearch(query, limit); err != nil {
+		return nil, err
+	}
+	q := `
+		SELECT ` + eventColumns + `
+		FROM events, plainto_tsquery('simple', events_search_text($2)) AS query
+		WHERE user_id = $1 AND search @@ query
+		ORDER BY ts_rank(search, query) DESC, datetime, id COLLATE "C"
+		LIMIT $3`
+	return selectEvents(ctx, s.db, q, userID, query, limit)
 }
 
-func (s *Storage) listBetween(start, end time.Time) ([]storage.Event, error) {
-	var events []storage.Event
+func (s *Storage) ListToNotify(ctx context.Context, from, to time.Time) ([]storage.Event, error) {
 	query := `
-		SELECT id, title, datetime, duration, description, user_id, notify_before
+		SELECT ` + eventColumns + `
 		FROM events
-		WHERE datetime > $1 AND datetime < $2`
-	err := s.db.Select(&events, query, start, end)
-	return events, err
+		WHERE notify_before > 0
+		  AND datetime - notify_before * INTERVAL '1 second' < $2
+		  AND (last_occurrence IS NULL OR last_occurrence - notify_before * INTERVAL '1 second' >= $1)`
+	events, err := selectEvents(ctx, s.db, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var result []storage.Event
+	for _, e := range events {
+		before := time.Duration(e.NotifyBefore) * time.Second
+		result = append(result, e.Occurrences(from.Add(before), to.Add(before))...)
+	}
+	return result, nil
+}
+
+func (s *Storage) DeleteBefore(ctx context.Context, t time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM events WHERE last_occurrence < $1", t)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
 }
@@ -0,0 +1,124 @@
+package sqlstorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/storagetest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// dsnEnv — переменная окружения с DSN тестовой базы. Если она не задана, тесты
+// запускают временный Postgres (бинарники скачиваются при первом запуске).
+// Таблица events в тестовой базе очищается.
+const dsnEnv = "CALENDAR_TEST_POSTGRES_DSN"
+
+// skipEnv — если переменная задана, тесты без доступной базы пропускаются, а не падают.
+const skipEnv = "CALENDAR_TEST_POSTGRES_SKIP"
+
+var (
+	testDSN string
+	// testDBErr — почему тестовая база недоступна
+	testDBErr error
+)
+
+func TestMain(m *testing.M) {
+	testDSN = os.Getenv(dsnEnv)
+	if testDSN != "" {
+		os.Exit(m.Run())
+	}
+
+	stop, dsn, err := startPostgres()
+	if err != nil {
+		testDBErr = fmt.Errorf("embedded postgres: %w (set %s to use an existing database or %s=1 to skip)",
+			err, dsnEnv, skipEnv)
+		os.Exit(m.Run())
+	}
+	testDSN = dsn
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// startPostgres запускает временный Postgres на свободном порту и возвращает функцию его остановки.
+func startPostgres() (stop func(), dsn string, err error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	dir, err := os.MkdirTemp("", "calendar-postgres")
+	if err != nil {
+		return nil, "", err
+	}
+	cfg := embeddedpostgres.DefaultConfig().
+		Port(uint32(port)).
+		RuntimePath(dir).
+		Logger(io.Discard)
+	db := embeddedpostgres.NewDatabase(cfg)
+	if err := db.Start(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, "", err
+	}
+	stop = func() {
+		_ = db.Stop()
+		_ = os.RemoveAll(dir)
+	}
+	return stop, cfg.GetConnectionURL() + "?sslmode=disable", nil
+}
+
+// newTestStorage возвращает хранилище с пустой таблицей events.
+func newTestStorage(t *testing.T) storage.Storage {
+	t.Helper()
+	requireDB(t)
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	s, err := New(context.Background(), testDSN, Options{}, log)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	_, err = s.db.Exec("TRUNCATE events")
+	require.NoError(t, err)
+	return s
+}
+
+// requireDB завершает тест, если тестовая база недоступна: падением, а с skipEnv — пропуском,
+// чтобы тесты Postgres не пропускались незаметно.
+func requireDB(t *testing.T) {
+	t.Helper()
+	if testDBErr == nil {
+		return
+	}
+	if os.Getenv(skipEnv) != "" {
+		t.Skip(testDBErr)
+	}
+	t.Fatal(testDBErr)
+}
+
+func TestSQLStorage_Conformance(t *testing.T) {
+	requireDB(t)
+	storagetest.Run(t, newTestStorage)
+}
+
+func TestSQLStorage_Checks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t).(*Storage)
+	require.NoError(t, s.Ping(ctx))
+	require.NoError(t, s.CheckSchema(ctx))
+
+	// Версия, о которой бинарник не знает, не делает его неготовым
+	_, err := s.db.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (999, 'future', '')
+		ON CONFLICT DO NOTHING`)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = s.db.Exec(`DELETE FROM schema_migrations WHERE version = 999`) })
+	require.NoError(t, s.CheckSchema(ctx))
+}
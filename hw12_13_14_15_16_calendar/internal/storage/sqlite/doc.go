@@ -0,0 +1,4 @@
+// Package sqlitestorage — хранилище событий в файле SQLite для установок из одного узла.
+// Драйвер mattn/go-sqlite3 требует сборки с CGO_ENABLED=1; без cgo пакет собирается,
+// но New и Open возвращают ErrCGORequired.
+package sqlitestorage
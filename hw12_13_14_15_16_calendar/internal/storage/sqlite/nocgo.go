@@ -0,0 +1,36 @@
+//go:build !cgo
+
+package sqlitestorage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCGORequired возвращается в сборке без cgo, например в Docker-образе.
+var ErrCGORequired = errors.New("sqlite storage requires a build with CGO_ENABLED=1")
+
+// Storage в сборке без cgo не создаётся: New всегда возвращает ErrCGORequired.
+type Storage struct {
+	storage.Storage
+}
+
+func Open(context.Context, string) (*sqlx.DB, error) {
+	return nil, ErrCGORequired
+}
+
+func New(context.Context, string, *logrus.Logger) (*Storage, error) {
+	return nil, ErrCGORequired
+}
+
+func (s *Storage) Ping(context.Context) error {
+	return ErrCGORequired
+}
+
+func (s *Storage) CheckSchema(context.Context) error {
+	return ErrCGORequired
+}
@@ -0,0 +1,24 @@
+//go:build !cgo
+
+package sqlitestorage
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_WithoutCGO(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	path := filepath.Join(t.TempDir(), "calendar.db")
+
+	_, err := New(context.Background(), path, log)
+	assert.ErrorIs(t, err, ErrCGORequired)
+	_, err = Open(context.Background(), path)
+	assert.ErrorIs(t, err, ErrCGORequired)
+}
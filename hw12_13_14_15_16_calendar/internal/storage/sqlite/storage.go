@@ -0,0 +1,465 @@
+//go:build cgo
+
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/migration"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type Storage struct {
+	db *sqlx.DB
+}
+
+// Open открывает файл БД path. Журнал WAL позволяет читать во время записи,
+// а транзакции начинаются с BEGIN IMMEDIATE: проверка пересечений и запись
+// выполняются без гонок между процессами, использующими один файл.
+func Open(ctx context.Context, path string) (*sqlx.DB, error) {
+	params := url.Values{}
+	params.Set("_busy_timeout", "5000")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_txlock", "immediate")
+	params.Set("_foreign_keys", "on")
+	return sqlx.ConnectContext(ctx, "sqlite3", "file:"+path+"?"+params.Encode())
+}
+
+func New(ctx context.Context, path string, logger *logrus.Logger) (*Storage, error) {
+	db, err := Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(ctx, db, logger); err != nil {
+		logger.WithError(err).Error("Migration failed")
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// migrate применяет недостающие миграции схемы.
+func migrate(ctx context.Context, db *sqlx.DB, logger *logrus.Logger) error {
+	logger.Info("Applying database migrations")
+	migrator, err := migration.NewSQLite(db)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		logger.Infof("Migration %d '%s' - applied successfully", m.Version, m.Name)
+	}
+	return err
+}
+
+// Close закрывает соединения с БД.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// Ping проверяет соединение с БД.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CheckSchema проверяет, что в БД применены все миграции, известные бинарнику.
+func (s *Storage) CheckSchema(ctx context.Context) error {
+	migrator, err := migration.NewSQLite(s.db)
+	if err != nil {
+		return err
+	}
+	return migrator.Check(ctx)
+}
+
+func (s *Storage) Add(ctx context.Context, event storage.Event) error {
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM events WHERE id = ?1)", event.ID); err != nil {
+			return err
+		}
+		if exists {
+			return storage.ErrEventExists
+		}
+		if err := checkConflicts(ctx, tx, event, ""); err != nil {
+			return err
+		}
+		r, err := toRow(event)
+		if err != nil {
+			return err
+		}
+		query := `
+			INSERT INTO events (id, title, datetime, ends_at, duration, description, user_id, notify_before,
+			                    rrule, exdates, last_occurrence, tz_name, tz_offset)
+			VALUES (:id, :title, :datetime, :ends_at, :duration, :description, :user_id, :notify_before,
+			        :rrule, :exdates, :last_occurrence, :tz_name, :tz_offset)`
+		_, err = tx.NamedExecContext(ctx, query, r)
+		return mapError(err)
+	})
+}
+
+func (s *Storage) Update(ctx context.Context, userID, id string, event storage.Event) error {
+	event.ID, event.UserID = id, userID
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var exists bool
+		query := "SELECT EXISTS (SELECT 1 FROM events WHERE id = ?1 AND user_id = ?2)"
+		if err := tx.GetContext(ctx, &exists, query, id, userID); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrEventNotFound
+		}
+		if err := checkConflicts(ctx, tx, event, id); err != nil {
+			return err
+		}
+		r, err := toRow(event)
+		if err != nil {
+			return err
+		}
+		query = `
+			UPDATE events
+			SET title = :title, datetime = :datetime, ends_at = :ends_at, duration = :duration,
+			    description = :description, notify_before = :notify_before,
+			    rrule = :rrule, exdates = :exdates, last_occurrence = :last_occurrence,
+			    tz_name = :tz_name, tz_offset = :tz_offset
+			WHERE id = :id AND user_id = :user_id`
+		_, err = tx.NamedExecContext(ctx, query, r)
+		return err
+	})
+}
+
+// inTx выполняет fn в транзакции. Благодаря _txlock=immediate транзакция сразу
+// получает блокировку записи, поэтому проверки пересечений не устаревают до вставки.
+func (s *Storage) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkConflicts проверяет пересечения события с остальными событиями пользователя.
+// Разовые события-кандидаты отбираются по времени, повторяющиеся проверяются в Go.
+func checkConflicts(ctx context.Context, tx *sqlx.Tx, event storage.Event, skipID string) error {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE user_id = ?1 AND id <> ?2
+		  AND (rrule <> '' OR ?3 OR (datetime < ?5 AND ends_at > ?4))
+		  AND (last_occurrence IS NULL OR last_occurrence + max(duration, 1) * 1000000000 > ?4)`
+	candidates, err := selectEvents(ctx, tx, query,
+		event.UserID, skipID, event.IsRecurring(), event.DateTime.UnixNano(), event.End().UnixNano())
+	if err != nil {
+		return err
+	}
+	for _, c := range candidates {
+		if c.Conflicts(event) {
+			return storage.ErrDateBusy
+		}
+	}
+	return nil
+}
+
+// eventColumns — колонки таблицы events, из которых собирается row.
+const eventColumns = "id, title, datetime, duration, description, user_id, notify_before, rrule, exdates, " +
+	"tz_name, tz_offset"
+
+// row — строка таблицы events: время в наносекундах Unix, exdates — JSON-массив,
+// часовой пояс начала (в нём разворачиваются повторения) — tz_name и tz_offset.
+type row struct {
+	ID             string        `db:"id"`
+	Title          string        `db:"title"`
+	DateTime       int64         `db:"datetime"`
+	EndsAt         int64         `db:"ends_at"`
+	Duration       int64         `db:"duration"`
+	Description    string        `db:"description"`
+	UserID         string        `db:"user_id"`
+	NotifyBefore   int64         `db:"notify_before"`
+	RRule          string        `db:"rrule"`
+	ExDates        string        `db:"exdates"`
+	LastOccurrence sql.NullInt64 `db:"last_occurrence"`
+	TZName         string        `db:"tz_name"`
+	TZOffset       int           `db:"tz_offset"`
+}
+
+func toRow(e storage.Event) (row, error) {
+	exDates := make([]int64, 0, len(e.ExDates))
+	for _, t := range e.ExDates {
+		exDates = append(exDates, t.UnixNano())
+	}
+	data, err := json.Marshal(exDates)
+	if err != nil {
+		return row{}, err
+	}
+	r := row{
+		ID:           e.ID,
+		Title:        e.Title,
+		DateTime:     e.DateTime.UnixNano(),
+		EndsAt:       e.End().UnixNano(),
+		Duration:     e.Duration,
+		Description:  e.Description,
+		UserID:       e.UserID,
+		NotifyBefore: e.NotifyBefore,
+		RRule:        e.RRule,
+		ExDates:      string(data),
+	}
+	r.TZName, r.TZOffset = storage.ZoneOf(e.DateTime)
+	if last, ok := e.LastOccurrence(); ok {
+		r.LastOccurrence = sql.NullInt64{Int64: last.UnixNano(), Valid: true}
+	}
+	return r, nil
+}
+
+func (r row) event() (storage.Event, error) {
+	e := storage.Event{
+		ID:           r.ID,
+		Title:        r.Title,
+		DateTime:     storage.InZone(time.Unix(0, r.DateTime), r.TZName, r.TZOffset),
+		Duration:     r.Duration,
+		Description:  r.Description,
+		UserID:       r.UserID,
+		NotifyBefore: r.NotifyBefore,
+		RRule:        r.RRule,
+	}
+	var exDates []int64
+	if err := json.Unmarshal([]byte(r.ExDates), &exDates); err != nil {
+		return storage.Event{}, fmt.Errorf("event %s: bad exdates %q: %w", r.ID, r.ExDates, err)
+	}
+	for _, n := range exDates {
+		e.ExDates = append(e.ExDates, time.Unix(0, n).UTC())
+	}
+	return e, nil
+}
+
+func selectEvents(ctx context.Context, q sqlx.QueryerContext, query string, args ...any) ([]storage.Event, error) {
+	var rows []row
+	if err := sqlx.SelectContext(ctx, q, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	events := make([]storage.Event, 0, len(rows))
+	for _, r := range rows {
+		e, err := r.event()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// mapError переводит ошибки БД в ошибки хранилища: нарушение первичного ключа
+// возможно, если событие с тем же ID вставили в обход проверки.
+func mapError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		return storage.ErrEventExists
+	}
+	return err
+}
+
+func (s *Storage) Delete(ctx context.Context, userID, id string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM events WHERE id = ?1 AND user_id = ?2", id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrEventNotFound
+	}
+	return nil
+}
+
+func (s *Storage) Get(ctx context.Context, userID, id string) (storage.Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE id = ?1 AND user_id = ?2`
+	events, err := selectEvents(ctx, s.db, query, id, userID)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if len(events) == 0 {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	return events[0], nil
+}
+
+func (s *Storage) ListAll(ctx context.Context, userID string) ([]storage.Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE user_id = ?1 ORDER BY datetime, id`
+	return selectEvents(ctx, s.db, query, userID)
+}
+
+func (s *Storage) ListDay(
+	ctx context.Context, userID string, date time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.DayRange(date)
+	return s.listBetween(ctx, userID, start, end, mode)
+}
+
+func (s *Storage) ListWeek(
+	ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.WeekRange(startDate)
+	return s.listBetween(ctx, userID, start, end, mode)
+}
+
+func (s *Storage) ListMonth(
+	ctx context.Context, userID string, startDate time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	start, end := storage.MonthRange(startDate)
+	return s.listBetween(ctx, userID, start, end, mode)
+}
+
+// listBetween возвращает экземпляры событий пользователя за [start, end) в режиме mode.
+// SQL отбирает события, последний экземпляр которых не закончился к start,
+// а экземпляры вычисляются в приложении.
+func (s *Storage) listBetween(
+	ctx context.Context, userID string, start, end time.Time, mode storage.RangeMode,
+) ([]storage.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE user_id = ?1 AND datetime < ?3
+		  AND (last_occurrence IS NULL OR last_occurrence + max(duration, 1) * 1000000000 > ?2)`
+	events, err := selectEvents(ctx, s.db, query, userID, start.UnixNano(), end.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	var result []storage.Event
+	for _, e := range events {
+		result = append(result, e.OccurrencesIn(start, end, mode)...)
+	}
+	return result, nil
+}
+
+// List выбирает разовые события по индексу (user_id, datetime, id) с продолжением
+// после курсора, а экземпляры повторяющихся серий из периода вычисляет в приложении.
+// Строки в SQLite сравниваются побайтно, как в Cursor.Before.
+func (s *Storage) List(
+	ctx context.Context, userID string, from, to time.Time, cursor string, limit int,
+) ([]storage.Event, string, error) {
+	c, err := storage.ValidateList(from, to, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	singleQuery := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE user_id = ?1 AND rrule = '' AND datetime >= max(?2, ?4) AND datetime < ?3
+		  AND (datetime, id) > (?4, ?5)
+		ORDER BY datetime, id
+		LIMIT ?6`
+	single, err := selectEvents(ctx, s.db, singleQuery,
+		userID, from.UnixNano(), to.UnixNano(), c.DateTime.UnixNano(), c.ID, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	seriesQuery := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE user_id = ?1 AND rrule <> '' AND datetime < ?3
+		  AND (last_occurrence IS NULL OR last_occurrence >= ?2)`
+	// Экземпляры до курсора уже выданы: серии, закончившиеся раньше, не нужны
+	after := from
+	if c.DateTime.After(after) {
+		after = c.DateTime
+	}
+	series, err := selectEvents(ctx, s.db, seriesQuery, userID, after.UnixNano(), to.UnixNano())
+	if err != nil {
+		return nil, "", err
+	}
+
+	events, next := storage.MergePage(single, series, c, from, to, limit)
+	return events, next, nil
+}
+
+// Search ищет по словам так же, как хранилище в памяти: событие должно содержать
+// все слова запроса. Индекса нет, события пользователя просматриваются целиком,
+// что приемлемо для небольших установок.
+func (s *Storage) Search(ctx context.Context, userID, query string, limit int) ([]storage.Event, error) {
+	if err := storage.ValidateSearch(query, limit); err != nil {
+		return nil, err
+	}
+	words := storage.SearchWords(query)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	events, err := selectEvents(ctx, s.db, `SELECT `+eventColumns+` FROM events WHERE user_id = ?1`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float64)
+	var result []storage.Event
+	for _, e := range events {
+		terms := storage.SearchTerms(e)
+		score := 0.0
+		for _, w := range words {
+			weight, ok := terms[w]
+			if !ok {
+				score = 0
+				break
+			}
+			score += weight
+		}
+		if score > 0 {
+			scores[e.ID] = score
+			result = append(result, e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if si, sj := scores[result[i].ID], scores[result[j].ID]; si != sj {
+			return si > sj
+		}
+		return storage.CursorOf(result[i]).Before(storage.CursorOf(result[j]))
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *Storage) ListToNotify(ctx context.Context, from, to time.Time) ([]storage.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE notify_before > 0
+		  AND datetime - notify_before * 1000000000 < ?2
+		  AND (last_occurrence IS NULL OR last_occurrence - notify_before * 1000000000 >= ?1)`
+	events, err := selectEvents(ctx, s.db, query, from.UnixNano(), to.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	var result []storage.Event
+	for _, e := range events {
+		before := time.Duration(e.NotifyBefore) * time.Second
+		result = append(result, e.Occurrences(from.Add(before), to.Add(before))...)
+	}
+	return result, nil
+}
+
+func (s *Storage) DeleteBefore(ctx context.Context, t time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM events WHERE last_occurrence < ?1", t.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,80 @@
+//go:build cgo
+
+package sqlitestorage
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/migration"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage/storagetest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T, path string) *Storage {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	s, err := New(context.Background(), path, log)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestSQLiteStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newTestStorage(t, filepath.Join(t.TempDir(), "calendar.db"))
+	})
+}
+
+func TestSQLiteStorage_Persistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "calendar.db")
+	event := storage.Event{
+		ID:       "1",
+		Title:    "Standup",
+		DateTime: time.Date(2024, time.May, 10, 10, 0, 0, 123, time.UTC),
+		Duration: 900,
+		UserID:   "user1",
+		RRule:    "FREQ=DAILY;COUNT=3",
+		ExDates:  []time.Time{time.Date(2024, time.May, 11, 10, 0, 0, 123, time.UTC)},
+	}
+
+	s := newTestStorage(t, path)
+	require.NoError(t, s.Add(ctx, event))
+	require.NoError(t, s.Close())
+
+	// Повторное открытие не применяет миграции заново и видит сохранённые события
+	s = newTestStorage(t, path)
+	got, err := s.Get(ctx, "user1", "1")
+	require.NoError(t, err)
+	assert.Equal(t, event, got)
+	assert.ErrorIs(t, s.Add(ctx, event), storage.ErrEventExists)
+}
+
+func TestSQLiteStorage_Checks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, filepath.Join(t.TempDir(), "calendar.db"))
+	require.NoError(t, s.Ping(ctx))
+	require.NoError(t, s.CheckSchema(ctx))
+
+	// БД обновил более новый бинарник: старый остаётся готовым до замены
+	_, err := s.db.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (999, 'future', '')`)
+	require.NoError(t, err)
+	require.NoError(t, s.CheckSchema(ctx))
+
+	// Одна из известных миграций не применена
+	_, err = s.db.Exec(`DELETE FROM schema_migrations WHERE version = 1`)
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.CheckSchema(ctx), migration.ErrVersionMismatch)
+
+	require.NoError(t, s.Close())
+	assert.Error(t, s.Ping(ctx))
+}
@@ -0,0 +1,219 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCRUD(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	e := event("1", at(1, 10, 0), 3600)
+	e.Description = "Обсуждение задач"
+	e.NotifyBefore = 900
+	add(t, s, e)
+
+	got, err := s.Get(ctx, "user1", "1")
+	require.NoError(t, err)
+	assert.Equal(t, e, normalize(got))
+
+	// ID и владелец берутся из аргументов Update, а не из события
+	updated := e
+	updated.ID, updated.UserID = "", ""
+	updated.Title = "Ретро"
+	updated.DateTime = at(2, 12, 0)
+	updated.Duration = 1800
+	require.NoError(t, s.Update(ctx, "user1", "1", updated))
+
+	got, err = s.Get(ctx, "user1", "1")
+	require.NoError(t, err)
+	updated.ID, updated.UserID = "1", "user1"
+	assert.Equal(t, updated, normalize(got))
+
+	require.NoError(t, s.Delete(ctx, "user1", "1"))
+	_, err = s.Get(ctx, "user1", "1")
+	assert.ErrorIs(t, err, storage.ErrEventNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, "user1", "1"), storage.ErrEventNotFound)
+
+	// После удаления время свободно
+	add(t, s, event("2", at(1, 10, 0), 3600))
+}
+
+func testErrors(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	add(t, s, event("a", at(1, 10, 0), 3600), event("b", at(1, 12, 0), 3600))
+
+	t.Run("duplicate id", func(t *testing.T) {
+		assert.ErrorIs(t, s.Add(ctx, event("a", at(5, 10, 0), 60)), storage.ErrEventExists)
+		// Совпадение ID важнее пересечения по времени
+		assert.ErrorIs(t, s.Add(ctx, event("a", at(1, 10, 0), 3600)), storage.ErrEventExists)
+		other := event("a", at(5, 10, 0), 60)
+		other.UserID = "user2"
+		assert.ErrorIs(t, s.Add(ctx, other), storage.ErrEventExists)
+	})
+
+	t.Run("busy on add", func(t *testing.T) {
+		assert.ErrorIs(t, s.Add(ctx, event("c", at(1, 10, 30), 60)), storage.ErrDateBusy)
+		assert.ErrorIs(t, s.Add(ctx, event("c", at(1, 9, 30), 3600)), storage.ErrDateBusy)
+		// Событие нулевой длительности занимает секунду
+		assert.ErrorIs(t, s.Add(ctx, event("c", at(1, 10, 0), 0)), storage.ErrDateBusy)
+	})
+
+	t.Run("adjacent events", func(t *testing.T) {
+		add(t, s, event("before", at(1, 9, 0), 3600), event("between", at(1, 11, 0), 3600))
+	})
+
+	t.Run("busy on update", func(t *testing.T) {
+		moved := event("b", at(1, 10, 15), 600)
+		assert.ErrorIs(t, s.Update(ctx, "user1", "b", moved), storage.ErrDateBusy)
+		got, err := s.Get(ctx, "user1", "b")
+		require.NoError(t, err)
+		assert.True(t, at(1, 12, 0).Equal(got.DateTime), "event must not change")
+
+		// Событие не пересекается само с собой
+		moved = event("b", at(1, 12, 30), 3600)
+		assert.NoError(t, s.Update(ctx, "user1", "b", moved))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		assert.ErrorIs(t, s.Update(ctx, "user1", "missing", event("missing", at(7, 10, 0), 60)),
+			storage.ErrEventNotFound)
+		// Отсутствие события важнее пересечения по времени
+		assert.ErrorIs(t, s.Update(ctx, "user1", "missing", event("missing", at(1, 10, 0), 60)),
+			storage.ErrEventNotFound)
+		assert.ErrorIs(t, s.Delete(ctx, "user1", "missing"), storage.ErrEventNotFound)
+		_, err := s.Get(ctx, "user1", "missing")
+		assert.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+}
+
+func testOwnership(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+	add(t, s, event("a", at(1, 10, 0), 3600))
+
+	// У другого пользователя то же время свободно
+	other := event("b", at(1, 10, 0), 3600)
+	other.UserID = "user2"
+	add(t, s, other)
+
+	_, err := s.Get(ctx, "user2", "a")
+	assert.ErrorIs(t, err, storage.ErrEventNotFound)
+	assert.ErrorIs(t, s.Update(ctx, "user2", "a", event("a", at(3, 10, 0), 60)), storage.ErrEventNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, "user2", "a"), storage.ErrEventNotFound)
+
+	got, err := s.Get(ctx, "user1", "a")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", got.UserID)
+	assert.True(t, at(1, 10, 0).Equal(got.DateTime))
+
+	events, err := s.ListAll(ctx, "user2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(events))
+}
+
+func testRecurring(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	daily := event("daily", at(1, 10, 0), 3600)
+	daily.RRule = "FREQ=DAILY;COUNT=5"
+	add(t, s, daily)
+
+	t.Run("conflicts with occurrence", func(t *testing.T) {
+		assert.ErrorIs(t, s.Add(ctx, event("x", at(3, 10, 30), 60)), storage.ErrDateBusy)
+		assert.ErrorIs(t, s.Add(ctx, event("x", at(5, 9, 30), 3600)), storage.ErrDateBusy)
+		// После последнего экземпляра время свободно
+		add(t, s, event("after", at(6, 10, 0), 3600))
+	})
+
+	t.Run("series conflicts with single", func(t *testing.T) {
+		weekly := event("weekly", at(1, 8, 0), 3600)
+		weekly.RRule = "FREQ=WEEKLY"
+		add(t, s, event("busy", at(15, 8, 30), 600))
+		assert.ErrorIs(t, s.Add(ctx, weekly), storage.ErrDateBusy)
+
+		single := event("single", at(2, 18, 0), 600)
+		add(t, s, single)
+		single.RRule = "FREQ=DAILY;COUNT=3"
+		single.DateTime = at(2, 10, 15)
+		assert.ErrorIs(t, s.Update(ctx, "user1", "single", single), storage.ErrDateBusy)
+	})
+
+	t.Run("exdates", func(t *testing.T) {
+		daily.ExDates = []time.Time{at(3, 10, 0)}
+		require.NoError(t, s.Update(ctx, "user1", "daily", daily))
+		add(t, s, event("x", at(3, 10, 30), 60))
+
+		got, err := s.Get(ctx, "user1", "daily")
+		require.NoError(t, err)
+		assert.Equal(t, daily, normalize(got))
+	})
+}
+
+// testTimeZone проверяет, что хранилище возвращает событие в его часовом поясе:
+// в нём разворачиваются BYDAY и время серии после перехода на летнее время.
+func testTimeZone(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	// Так время приходит из JSON API: смещение без имени пояса. По UTC это воскресенье
+	monday, err := time.Parse(time.RFC3339, "2024-01-01T01:00:00+03:00")
+	require.NoError(t, err)
+	fixed := event("fixed", monday, 3600)
+	fixed.RRule = "FREQ=WEEKLY;BYDAY=MO;COUNT=4"
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	dst := event("dst", time.Date(2024, time.March, 25, 10, 0, 0, 0, berlin), 3600)
+	dst.RRule = "FREQ=WEEKLY;COUNT=3"
+	add(t, s, fixed, dst)
+
+	for _, want := range []storage.Event{fixed, dst} {
+		got, err := s.Get(ctx, "user1", want.ID)
+		require.NoError(t, err)
+		assert.Equal(t, want.DateTime.Format(time.RFC3339), got.DateTime.Format(time.RFC3339), want.ID)
+		assert.Equal(t, want.DateTime.Location().String(), got.DateTime.Location().String(), want.ID)
+	}
+
+	events, err := s.ListMonth(ctx, "user1", monday, storage.StartsInRange)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	for _, e := range events {
+		assert.Equal(t, "01:00:00+03:00 Monday", e.DateTime.Format("15:04:05Z07:00 Monday"))
+	}
+
+	// Последний экземпляр — после перехода на летнее время, в то же местное время
+	events, err = s.ListDay(ctx, "user1", time.Date(2024, time.April, 8, 0, 0, 0, 0, berlin), storage.StartsInRange)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "2024-04-08T10:00:00+02:00", events[0].DateTime.Format(time.RFC3339))
+}
+
+func testListAll(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	daily := event("daily", at(2, 10, 0), 3600)
+	daily.RRule = "FREQ=DAILY;COUNT=5"
+	foreign := event("foreign", at(1, 10, 0), 3600)
+	foreign.UserID = "user2"
+	add(t, s, event("late", at(9, 10, 0), 60), daily, event("b", at(1, 8, 0), 60), event("a", at(1, 8, 1), 60),
+		foreign)
+
+	events, err := s.ListAll(ctx, "user1")
+	require.NoError(t, err)
+	// Серия не разворачивается; порядок — по началу
+	assert.Equal(t, []string{"b", "a", "daily", "late"}, ids(events))
+	assert.Equal(t, daily, normalize(events[2]))
+
+	events, err = s.ListAll(ctx, "nobody")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,188 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testList(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	daily := event("daily", at(2, 12, 0), 3600)
+	daily.RRule = "FREQ=DAILY;COUNT=3"
+	foreign := event("foreign", at(2, 9, 0), 60)
+	foreign.UserID = "user2"
+	add(t, s,
+		event("before", at(0, 12, 0), 60),
+		event("a", at(1, 0, 0), 60),
+		event("b", at(2, 9, 0), 60),
+		daily,
+		event("c", at(3, 18, 0), 60),
+		event("end", at(5, 0, 0), 60),
+		foreign,
+	)
+	want := []time.Time{at(1, 0, 0), at(2, 9, 0), at(2, 12, 0), at(3, 12, 0), at(3, 18, 0), at(4, 12, 0)}
+
+	var (
+		got    []time.Time
+		cursor string
+	)
+	// Страницы по 4 экземпляра вперемешку из разовых событий и серии
+	for range len(want) {
+		page, next, err := s.List(ctx, "user1", at(1, 0, 0), at(5, 0, 0), cursor, 4)
+		require.NoError(t, err)
+		for _, e := range page {
+			got = append(got, e.DateTime.UTC())
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, want, got)
+
+	// Ровно limit экземпляров — следующей страницы нет
+	page, next, err := s.List(ctx, "user1", at(1, 0, 0), at(5, 0, 0), "", len(want))
+	require.NoError(t, err)
+	assert.Len(t, page, len(want))
+	assert.Empty(t, next)
+
+	_, _, err = s.List(ctx, "user1", at(1, 0, 0), at(5, 0, 0), "%", 10)
+	assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+	_, _, err = s.List(ctx, "user1", at(1, 0, 0), at(5, 0, 0), "", 0)
+	assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+	_, _, err = s.List(ctx, "user1", at(5, 0, 0), at(1, 0, 0), "", 10)
+	assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+}
+
+func testSearch(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	release := event("release", at(1, 10, 0), 3600)
+	release.Title = "Релиз версии"
+	prepare := event("prepare", at(2, 10, 0), 3600)
+	prepare.Title = "Созвон"
+	prepare.Description = "Подготовить релиз"
+	foreign := event("foreign", at(1, 10, 0), 3600)
+	foreign.Title = "Релиз"
+	foreign.UserID = "user2"
+	add(t, s, prepare, release, event("other", at(3, 10, 0), 60), foreign)
+
+	events, err := s.Search(ctx, "user1", "релиз", 10)
+	require.NoError(t, err)
+	// Совпадение в названии весит больше, чем в описании
+	assert.Equal(t, []string{"release", "prepare"}, ids(events))
+
+	events, err = s.Search(ctx, "user1", "релиз версии", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"release"}, ids(events))
+
+	events, err = s.Search(ctx, "user1", "релиз", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"release"}, ids(events))
+
+	events, err = s.Search(ctx, "user1", "отпуск", 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// Хранилища одинаково нормализуют словоформы и не поддерживают синтаксис запросов
+	meeting := event("meeting", at(4, 10, 0), 3600)
+	meeting.Title = "Встреча с командой"
+	meeting.Description = "Ёлка в офисе, RELEASE-notes"
+	add(t, s, meeting)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"встречи", []string{"meeting"}},
+		{"ВСТРЕЧАМИ команды", []string{"meeting"}},
+		{"ЕЛКА", []string{"meeting"}},
+		{"release notes", []string{"meeting"}},
+		{"версия", []string{"release"}},
+		{"релиз OR созвон", []string{}},
+		{`"релиз версии"`, []string{"release"}},
+		{"релиз -версии", []string{"release"}},
+	}
+	for _, tt := range tests {
+		events, err := s.Search(ctx, "user1", tt.query, 10)
+		require.NoError(t, err, tt.query)
+		assert.Equal(t, tt.want, ids(events), tt.query)
+	}
+
+	_, err = s.Search(ctx, "user1", " ", 10)
+	assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+	_, err = s.Search(ctx, "user1", "релиз", 0)
+	assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+}
+
+func testListToNotify(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	// Уведомление о meeting — в 09:00
+	meeting := event("meeting", at(1, 10, 0), 3600)
+	meeting.NotifyBefore = 3600
+	daily := event("daily", at(1, 12, 0), 600)
+	daily.NotifyBefore = 600
+	daily.RRule = "FREQ=DAILY;COUNT=3"
+	foreign := event("foreign", at(1, 9, 30), 60)
+	foreign.UserID = "user2"
+	foreign.NotifyBefore = 1800
+	add(t, s, meeting, daily, foreign, event("silent", at(1, 9, 0), 60))
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []string
+	}{
+		{"window start included", at(1, 9, 0), at(1, 9, 1), []string{"meeting", "foreign"}},
+		{"window end excluded", at(1, 8, 0), at(1, 9, 0), nil},
+		{"recurring occurrence", at(2, 11, 45), at(2, 11, 55), []string{"daily"}},
+		{"after series", at(4, 11, 0), at(4, 12, 0), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := s.ListToNotify(ctx, tt.from, tt.to)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, ids(events))
+		})
+	}
+
+	events, err := s.ListToNotify(ctx, at(2, 11, 45), at(2, 11, 55))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.True(t, at(2, 12, 0).Equal(events[0].DateTime), "occurrence start expected")
+}
+
+func testDeleteBefore(t *testing.T, newStorage NewStorage) {
+	ctx := context.Background()
+	s := newStorage(t)
+
+	finished := event("finished", at(0, 20, 0), 3600)
+	finished.RRule = "FREQ=DAILY;COUNT=2"
+	endless := event("endless", at(-10, 7, 0), 600)
+	endless.RRule = "FREQ=DAILY"
+	foreign := event("foreign", at(-5, 10, 0), 60)
+	foreign.UserID = "user2"
+	add(t, s, event("old", at(-1, 10, 0), 60), finished, endless, event("boundary", at(2, 0, 0), 60),
+		event("future", at(10, 10, 0), 60), foreign)
+
+	// Удаляются события, последний экземпляр которых начался раньше t, у всех пользователей
+	n, err := s.DeleteBefore(ctx, at(2, 0, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	events, err := s.ListAll(ctx, "user1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"endless", "boundary", "future"}, ids(events))
+	events, err = s.ListAll(ctx, "user2")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,148 @@
+package storagetest
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type listFunc func(storage.Storage, context.Context, string, time.Time, storage.RangeMode) ([]storage.Event, error)
+
+var (
+	listDay   listFunc = storage.Storage.ListDay
+	listWeek  listFunc = storage.Storage.ListWeek
+	listMonth listFunc = storage.Storage.ListMonth
+)
+
+// testListRanges проверяет границы ListDay/ListWeek/ListMonth: период — полуинтервал [start, end),
+// а в режиме OverlapsRange в него попадают и экземпляры, начавшиеся раньше и ещё идущие.
+func testListRanges(t *testing.T, newStorage NewStorage) {
+	daily := event("daily", at(0, 23, 0), 7200) // 29 февраля 23:00
+	daily.RRule = "FREQ=DAILY;COUNT=5"
+	foreign := event("foreign", at(1, 10, 0), 3600)
+	foreign.UserID = "user2"
+
+	tests := []struct {
+		name   string
+		events []storage.Event
+		list   listFunc
+		date   time.Time
+		mode   storage.RangeMode
+		want   []time.Time
+	}{
+		{
+			name:   "day includes event at midnight",
+			events: []storage.Event{event("a", at(1, 0, 0), 1800)},
+			list:   listDay,
+			date:   at(1, 12, 0),
+			want:   []time.Time{at(1, 0, 0)},
+		},
+		{
+			name:   "day excludes event at next midnight",
+			events: []storage.Event{event("a", at(2, 0, 0), 1800)},
+			list:   listDay,
+			date:   at(1, 0, 0),
+			mode:   storage.OverlapsRange,
+		},
+		{
+			name:   "running event skipped by default",
+			events: []storage.Event{event("a", at(0, 23, 0), 7200)},
+			list:   listDay,
+			date:   at(1, 0, 0),
+		},
+		{
+			name:   "running event included when overlapping",
+			events: []storage.Event{event("a", at(0, 23, 0), 7200)},
+			list:   listDay,
+			date:   at(1, 0, 0),
+			mode:   storage.OverlapsRange,
+			want:   []time.Time{at(0, 23, 0)},
+		},
+		{
+			name:   "event ended at midnight does not overlap",
+			events: []storage.Event{event("a", at(0, 23, 0), 3600)},
+			list:   listDay,
+			date:   at(1, 0, 0),
+			mode:   storage.OverlapsRange,
+		},
+		{
+			name:   "week bounds",
+			events: []storage.Event{event("a", at(4, 0, 0), 60), event("b", at(10, 23, 59), 60), event("c", at(11, 0, 0), 60)},
+			list:   listWeek,
+			date:   at(4, 15, 0),
+			want:   []time.Time{at(4, 0, 0), at(10, 23, 59)},
+		},
+		{
+			name:   "month includes first day",
+			events: []storage.Event{event("a", at(1, 0, 0), 60), event("b", at(31, 23, 0), 3600), event("c", at(32, 0, 0), 60)},
+			list:   listMonth,
+			date:   at(15, 0, 0),
+			want:   []time.Time{at(1, 0, 0), at(31, 23, 0)},
+		},
+		{
+			name:   "month overlapping includes event from previous month",
+			events: []storage.Event{event("a", at(0, 23, 0), 7200), event("b", at(31, 23, 0), 7200)},
+			list:   listMonth,
+			date:   at(1, 0, 0),
+			mode:   storage.OverlapsRange,
+			want:   []time.Time{at(0, 23, 0), at(31, 23, 0)},
+		},
+		{
+			name:   "recurring occurrence in day",
+			events: []storage.Event{daily},
+			list:   listDay,
+			date:   at(1, 0, 0),
+			want:   []time.Time{at(1, 23, 0)},
+		},
+		{
+			name:   "recurring occurrences overlapping day",
+			events: []storage.Event{daily},
+			list:   listDay,
+			date:   at(1, 0, 0),
+			mode:   storage.OverlapsRange,
+			want:   []time.Time{at(0, 23, 0), at(1, 23, 0)},
+		},
+		{
+			name:   "last recurring occurrence running into day",
+			events: []storage.Event{daily},
+			list:   listDay,
+			date:   at(5, 0, 0),
+			mode:   storage.OverlapsRange,
+			want:   []time.Time{at(4, 23, 0)},
+		},
+		{
+			name:   "other users are not listed",
+			events: []storage.Event{foreign},
+			list:   listDay,
+			date:   at(1, 0, 0),
+			mode:   storage.OverlapsRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newStorage(t)
+			for _, e := range tt.events {
+				require.NoError(t, s.Add(ctx, e))
+			}
+
+			events, err := tt.list(s, ctx, "user1", tt.date, tt.mode)
+			require.NoError(t, err)
+			got := make([]time.Time, 0, len(events))
+			for _, e := range events {
+				got = append(got, e.DateTime.UTC())
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i].Before(got[j]) })
+			if tt.want == nil {
+				tt.want = []time.Time{}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Package storagetest — набор тестов соответствия интерфейсу storage.Storage.
+// Реализация подключает его одной функцией:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage { return New() })
+//	}
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/melnikdv/OtusGolangHW/hw12_13_14_15_16_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStorage возвращает пустое хранилище для очередного подтеста.
+type NewStorage func(t *testing.T) storage.Storage
+
+// Run прогоняет все тесты набора; каждый подтест получает своё хранилище из newStorage.
+func Run(t *testing.T, newStorage NewStorage) {
+	t.Helper()
+	tests := []struct {
+		name string
+		run  func(t *testing.T, newStorage NewStorage)
+	}{
+		{"CRUD", testCRUD},
+		{"Errors", testErrors},
+		{"Ownership", testOwnership},
+		{"Recurring", testRecurring},
+		{"TimeZone", testTimeZone},
+		{"ListAll", testListAll},
+		{"ListRanges", testListRanges},
+		{"List", testList},
+		{"Search", testSearch},
+		{"ListToNotify", testListToNotify},
+		{"DeleteBefore", testDeleteBefore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStorage)
+		})
+	}
+}
+
+// at возвращает момент в марте 2024 года по UTC; day 0 — 29 февраля, 32 — 1 апреля.
+// Время кратно секунде, чтобы Postgres хранил его без округления.
+func at(day, hour, minute int) time.Time {
+	return time.Date(2024, time.March, day, hour, minute, 0, 0, time.UTC)
+}
+
+func event(id string, start time.Time, duration int64) storage.Event {
+	return storage.Event{ID: id, Title: id, DateTime: start, Duration: duration, UserID: "user1"}
+}
+
+func add(t *testing.T, s storage.Storage, events ...storage.Event) {
+	t.Helper()
+	for _, e := range events {
+		require.NoError(t, s.Add(context.Background(), e), "add %s", e.ID)
+	}
+}
+
+// normalize приводит время события к UTC: реализации могут возвращать его
+// в другом часовом поясе, а сравнивать нужно моменты.
+func normalize(e storage.Event) storage.Event {
+	e.DateTime = e.DateTime.UTC()
+	var exDates []time.Time
+	for _, ex := range e.ExDates {
+		exDates = append(exDates, ex.UTC())
+	}
+	e.ExDates = exDates
+	return e
+}
+
+func ids(events []storage.Event) []string {
+	result := make([]string, 0, len(events))
+	for _, e := range events {
+		result = append(result, e.ID)
+	}
+	return result
+}
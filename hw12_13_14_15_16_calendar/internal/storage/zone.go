@@ -0,0 +1,26 @@
+package storage
+
+import "time"
+
+// ZoneOf возвращает имя часового пояса t и его смещение от UTC в секундах для хранения.
+// У времени, разобранного из RFC 3339 (JSON, gRPC-клиенты), имени обычно нет: пояс
+// задан только смещением.
+func ZoneOf(t time.Time) (name string, offset int) {
+	_, offset = t.Zone()
+	return t.Location().String(), offset
+}
+
+// InZone переводит t в пояс, сохранённый ZoneOf. Поясу с именем из базы IANA возвращаются
+// правила перехода на летнее время; иначе восстанавливается фиксированное смещение.
+// Повторения разворачиваются в поясе DateTime, поэтому терять его нельзя.
+func InZone(t time.Time, name string, offset int) time.Time {
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return t.In(loc)
+		}
+	}
+	if name == "" && offset == 0 {
+		return t.UTC()
+	}
+	return t.In(time.FixedZone(name, offset))
+}
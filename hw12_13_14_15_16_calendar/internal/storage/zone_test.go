@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	parsed, err := time.Parse(time.RFC3339, "2024-01-01T01:00:00+03:00")
+	require.NoError(t, err)
+
+	for _, tt := range []time.Time{
+		parsed,
+		time.Date(2024, time.March, 25, 10, 0, 0, 0, berlin),
+		time.Date(2024, time.March, 25, 10, 0, 0, 0, time.UTC),
+		time.Date(2024, time.March, 25, 10, 0, 0, 0, time.FixedZone("MSK-ish", 3*3600)),
+	} {
+		name, offset := ZoneOf(tt)
+		got := InZone(tt.UTC(), name, offset)
+		assert.Equal(t, tt.Format(time.RFC3339), got.Format(time.RFC3339), name)
+		assert.Equal(t, tt.Location().String(), got.Location().String())
+	}
+
+	// Летнее время восстанавливается по правилам пояса, а не по сохранённому смещению
+	name, offset := ZoneOf(time.Date(2024, time.March, 25, 10, 0, 0, 0, berlin))
+	summer := InZone(time.Date(2024, time.July, 1, 8, 0, 0, 0, time.UTC), name, offset)
+	assert.Equal(t, 10, summer.Hour())
+}